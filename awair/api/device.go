@@ -14,6 +14,9 @@ const (
 	DeviceTypeUnknown      DeviceType = "unknown"
 )
 
+// PollInterval is how often a polling device fetches a new measurement
+const PollInterval = 10 * time.Second
+
 type Device struct {
 	Client             *Client
 	Type               *DeviceType
@@ -61,7 +64,7 @@ func (device *Device) SetOnMeasurement(callback func(*Measurement)) {
 	device.onMeasurement = callback
 }
 
-// StartPolling starts polling for measurements every 10 seconds
+// StartPolling starts polling for measurements every PollInterval
 func (device *Device) StartPolling() {
 	device.StopPolling()
 
@@ -72,7 +75,7 @@ func (device *Device) StartPolling() {
 	device.pollingContext, device.pollingCancel = context.WithCancel(context.Background())
 
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
+		ticker := time.NewTicker(PollInterval)
 		defer ticker.Stop()
 
 		for {
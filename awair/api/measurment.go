@@ -7,7 +7,7 @@ import (
 type Measurement struct {
 	Timestamp   time.Time `json:"timestamp,omitempty"`
 	Score       int       `json:"score,omitempty"`
-	DewPoint    float64   `json:"dew_point,omitempty"`
+	DewPoint    *float64  `json:"dew_point,omitempty"` // nil if the device doesn't report it
 	Temperature float64   `json:"temp,omitempty"`
 	Humidity    float64   `json:"humid,omitempty"`
 	CO2         int       `json:"co2,omitempty"`
@@ -18,6 +18,8 @@ BINARY_NAME="gnome-desktop-air-monitor"
 INSTALL_DIR="/usr/local/bin"
 DESKTOP_DIR="/usr/share/applications"
 ICON_DIR="/usr/share/icons/hicolor"
+SCHEMA_DIR="/usr/share/glib-2.0/schemas"
+SCHEMA_FILE="io.stanko.gnome-desktop-air-monitor.gschema.xml"
 
 # Functions
 log_info() {
@@ -204,6 +206,28 @@ install_desktop_file() {
   fi
 }
 
+# Download and install the GSettings schema
+install_schema() {
+  local tag_name="$1"
+  local temp_dir
+  temp_dir=$(mktemp -d)
+  trap "rm -rf $temp_dir" EXIT
+
+  log_info "Downloading GSettings schema..."
+
+  local schema_url="https://github.com/$REPO/releases/download/$tag_name/$SCHEMA_FILE"
+  if curl -sSLf "$schema_url" -o "$temp_dir/$SCHEMA_FILE"; then
+    sudo mkdir -p "$SCHEMA_DIR"
+    sudo cp "$temp_dir/$SCHEMA_FILE" "$SCHEMA_DIR/"
+    if command -v glib-compile-schemas &>/dev/null; then
+      sudo glib-compile-schemas "$SCHEMA_DIR"
+    fi
+    log_success "GSettings schema installed"
+  else
+    log_warning "Failed to download GSettings schema from release, settings will be stored in a file"
+  fi
+}
+
 # Install GNOME Shell extension
 install_extension() {
   local tag_name="$1"
@@ -266,6 +290,7 @@ main() {
   install_binary "$tag_name" "$arch"
   install_icons "$tag_name"
   install_desktop_file "$tag_name"
+  install_schema "$tag_name"
   install_extension "$tag_name"
 
   echo
@@ -0,0 +1,207 @@
+package airquality
+
+import (
+	"math"
+)
+
+// Pollutant identifies the pollutant an index was computed for
+type Pollutant string
+
+const (
+	PollutantPM25 Pollutant = "pm25"
+	PollutantCO2  Pollutant = "co2"
+	PollutantVOC  Pollutant = "voc"
+)
+
+// DisplayName returns the human readable name of the pollutant
+func (p Pollutant) DisplayName() string {
+	switch p {
+	case PollutantPM25:
+		return "PM2.5"
+	case PollutantCO2:
+		return "CO₂"
+	case PollutantVOC:
+		return "VOC"
+	default:
+		return string(p)
+	}
+}
+
+// Reading holds the concentrations the indices are computed from
+type Reading struct {
+	PM25Daily  float64 // 24 hour mean of PM2.5 in µg/m³
+	PM25Hourly float64 // 1 hour mean of PM2.5 in µg/m³
+	CO2        float64 // 1 hour mean of CO₂ in ppm
+	VOC        float64 // 1 hour mean of TVOC in ppb
+}
+
+// Level is the position of a value on an index scale, 0 being the best
+type Level struct {
+	Value int    // Numeric index value, 0 if the scale has none
+	Rank  int    // Position of the band, 0 is the best band
+	Max   int    // Rank of the worst band
+	Name  string // Name of the band
+}
+
+// severity returns how bad the level is relative to its own scale (0-1)
+func (level Level) severity() float64 {
+	if level.Max == 0 {
+		return 0
+	}
+	return float64(level.Rank) / float64(level.Max)
+}
+
+// Indices holds all locally computed air quality indices
+type Indices struct {
+	AQI               Level     // US EPA AQI for PM2.5
+	CAQI              Level     // EU CAQI for PM2.5
+	CO2               Level     // EN 16798-1 indoor environment category
+	VOC               Level     // TVOC category
+	DominantPollutant Pollutant // Pollutant with the worst relative level
+}
+
+// Valid reports whether there were measurements to compute the indices from
+func (indices Indices) Valid() bool {
+	return indices.AQI.Name != ""
+}
+
+// Compute calculates all indices for a reading
+func Compute(reading Reading) Indices {
+	indices := Indices{
+		AQI:  USAQI(reading.PM25Daily),
+		CAQI: EUCAQI(reading.PM25Hourly),
+		CO2:  CO2Category(reading.CO2),
+		VOC:  VOCCategory(reading.VOC),
+	}
+
+	// The PM2.5 severity is the worse of the two PM indices, ties favour
+	// PM2.5 over CO₂ over VOC
+	pmSeverity := math.Max(indices.AQI.severity(), indices.CAQI.severity())
+	indices.DominantPollutant = PollutantPM25
+	worst := pmSeverity
+
+	if severity := indices.CO2.severity(); severity > worst {
+		indices.DominantPollutant = PollutantCO2
+		worst = severity
+	}
+
+	if severity := indices.VOC.severity(); severity > worst {
+		indices.DominantPollutant = PollutantVOC
+	}
+
+	return indices
+}
+
+// breakpoint maps a concentration range onto an index range
+type breakpoint struct {
+	concentrationLow  float64
+	concentrationHigh float64
+	indexLow          float64
+	indexHigh         float64
+	name              string
+}
+
+// US EPA PM2.5 breakpoints as revised in 2024
+var usAQIBreakpoints = []breakpoint{
+	{0.0, 9.0, 0, 50, "Good"},
+	{9.1, 35.4, 51, 100, "Moderate"},
+	{35.5, 55.4, 101, 150, "Unhealthy for Sensitive Groups"},
+	{55.5, 125.4, 151, 200, "Unhealthy"},
+	{125.5, 225.4, 201, 300, "Very Unhealthy"},
+	{225.5, 325.4, 301, 500, "Hazardous"},
+}
+
+// USAQI computes the US EPA Air Quality Index from a 24 hour PM2.5 mean
+func USAQI(pm25 float64) Level {
+	// The EPA truncates PM2.5 concentrations to one decimal place
+	concentration := math.Floor(math.Max(pm25, 0)*10) / 10
+	maxRank := len(usAQIBreakpoints) - 1
+
+	for rank, bp := range usAQIBreakpoints {
+		if concentration <= bp.concentrationHigh {
+			value := (bp.indexHigh-bp.indexLow)/(bp.concentrationHigh-bp.concentrationLow)*
+				(concentration-bp.concentrationLow) + bp.indexLow
+			return Level{Value: int(math.Round(value)), Rank: rank, Max: maxRank, Name: bp.name}
+		}
+	}
+
+	last := usAQIBreakpoints[maxRank]
+	return Level{Value: int(last.indexHigh), Rank: maxRank, Max: maxRank, Name: last.name}
+}
+
+// CAQI hourly PM2.5 grid
+var euCAQIBreakpoints = []breakpoint{
+	{0, 15, 0, 25, "Very low"},
+	{15, 30, 25, 50, "Low"},
+	{30, 55, 50, 75, "Medium"},
+	{55, 110, 75, 100, "High"},
+}
+
+// EUCAQI computes the European Common Air Quality Index from a 1 hour PM2.5 mean
+func EUCAQI(pm25 float64) Level {
+	concentration := math.Max(pm25, 0)
+	maxRank := len(euCAQIBreakpoints)
+
+	for rank, bp := range euCAQIBreakpoints {
+		if concentration <= bp.concentrationHigh {
+			value := (bp.indexHigh-bp.indexLow)/(bp.concentrationHigh-bp.concentrationLow)*
+				(concentration-bp.concentrationLow) + bp.indexLow
+			return Level{Value: int(math.Round(value)), Rank: rank, Max: maxRank, Name: bp.name}
+		}
+	}
+
+	// Above the grid the index keeps growing with the slope of the last band
+	last := euCAQIBreakpoints[len(euCAQIBreakpoints)-1]
+	value := last.indexHigh + (concentration-last.concentrationHigh)*
+		(last.indexHigh-last.indexLow)/(last.concentrationHigh-last.concentrationLow)
+	return Level{Value: int(math.Round(value)), Rank: maxRank, Max: maxRank, Name: "Very high"}
+}
+
+// OutdoorCO2 is the assumed outdoor CO₂ concentration in ppm
+const OutdoorCO2 = 400
+
+// CO₂ concentrations above outdoor for EN 16798-1 categories I to III
+var co2CategoryLimits = []struct {
+	aboveOutdoor float64
+	name         string
+}{
+	{550, "Category I"},
+	{800, "Category II"},
+	{1350, "Category III"},
+}
+
+// CO2Category returns the EN 16798-1 ventilation category for a CO₂ concentration
+func CO2Category(co2 float64) Level {
+	maxRank := len(co2CategoryLimits)
+
+	for rank, limit := range co2CategoryLimits {
+		if co2-OutdoorCO2 <= limit.aboveOutdoor {
+			return Level{Rank: rank, Max: maxRank, Name: limit.name}
+		}
+	}
+
+	return Level{Rank: maxRank, Max: maxRank, Name: "Category IV"}
+}
+
+// TVOC limits in ppb, following the bands used by Awair's own VOC rating
+var vocCategoryLimits = []struct {
+	limit float64
+	name  string
+}{
+	{333, "Low"},
+	{1000, "Moderate"},
+	{3333, "High"},
+}
+
+// VOCCategory returns the category for a TVOC concentration in ppb
+func VOCCategory(voc float64) Level {
+	maxRank := len(vocCategoryLimits)
+
+	for rank, limit := range vocCategoryLimits {
+		if voc <= limit.limit {
+			return Level{Rank: rank, Max: maxRank, Name: limit.name}
+		}
+	}
+
+	return Level{Rank: maxRank, Max: maxRank, Name: "Very high"}
+}
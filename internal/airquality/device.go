@@ -0,0 +1,58 @@
+package airquality
+
+import (
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// average holds mean concentrations over a period of time
+type average struct {
+	PM25  float64
+	CO2   float64
+	VOC   float64
+	Count int64
+}
+
+// ForDevice computes the indices of a device from its stored measurements.
+// PM2.5 uses the daily mean for the AQI and the hourly mean for the CAQI,
+// CO₂ and VOC use the hourly mean. If the device reported nothing in the
+// last hour the daily means are used instead, and if it reported nothing in
+// the last day the indices aren't valid.
+func ForDevice(db *gorm.DB, deviceID uint, now time.Time) (Indices, error) {
+	daily, err := averageSince(db, deviceID, now.Add(-24*time.Hour))
+	if err != nil {
+		return Indices{}, err
+	}
+
+	if daily.Count == 0 {
+		return Indices{}, nil
+	}
+
+	hourly, err := averageSince(db, deviceID, now.Add(-time.Hour))
+	if err != nil {
+		return Indices{}, err
+	}
+
+	if hourly.Count == 0 {
+		hourly = daily
+	}
+
+	return Compute(Reading{
+		PM25Daily:  daily.PM25,
+		PM25Hourly: hourly.PM25,
+		CO2:        hourly.CO2,
+		VOC:        hourly.VOC,
+	}), nil
+}
+
+// averageSince averages the PM2.5, CO₂ and VOC measurements of a device since a point in time
+func averageSince(db *gorm.DB, deviceID uint, since time.Time) (average, error) {
+	var result average
+	err := db.Model(&models.Measurement{}).
+		Select("COALESCE(AVG(pm25), 0) AS pm25, COALESCE(AVG(co2), 0) AS co2, COALESCE(AVG(voc), 0) AS voc, COUNT(*) AS count").
+		Where("device_id = ? AND timestamp >= ?", deviceID, since.UTC()).
+		Scan(&result).Error
+	return result, err
+}
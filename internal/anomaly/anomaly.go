@@ -0,0 +1,141 @@
+// Package anomaly detects measurements that look like sensor faults or
+// glitches instead of real readings, and gaps in the measurements of a device.
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+)
+
+// Range is the span of values a metric can physically take, in the unit it is stored in
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// Ranges are the values outside of which a measurement is out of range
+var Ranges = map[stats.Metric]Range{
+	stats.MetricTemperature: {-40, 85},
+	stats.MetricHumidity:    {0, 100},
+	stats.MetricCO2:         {300, 10000}, // Outdoor air alone has over 400 ppm
+	stats.MetricVOC:         {0, 60000},
+	stats.MetricPM25:        {0, 1000},
+	stats.MetricScore:       {0, 100},
+}
+
+// Jumps are the largest changes between two consecutive measurements
+// that indoor air can make, larger changes are glitches
+var Jumps = map[stats.Metric]float64{
+	stats.MetricTemperature: 5,
+	stats.MetricHumidity:    20,
+	stats.MetricCO2:         2000,
+}
+
+const (
+	// FlatLineAfter is how long every value has to stay exactly the same
+	// before the readings count as flat-lined, real sensors always jitter
+	FlatLineAfter = time.Hour
+
+	// StuckCO2After is how long CO₂ has to stay at the sensor's floor before
+	// the sensor counts as stuck
+	StuckCO2After = time.Hour
+
+	// StuckCO2Value is the floor CO₂ sensors report when they get stuck
+	StuckCO2Value = 400
+
+	// Lookback is how far around a period measurements are needed to detect
+	// anomalies that span a while at the bounds of the period
+	Lookback = FlatLineAfter
+)
+
+// sensorMetrics are the metrics measured by a sensor, the score is computed from them
+var sensorMetrics = []stats.Metric{stats.MetricTemperature, stats.MetricHumidity, stats.MetricCO2, stats.MetricVOC, stats.MetricPM25}
+
+// Gap is a period without measurements
+type Gap struct {
+	From time.Time
+	To   time.Time
+}
+
+// Duration returns how long the gap lasted
+func (gap Gap) Duration() time.Duration {
+	return gap.To.Sub(gap.From)
+}
+
+// Detect returns the anomalies of each of the measurements of a device,
+// which must be ordered by timestamp
+func Detect(measurements []models.Measurement) []models.Anomaly {
+	anomalies := make([]models.Anomaly, len(measurements))
+
+	for i, measurement := range measurements {
+		for metric, valid := range Ranges {
+			value := metric.Value(measurement)
+			if value < valid.Min || value > valid.Max || math.IsNaN(value) {
+				anomalies[i] |= models.AnomalyOutOfRange
+			}
+		}
+
+		if i == 0 || measurement.Timestamp.Sub(measurements[i-1].Timestamp) > stats.MaxGap {
+			continue
+		}
+		for metric, limit := range Jumps {
+			if math.Abs(metric.Value(measurement)-metric.Value(measurements[i-1])) > limit {
+				anomalies[i] |= models.AnomalyJump
+			}
+		}
+	}
+
+	flagRuns(measurements, anomalies, models.AnomalyFlatLine, FlatLineAfter, sameReadings)
+	flagRuns(measurements, anomalies, models.AnomalyStuckCO2, StuckCO2After, func(a, b models.Measurement) bool {
+		return a.CO2 == StuckCO2Value && b.CO2 == StuckCO2Value
+	})
+
+	return anomalies
+}
+
+// sameReadings tells whether two measurements have exactly the same values
+func sameReadings(a, b models.Measurement) bool {
+	for _, metric := range sensorMetrics {
+		if metric.Value(a) != metric.Value(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// flagRuns flags runs of consecutive measurements, without gaps between
+// them, for which same holds between neighbours and that last at least
+// the given duration
+func flagRuns(measurements []models.Measurement, anomalies []models.Anomaly, anomaly models.Anomaly, duration time.Duration, same func(a, b models.Measurement) bool) {
+	start := 0
+	for i := 1; i <= len(measurements); i++ {
+		if i < len(measurements) &&
+			measurements[i].Timestamp.Sub(measurements[i-1].Timestamp) <= stats.MaxGap &&
+			same(measurements[i-1], measurements[i]) {
+			continue
+		}
+
+		if i-start > 1 && measurements[i-1].Timestamp.Sub(measurements[start].Timestamp) >= duration {
+			for j := start; j < i; j++ {
+				anomalies[j] |= anomaly
+			}
+		}
+		start = i
+	}
+}
+
+// Gaps returns the periods between consecutive measurements, ordered by
+// timestamp, that are longer than the given duration
+func Gaps(measurements []models.Measurement, longerThan time.Duration) []Gap {
+	var gaps []Gap
+	for i := 1; i < len(measurements); i++ {
+		gap := Gap{From: measurements[i-1].Timestamp, To: measurements[i].Timestamp}
+		if gap.Duration() > longerThan {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps
+}
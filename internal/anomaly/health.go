@@ -0,0 +1,218 @@
+package anomaly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"gorm.io/gorm"
+)
+
+const (
+	// GapWarningAfter is how long a device has to go without measurements
+	// for the gap to be pointed out
+	GapWarningAfter = 30 * time.Minute
+
+	// JumpsForWarning is how many impossible jumps it takes to suspect the
+	// sensor, a single one can be a glitch of the connection
+	JumpsForWarning = 3
+
+	// HealthPeriod is how far back the health of a device is judged from
+	HealthPeriod = 24 * time.Hour
+
+	// flagBatchSize is how many measurements are updated per statement
+	flagBatchSize = 500
+)
+
+// Health describes how trustworthy the measurements of a device were over a period
+type Health struct {
+	From      time.Time
+	To        time.Time
+	Count     int                              // Number of measurements
+	Anomalous int                              // Number of measurements with any anomaly
+	Flagged   map[models.Anomaly]int           // Number of measurements with each anomaly
+	Durations map[models.Anomaly]time.Duration // Time spent with each anomaly
+	Gaps      []Gap                            // Periods without measurements, including one up to To
+}
+
+// Faulty tells whether the sensor of the device looks broken, gaps alone
+// don't count as a device may simply have been switched off
+func (health Health) Faulty() bool {
+	for _, anomaly := range models.Anomalies {
+		if health.Flagged[anomaly] >= health.threshold(anomaly) {
+			return true
+		}
+	}
+	return false
+}
+
+// threshold returns how many measurements with an anomaly make it worth a warning
+func (health Health) threshold(anomaly models.Anomaly) int {
+	if anomaly == models.AnomalyJump {
+		return JumpsForWarning
+	}
+	return 1
+}
+
+// Warnings describes the problems found, one per line
+func (health Health) Warnings() []string {
+	var warnings []string
+
+	for _, anomaly := range models.Anomalies {
+		count := health.Flagged[anomaly]
+		if count < health.threshold(anomaly) {
+			continue
+		}
+
+		switch {
+		case anomaly == models.AnomalyJump:
+			warnings = append(warnings, fmt.Sprintf("%d impossible jumps between readings", count))
+		case health.Durations[anomaly] < time.Minute:
+			warnings = append(warnings, fmt.Sprintf("%s in %s", anomaly.DisplayName(), readings(count)))
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s for %s", anomaly.DisplayName(), stats.FormatDuration(health.Durations[anomaly])))
+		}
+	}
+
+	if len(health.Gaps) > 0 {
+		longest := health.Gaps[0]
+		for _, gap := range health.Gaps {
+			if gap.Duration() > longest.Duration() {
+				longest = gap
+			}
+		}
+
+		if len(health.Gaps) == 1 {
+			warnings = append(warnings, fmt.Sprintf("No measurements for %s from %s",
+				stats.FormatDuration(longest.Duration()), longest.From.Local().Format("Mon 15:04")))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%d gaps in the measurements, the longest %s from %s",
+				len(health.Gaps), stats.FormatDuration(longest.Duration()), longest.From.Local().Format("Mon 15:04")))
+		}
+	}
+
+	return warnings
+}
+
+// readings formats a number of readings
+func readings(count int) string {
+	if count == 1 {
+		return "1 reading"
+	}
+	return fmt.Sprintf("%d readings", count)
+}
+
+// Check judges the health of a device from its measurements between two
+// points in time, ordered by timestamp. Measurements before from are only
+// used to detect anomalies that started before the period.
+func Check(measurements []models.Measurement, from, to time.Time) Health {
+	health := Health{
+		From:      from,
+		To:        to,
+		Flagged:   make(map[models.Anomaly]int),
+		Durations: make(map[models.Anomaly]time.Duration),
+	}
+
+	anomalies := Detect(measurements)
+
+	start := len(measurements)
+	for i, measurement := range measurements {
+		if !measurement.Timestamp.Before(from) {
+			start = i
+			break
+		}
+	}
+	measurements, anomalies = measurements[start:], anomalies[start:]
+	health.Count = len(measurements)
+
+	for i, measurement := range measurements {
+		if anomalies[i] != 0 {
+			health.Anomalous++
+		}
+
+		var duration time.Duration
+		if i+1 < len(measurements) {
+			if next := measurements[i+1].Timestamp.Sub(measurement.Timestamp); next <= stats.MaxGap {
+				duration = next
+			}
+		}
+
+		for _, anomaly := range models.Anomalies {
+			if anomalies[i].Has(anomaly) {
+				health.Flagged[anomaly]++
+				health.Durations[anomaly] += duration
+			}
+		}
+	}
+
+	health.Gaps = Gaps(measurements, GapWarningAfter)
+
+	// The device may have stopped reporting altogether
+	last := from
+	if len(measurements) > 0 {
+		last = measurements[len(measurements)-1].Timestamp
+	}
+	if to.Sub(last) > GapWarningAfter {
+		health.Gaps = append(health.Gaps, Gap{From: last, To: to})
+	}
+
+	return health
+}
+
+// CheckDevice judges the health of a device from its stored measurements
+// between two points in time
+func CheckDevice(db *gorm.DB, deviceID uint, from, to time.Time) (Health, error) {
+	measurements, err := load(db, deviceID, from.Add(-Lookback), to)
+	if err != nil {
+		return Health{}, err
+	}
+
+	return Check(measurements, from, to), nil
+}
+
+// FlagDevice stores the anomalies of the measurements of a device between
+// two points in time, clearing flags that no longer apply, and returns
+// how many measurements changed. Measurements just outside of the period
+// are looked at too, to catch anomalies that span its bounds.
+func FlagDevice(db *gorm.DB, deviceID uint, from, to time.Time) (int, error) {
+	measurements, err := load(db, deviceID, from.Add(-Lookback), to.Add(Lookback))
+	if err != nil {
+		return 0, err
+	}
+
+	// Group the changes by their new flags to update them together
+	changes := make(map[models.Anomaly][]uint)
+	for i, anomalies := range Detect(measurements) {
+		measurement := measurements[i]
+		if measurement.Timestamp.Before(from) || measurement.Timestamp.After(to) || measurement.Anomalies == anomalies {
+			continue
+		}
+		changes[anomalies] = append(changes[anomalies], measurement.ID)
+	}
+
+	changed := 0
+	for anomalies, ids := range changes {
+		for len(ids) > 0 {
+			batch := ids[:min(len(ids), flagBatchSize)]
+			err := db.Model(&models.Measurement{}).Where("id IN ?", batch).Update("anomalies", anomalies).Error
+			if err != nil {
+				return changed, err
+			}
+			changed += len(batch)
+			ids = ids[len(batch):]
+		}
+	}
+
+	return changed, nil
+}
+
+// load returns the measurements of a device between two points in time
+func load(db *gorm.DB, deviceID uint, from, to time.Time) ([]models.Measurement, error) {
+	var measurements []models.Measurement
+	err := db.Select("id, timestamp, temperature, humidity, co2, voc, pm25, score, anomalies").
+		Where("device_id = ? AND timestamp BETWEEN ? AND ?", deviceID, from.UTC(), to.UTC()).
+		Order("timestamp ASC").
+		Find(&measurements).Error
+	return measurements, err
+}
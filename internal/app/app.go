@@ -2,6 +2,7 @@ package app
 
 import (
 	"log/slog"
+	"sync"
 	"time"
 
 	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
@@ -10,13 +11,39 @@ import (
 	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
 	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
 	api "github.com/monorkin/gnome-desktop-air-monitor/awair/api"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
 	database "github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/forecast"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
 )
 
 const (
 	APP_IDENTIFIER = "io.stanko.gnome-desktop-air-monitor"
+
+	// MEASUREMENT_WRITE_INTERVAL is how often buffered measurements are written to the database
+	MEASUREMENT_WRITE_INTERVAL = 10 * time.Second
+
+	// GRAPH_GAP_AFTER is how long a device can go without measurements before
+	// the graph breaks its line, a few polls may be missed without an outage
+	GRAPH_GAP_AFTER = 6 * api.PollInterval
+
+	// GRAPH_FORECAST_SHARE is how much of the time window the graph adds
+	// past the latest measurements to show where they are heading
+	GRAPH_FORECAST_SHARE = 0.25
+
+	// GRAPH_MIN_WINDOW and GRAPH_MAX_WINDOW limit how far the graph can be zoomed in and out
+	GRAPH_MIN_WINDOW = 10 * time.Minute
+	GRAPH_MAX_WINDOW = 7 * 24 * time.Hour
+
+	// GRAPH_ZOOM_STEP is how much a step of the scroll wheel zooms the graph
+	GRAPH_ZOOM_STEP = 1.2
+
+	// GRAPH_SCROLL_STEP_PIXELS is how far scrolling on a touchpad counts as
+	// a step of the scroll wheel
+	GRAPH_SCROLL_STEP_PIXELS = 20.0
 )
 
 type App struct {
@@ -33,11 +60,33 @@ type App struct {
 	indexPage      *IndexPageState    // Index page state
 	settingsPage   *SettingsPageState // Settings page state
 	cleanupTicker  *time.Ticker       // Ticker for periodic data cleanup
+	backupMutex    sync.Mutex         // Prevents overlapping automatic backups
+	reportMutex    sync.Mutex         // Prevents sending the weekly report twice
+	healthMutex    sync.Mutex         // Prevents overlapping device health checks
+	faultyDevices  map[uint]bool      // Devices whose sensors looked faulty at the last health check
+
+	measurementWriter *database.MeasurementWriter // Buffers measurements and writes them in batches
+	deviceIDs         map[string]uint             // Database IDs of devices by serial number
+	deviceIDsMutex    sync.RWMutex
+	derived           map[uint]derivedValues // Values computed from the measurements of devices by device ID
+	derivedMutex      sync.Mutex
+}
+
+// derivedValues are the values computed from the stored measurements of a
+// device, they only change when the device gets a new measurement
+type derivedValues struct {
+	latest        time.Time // Time of the latest measurement they were computed with
+	indices       airquality.Indices
+	co2Forecast   forecast.Forecast
+	scoreForecast forecast.Forecast
 }
 
 type DeviceWithMeasurement struct {
-	Device      models.Device
-	Measurement models.Measurement
+	Device        models.Device
+	Measurement   models.Measurement
+	Indices       airquality.Indices
+	CO2Forecast   forecast.Forecast // Where CO₂ is heading, invalid without recent measurements
+	ScoreForecast forecast.Forecast // Where the score is heading, invalid without recent measurements
 }
 
 func NewApp() *App {
@@ -50,15 +99,19 @@ func NewApp() *App {
 	)
 
 	app := &App{
-		Application:  application,
-		apiClient:    api.NewClientWithLogger(globals.Logger),
-		logger:       globals.Logger,
-		devicePage:   &DevicePageState{},   // Initialize device page state
-		indexPage:    &IndexPageState{},    // Initialize index page state
-		settingsPage: &SettingsPageState{}, // Initialize settings page state
+		Application:   application,
+		apiClient:     api.NewClientWithLogger(globals.Logger),
+		logger:        globals.Logger,
+		devicePage:    &DevicePageState{},   // Initialize device page state
+		indexPage:     &IndexPageState{},    // Initialize index page state
+		settingsPage:  &SettingsPageState{}, // Initialize settings page state
+		deviceIDs:     make(map[string]uint),
+		derived:       make(map[uint]derivedValues),
+		faultyDevices: make(map[uint]bool),
 	}
 
 	app.ConnectActivate(app.onActivate)
+	app.setupReportActions()
 
 	// Hold the application so it doesn't quit when the window is closed
 	app.Hold()
@@ -72,6 +125,12 @@ func (app *App) onActivate() {
 
 	// Database is already initialized in NewApp()
 
+	// Started before the DBUS service, whose methods can restore the database
+	app.measurementWriter = database.NewMeasurementWriter(MEASUREMENT_WRITE_INTERVAL, app.onMeasurementsWritten, func(err error) {
+		app.logger.Error("Failed to write measurements, retrying with the next batch", "error", err)
+	})
+	app.measurementWriter.Start()
+
 	// Initialize DBUS service
 	var err error
 	app.dbusService, err = NewDBusService(app)
@@ -83,6 +142,8 @@ func (app *App) onActivate() {
 		app.dbusService.StartPeriodicUpdates()
 		// Send initial visibility state
 		app.dbusService.EmitVisibilityChanged()
+		// Export objects for the known devices
+		app.syncDBusDevices()
 	}
 
 	app.mainWindow = adw.NewApplicationWindow(app.Application)
@@ -128,6 +189,9 @@ func (app *App) onActivate() {
 
 	// Start periodic data cleanup
 	app.startDataCleanup()
+
+	// Pick up settings changed by other processes
+	app.watchSettings()
 }
 
 func (app *App) Run() int {
@@ -138,6 +202,13 @@ func (app *App) Quit() {
 	// Stop device polling
 	app.stopAllDevicePolling()
 
+	// Write the measurements that are still buffered
+	if app.measurementWriter != nil {
+		if err := app.measurementWriter.Close(); err != nil {
+			app.logger.Error("Failed to write buffered measurements", "error", err)
+		}
+	}
+
 	// Stop data cleanup
 	app.stopDataCleanup()
 
@@ -146,6 +217,9 @@ func (app *App) Quit() {
 		app.dbusService.Close()
 	}
 
+	// Stop watching settings
+	globals.Settings.Store().Close()
+
 	// Release the hold and quit
 	app.Release()
 	app.Application.Quit()
@@ -166,14 +240,15 @@ func (app *App) onDeviceDiscovered(apiDevice api.Device) {
 	}
 
 	app.logger.Info("Device stored successfully", "name", dbDevice.Name, "serial", dbDevice.SerialNumber)
+	app.syncDBusDevices()
 
 	// Store initial measurement if available
 	if apiDevice.LastMeasurement != nil {
-		err := app.storeMeasurement(dbDevice.ID, *apiDevice.LastMeasurement)
+		deviceID, err := app.deviceID(dbDevice.SerialNumber)
 		if err != nil {
-			app.logger.Error("Failed to store initial measurement", "device_id", dbDevice.ID, "error", err)
+			app.logger.Error("Failed to find device for initial measurement", "serial", dbDevice.SerialNumber, "error", err)
 		} else {
-			app.logger.Debug("Initial measurement stored", "device_id", dbDevice.ID)
+			app.queueMeasurement(deviceID, *apiDevice.LastMeasurement)
 		}
 	}
 
@@ -218,16 +293,22 @@ func (app *App) storeDevice(device models.Device) error {
 		existingDevice.IPAddress = device.IPAddress
 		existingDevice.LastSeen = device.LastSeen
 
-		err := database.DB.Save(&existingDevice).Error
+		err := database.Write(func(tx *gorm.DB) error {
+			return tx.Save(&existingDevice).Error
+		})
 		if err == nil {
+			app.cacheDeviceID(existingDevice.SerialNumber, existingDevice.ID)
 			// Refresh the UI after storing device
 			app.refreshDevicesFromDatabaseSafe()
 		}
 		return err
 	} else {
 		// Device doesn't exist, create it
-		err := database.DB.Create(&device).Error
+		err := database.Write(func(tx *gorm.DB) error {
+			return tx.Create(&device).Error
+		})
 		if err == nil {
+			app.cacheDeviceID(device.SerialNumber, device.ID)
 			// Refresh the UI after storing device
 			app.refreshDevicesFromDatabaseSafe()
 		}
@@ -243,6 +324,12 @@ func (app *App) refreshDevicesFromDatabase() {
 		return
 	}
 
+	// Don't refresh if user is editing device room or tags
+	if app.devicePage.isEditingLocation {
+		app.logger.Debug("Skipping UI refresh - device location editing in progress")
+		return
+	}
+
 	app.logger.Debug("Starting UI refresh from database")
 
 	app.logger.Debug("Refreshing UI components", "current_device", app.devicePage.currentDeviceSerial)
@@ -266,23 +353,86 @@ func (app *App) refreshDevicesFromDatabaseSafe() {
 	})
 }
 
-// storeMeasurement stores a measurement in the database
-func (app *App) storeMeasurement(deviceID uint, apiMeasurement api.Measurement) error {
-	device := database.DB.First(&models.Device{}, deviceID)
-	if device.Error != nil {
-		return device.Error
+// deviceID returns the database ID of a device by serial number. IDs are
+// cached, so only the first lookup of each device queries the database.
+func (app *App) deviceID(serialNumber string) (uint, error) {
+	app.deviceIDsMutex.RLock()
+	id, ok := app.deviceIDs[serialNumber]
+	app.deviceIDsMutex.RUnlock()
+	if ok {
+		return id, nil
 	}
 
-	err := database.DB.Model(&models.Device{}).Where("id = ?", deviceID).Update("last_seen", time.Now()).Error
+	var device models.Device
+	err := database.DB.Select("id").Where("serial_number = ?", serialNumber).First(&device).Error
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	app.cacheDeviceID(serialNumber, device.ID)
+	return device.ID, nil
+}
+
+// cacheDeviceID remembers the database ID of a device
+func (app *App) cacheDeviceID(serialNumber string, id uint) {
+	app.deviceIDsMutex.Lock()
+	defer app.deviceIDsMutex.Unlock()
+
+	app.deviceIDs[serialNumber] = id
+}
+
+// forgetDeviceIDs clears the cached device IDs, they are looked up again when needed
+func (app *App) forgetDeviceIDs() {
+	app.deviceIDsMutex.Lock()
+	defer app.deviceIDsMutex.Unlock()
+
+	app.deviceIDs = make(map[string]uint)
+}
+
+// restoreDatabase replaces the database with a backup and returns the path
+// of the backup of the replaced database. Device IDs are looked up again,
+// values computed from measurements are computed again and measurements
+// queued for the replaced database are dropped, as devices can have other
+// IDs in the restored one.
+func (app *App) restoreDatabase(path string) (string, error) {
+	var snapshot string
+	var err error
+	app.measurementWriter.ReplaceDatabase(func() bool {
+		snapshot, err = database.Restore(database.DB, path)
+		// The current database is only overwritten once it's backed up
+		if snapshot == "" {
+			return false
+		}
+
+		app.forgetDeviceIDs()
+		app.forgetDerivedValues()
+		return true
+	})
+	if err != nil {
+		return snapshot, err
 	}
 
+	app.logger.Info("Restored database", "path", path, "previous_database", snapshot)
+	glib.IdleAdd(func() bool {
+		app.refreshDevicesFromDatabase()
+		app.syncDBusDevices()
+		app.settingsPage.refreshDropdown(app, app.settingsPage.deviceList)
+		app.settingsPage.updateDatabaseSize()
+		return false // Don't repeat
+	})
+
+	return snapshot, nil
+}
+
+// queueMeasurement buffers a measurement, it is written to the database
+// with the measurements of the other devices in the next batch
+func (app *App) queueMeasurement(deviceID uint, apiMeasurement api.Measurement) {
 	measurement := models.Measurement{
 		DeviceID:    deviceID,
-		Timestamp:   apiMeasurement.Timestamp,
+		Timestamp:   apiMeasurement.Timestamp.UTC(),
 		Temperature: apiMeasurement.Temperature,
 		Humidity:    apiMeasurement.Humidity,
+		DewPoint:    apiMeasurement.DewPoint,
 		CO2:         float64(apiMeasurement.CO2),
 		VOC:         float64(apiMeasurement.VOC),
 		PM25:        float64(apiMeasurement.PM25),
@@ -291,16 +441,57 @@ func (app *App) storeMeasurement(deviceID uint, apiMeasurement api.Measurement)
 
 	// If timestamp is zero, use current time
 	if measurement.Timestamp.IsZero() {
-		measurement.Timestamp = time.Now()
+		measurement.Timestamp = time.Now().UTC()
+	}
+
+	app.measurementWriter.Add(measurement)
+	app.logger.Debug("Measurement queued", "device_id", deviceID, "score", measurement.Score)
+}
+
+// onMeasurementsWritten is called by the measurement writer after a batch
+// was written. Only the devices with new measurements are updated.
+func (app *App) onMeasurementsWritten(measurements []models.Measurement) {
+	latest := make(map[uint]models.Measurement)
+	for _, measurement := range measurements {
+		if current, ok := latest[measurement.DeviceID]; !ok || !measurement.Timestamp.Before(current.Timestamp) {
+			latest[measurement.DeviceID] = measurement
+		}
 	}
 
-	err = database.DB.Create(&measurement).Error
-	if err == nil {
-		app.logger.Debug("Measurement stored", "device_id", deviceID, "score", measurement.Score)
-		// Refresh UI after storing measurement (safely from any thread)
-		app.refreshDevicesFromDatabaseSafe()
+	deviceIDs := make([]uint, 0, len(latest))
+	for deviceID := range latest {
+		deviceIDs = append(deviceIDs, deviceID)
 	}
-	return err
+
+	var devices []models.Device
+	if err := database.DB.Preload("Tags").Find(&devices, deviceIDs).Error; err != nil {
+		app.logger.Error("Failed to load devices with new measurements", "error", err)
+		return
+	}
+
+	app.logger.Debug("Measurements written", "count", len(measurements), "devices", len(devices))
+
+	updated := make([]DeviceWithMeasurement, 0, len(devices))
+	for _, device := range devices {
+		deviceData := app.deviceWithMeasurement(device, latest[device.ID])
+		updated = append(updated, deviceData)
+
+		// Notify D-Bus clients about the new measurement
+		if app.dbusService != nil {
+			app.dbusService.EmitMeasurementStored(&deviceData)
+		}
+
+		// Check if this measurement is for the device shown in shell extension
+		app.updateShellExtensionIfNeeded(&deviceData)
+	}
+
+	glib.IdleAdd(func() bool {
+		for _, deviceData := range updated {
+			app.indexPage.updateDevice(app, deviceData)
+			app.devicePage.updateDevice(app, deviceData)
+		}
+		return false // Don't repeat
+	})
 }
 
 // startDevicePolling starts polling for a discovered device
@@ -326,23 +517,13 @@ func (app *App) startDevicePolling(apiDevice api.Device) {
 func (app *App) onDeviceMeasurement(apiDevice api.Device, measurement *api.Measurement) {
 	app.logger.Debug("New measurement received", "device_id", *apiDevice.ID, "score", measurement.Score)
 
-	// Find the device in database to get its ID
-	var dbDevice models.Device
-	err := database.DB.Where("serial_number = ?", *apiDevice.ID).First(&dbDevice).Error
+	deviceID, err := app.deviceID(*apiDevice.ID)
 	if err != nil {
 		app.logger.Error("Failed to find device for measurement", "device_id", *apiDevice.ID, "error", err)
 		return
 	}
 
-	// Store the measurement
-	err = app.storeMeasurement(dbDevice.ID, *measurement)
-	if err != nil {
-		app.logger.Error("Failed to store measurement", "device_id", dbDevice.ID, "error", err)
-		return
-	}
-
-	// Check if this measurement is for the device shown in shell extension
-	app.updateShellExtensionIfNeeded(dbDevice.SerialNumber)
+	app.queueMeasurement(deviceID, *measurement)
 }
 
 // stopAllDevicePolling stops polling for all devices
@@ -357,45 +538,115 @@ func (app *App) stopAllDevicePolling() {
 // getDevicesWithMeasurements loads all devices with their latest measurements from the database
 func (app *App) getDevicesWithMeasurements() ([]DeviceWithMeasurement, error) {
 	var devices []models.Device
-	err := database.DB.Find(&devices).Error
+	err := database.DB.Preload("Tags").Find(&devices).Error
 	if err != nil {
 		return nil, err
 	}
 
 	devicesWithMeasurements := make([]DeviceWithMeasurement, 0, len(devices))
-
 	for _, device := range devices {
-		// Get the latest measurement for this device
-		var measurement models.Measurement
-		err := database.DB.Where("device_id = ?", device.ID).
-			Order("timestamp DESC").
-			First(&measurement).Error
-
-		deviceWithMeasurement := DeviceWithMeasurement{
-			Device: device,
-		}
+		devicesWithMeasurements = append(devicesWithMeasurements, app.loadDeviceWithMeasurement(device))
+	}
 
-		if err == nil {
-			// Found measurement
-			deviceWithMeasurement.Measurement = measurement
-		} else {
-			// No measurement found, create a placeholder
-			deviceWithMeasurement.Measurement = models.Measurement{
-				DeviceID:    device.ID,
-				Timestamp:   time.Now(),
-				Temperature: 0,
-				Humidity:    0,
-				CO2:         0,
-				VOC:         0,
-				PM25:        0,
-				Score:       0,
-			}
+	return devicesWithMeasurements, nil
+}
+
+// getDeviceWithMeasurement loads a single device by serial number with its latest measurement
+func (app *App) getDeviceWithMeasurement(serialNumber string) (*DeviceWithMeasurement, error) {
+	var device models.Device
+	err := database.DB.Preload("Tags").Where("serial_number = ?", serialNumber).First(&device).Error
+	if err != nil {
+		return nil, err
+	}
+
+	deviceWithMeasurement := app.loadDeviceWithMeasurement(device)
+	return &deviceWithMeasurement, nil
+}
+
+// loadDeviceWithMeasurement attaches the latest measurement and air quality indices to a device
+func (app *App) loadDeviceWithMeasurement(device models.Device) DeviceWithMeasurement {
+	// Get the latest measurement for this device
+	var measurement models.Measurement
+	err := database.DB.Where("device_id = ?", device.ID).
+		Order("timestamp DESC").
+		First(&measurement).Error
+
+	if err != nil {
+		// No measurement found, create a placeholder
+		measurement = models.Measurement{
+			DeviceID:    device.ID,
+			Timestamp:   time.Now(),
+			Temperature: 0,
+			Humidity:    0,
+			CO2:         0,
+			VOC:         0,
+			PM25:        0,
+			Score:       0,
 		}
+	}
+
+	return app.deviceWithMeasurement(device, measurement)
+}
+
+// deviceWithMeasurement combines a device with its latest measurement and
+// the values computed from its stored measurements. These are computed once
+// per measurement, devices that got no new one since reuse them.
+func (app *App) deviceWithMeasurement(device models.Device, measurement models.Measurement) DeviceWithMeasurement {
+	now := time.Now()
+
+	app.derivedMutex.Lock()
+	derived, ok := app.derived[device.ID]
+	app.derivedMutex.Unlock()
+
+	if !ok || !derived.latest.Equal(measurement.Timestamp) {
+		derived = app.computeDerivedValues(device.ID, now)
+		derived.latest = measurement.Timestamp
 
-		devicesWithMeasurements = append(devicesWithMeasurements, deviceWithMeasurement)
+		app.derivedMutex.Lock()
+		app.derived[device.ID] = derived
+		app.derivedMutex.Unlock()
 	}
 
-	return devicesWithMeasurements, nil
+	return DeviceWithMeasurement{
+		Device:        device,
+		Measurement:   measurement,
+		Indices:       derived.indices,
+		CO2Forecast:   derived.co2Forecast.Current(now),
+		ScoreForecast: derived.scoreForecast.Current(now),
+	}
+}
+
+// computeDerivedValues computes the air quality indices and forecasts of a device
+func (app *App) computeDerivedValues(deviceID uint, now time.Time) derivedValues {
+	var derived derivedValues
+	var err error
+
+	db := globals.StatisticsDB()
+	derived.indices, err = airquality.ForDevice(db, deviceID, now)
+	if err != nil {
+		app.logger.Error("Failed to compute air quality indices", "device_id", deviceID, "error", err)
+	}
+
+	derived.co2Forecast, err = forecast.ForDevice(db, deviceID, "co2", now)
+	if err != nil {
+		app.logger.Error("Failed to forecast CO₂", "device_id", deviceID, "error", err)
+	}
+
+	derived.scoreForecast, err = forecast.ForDevice(db, deviceID, "score", now)
+	if err != nil {
+		app.logger.Error("Failed to forecast score", "device_id", deviceID, "error", err)
+	}
+
+	return derived
+}
+
+// forgetDerivedValues clears the values computed from the measurements of
+// all devices, they are computed again when needed
+func (app *App) forgetDerivedValues() {
+	app.derivedMutex.Lock()
+	defer app.derivedMutex.Unlock()
+
+	app.derived = make(map[uint]derivedValues)
 }
 
 // getSelectedDeviceForShellExtension returns the device that should be displayed in the shell extension
@@ -423,26 +674,133 @@ func (app *App) getSelectedDeviceForShellExtension() (*DeviceWithMeasurement, er
 	return &devices[0], nil
 }
 
+// selectedDeviceSerial returns the serial number of the device shown in the
+// shell extension, without loading the measurements of every device
+func (app *App) selectedDeviceSerial() (string, error) {
+	if serial := globals.Settings.StatusBarDeviceSerialNumber; serial != nil {
+		if _, err := app.deviceID(*serial); err == nil {
+			return *serial, nil
+		}
+	}
+
+	// Fall back to first device
+	var devices []models.Device
+	err := database.DB.Select("serial_number").Order("id").Limit(1).Find(&devices).Error
+	if err != nil || len(devices) == 0 {
+		return "", err
+	}
+	return devices[0].SerialNumber, nil
+}
+
 // updateShellExtensionIfNeeded updates the shell extension if the measurement is for the selected device
-func (app *App) updateShellExtensionIfNeeded(deviceSerial string) {
-	selectedDevice, err := app.getSelectedDeviceForShellExtension()
+func (app *App) updateShellExtensionIfNeeded(deviceData *DeviceWithMeasurement) {
+	selectedSerial, err := app.selectedDeviceSerial()
 	if err != nil {
 		app.logger.Error("Failed to get selected device for shell extension", "error", err)
 		return
 	}
 
-	if selectedDevice == nil {
-		app.logger.Debug("No device available for shell extension")
-		return
-	}
-
 	// Check if this measurement is for the selected device
-	if selectedDevice.Device.SerialNumber == deviceSerial {
-		app.logger.Debug("Updating shell extension with new measurement", "device_serial", deviceSerial)
+	if selectedSerial == deviceData.Device.SerialNumber {
+		app.logger.Debug("Updating shell extension with new measurement", "device_serial", selectedSerial)
 
 		// Trigger DBus signal to update shell extension
 		if app.dbusService != nil {
-			app.dbusService.EmitDeviceUpdated()
+			app.dbusService.emitDeviceUpdated(deviceData)
+		}
+	}
+}
+
+// syncDBusDevices updates the per-device DBus objects after devices were added or changed
+func (app *App) syncDBusDevices() {
+	if app.dbusService == nil {
+		return
+	}
+
+	if err := app.dbusService.SyncDevices(); err != nil {
+		app.logger.Error("Failed to sync DBus device objects", "error", err)
+	}
+}
+
+// applySettings saves the settings after the given keys were changed and
+// updates everything that depends on them
+func (app *App) applySettings(keys ...string) error {
+	if err := globals.Settings.Save(); err != nil {
+		return err
+	}
+
+	app.settingsUpdated(keys...)
+	return nil
+}
+
+// watchSettings applies settings changed by other processes, like the CLI
+func (app *App) watchSettings() {
+	err := globals.Settings.Store().Watch(func(settings *config.Settings) {
+		glib.IdleAdd(func() bool {
+			keys := globals.Settings.ChangedKeys(settings)
+			if len(keys) == 0 {
+				return false // Our own change or nothing relevant
+			}
+
+			app.logger.Info("Settings changed externally", "keys", keys)
+			globals.Settings.Update(settings)
+			app.settingsUpdated(keys...)
+			app.settingsPage.syncSettings(app)
+			return false // Don't repeat
+		})
+	}, func(err error) {
+		// Keep the current settings, they are written back on the next change
+		app.logger.Error("Ignoring invalid external settings change", "error", err)
+	})
+	if err != nil {
+		app.logger.Error("Failed to watch settings", "location", globals.Settings.Store().Location(), "error", err)
+	}
+}
+
+// settingsUpdated updates everything that depends on the given settings
+func (app *App) settingsUpdated(keys ...string) {
+	for _, key := range keys {
+		switch key {
+		case config.KeyShowShellExtension:
+			if app.dbusService != nil {
+				app.dbusService.EmitVisibilityChanged()
+			}
+		case config.KeyStatusBarDeviceSerialNumber:
+			if app.dbusService != nil {
+				app.dbusService.EmitDeviceUpdated()
+			}
+		case config.KeyDataRetentionPeriod:
+			app.cleanupOldMeasurements()
+		case config.KeyBackupInterval:
+			go app.backupIfDue()
+		case config.KeyWeeklyReport:
+			go app.sendWeeklyReportIfDue()
+		case config.KeyExcludeAnomalies:
+			// Indices and forecasts are computed from the measurements that are kept
+			app.forgetDerivedValues()
+			app.refreshDevicesFromDatabaseSafe()
+			app.syncDBusDevices()
+			if app.dbusService != nil {
+				app.dbusService.EmitDeviceUpdated()
+			}
+		case config.KeyCO2Threshold:
+			app.refreshDevicesFromDatabaseSafe()
+			app.syncDBusDevices()
+			if app.dbusService != nil {
+				app.dbusService.EmitDeviceUpdated()
+			}
+		case config.KeyTemperatureUnit, config.KeyVOCUnit:
+			app.refreshDevicesFromDatabaseSafe()
+			app.syncDBusDevices()
+			if app.dbusService != nil {
+				app.dbusService.EmitDeviceUpdated()
+			}
+		}
+	}
+
+	if app.dbusService != nil {
+		if err := app.dbusService.EmitSettingsChanged(keys...); err != nil {
+			app.logger.Error("Failed to emit settings changed signal", "error", err)
 		}
 	}
 }
@@ -451,8 +809,12 @@ func (app *App) updateShellExtensionIfNeeded(deviceSerial string) {
 func (app *App) startDataCleanup() {
 	app.logger.Info("Starting periodic data cleanup", "interval", "10 minutes")
 
-	// Run initial cleanup
+	// Run initial cleanup, backups, reports and health checks can take a
+	// while so they don't block startup
 	app.cleanupOldMeasurements()
+	go app.backupIfDue()
+	go app.sendWeeklyReportIfDue()
+	go app.checkDeviceHealth()
 
 	// Set up ticker for every 10 minutes
 	app.cleanupTicker = time.NewTicker(10 * time.Minute)
@@ -460,6 +822,9 @@ func (app *App) startDataCleanup() {
 	go func() {
 		for range app.cleanupTicker.C {
 			app.cleanupOldMeasurements()
+			app.backupIfDue()
+			app.sendWeeklyReportIfDue()
+			app.checkDeviceHealth()
 		}
 	}()
 }
@@ -480,27 +845,55 @@ func (app *App) cleanupOldMeasurements() {
 		return
 	}
 
-	cutoffTime := time.Now().AddDate(0, 0, -globals.Settings.DataRetentionPeriod)
+	// Measurements are stored in UTC
+	cutoffTime := time.Now().UTC().AddDate(0, 0, -globals.Settings.DataRetentionPeriod)
 
 	app.logger.Debug("Cleaning up old measurements",
 		"retention_days", globals.Settings.DataRetentionPeriod,
-		"cutoff_time", cutoffTime.Format("2006-01-02 15:04:05"))
+		"cutoff_time", cutoffTime.Local().Format("2006-01-02 15:04:05"))
 
-	result := database.DB.Where("timestamp < ?", cutoffTime).Delete(&models.Measurement{})
-	if result.Error != nil {
-		app.logger.Error("Failed to cleanup old measurements", "error", result.Error)
+	var deletedCount int64
+	err := database.Write(func(tx *gorm.DB) error {
+		result := tx.Where("timestamp < ?", cutoffTime).Delete(&models.Measurement{})
+		deletedCount = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		app.logger.Error("Failed to cleanup old measurements", "error", err)
 		return
 	}
 
-	if result.RowsAffected > 0 {
+	if deletedCount > 0 {
 		app.logger.Info("Cleaned up old measurements",
-			"deleted_count", result.RowsAffected,
-			"cutoff_time", cutoffTime.Format("2006-01-02 15:04:05"))
+			"deleted_count", deletedCount,
+			"cutoff_time", cutoffTime.Local().Format("2006-01-02 15:04:05"))
 	} else {
 		app.logger.Debug("No old measurements to cleanup")
 	}
 }
 
+// backupIfDue writes an automatic backup of the database when the last one
+// is older than the backup interval, keeping the configured number of backups
+func (app *App) backupIfDue() {
+	app.backupMutex.Lock()
+	defer app.backupMutex.Unlock()
+
+	if globals.Settings.BackupInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(globals.Settings.BackupInterval) * 24 * time.Hour
+	path, err := database.BackupIfDue(database.DB, config.BackupDir(), interval, globals.Settings.BackupCount, time.Now())
+	if err != nil {
+		app.logger.Error("Failed to back up database", "error", err)
+		return
+	}
+
+	if path != "" {
+		app.logger.Info("Backed up database", "path", path)
+	}
+}
+
 // setupCSS adds custom CSS styles for the application
 func (app *App) setupCSS() {
 	cssProvider := gtk.NewCSSProvider()
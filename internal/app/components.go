@@ -6,6 +6,8 @@ import (
 
 	"github.com/diamondburned/gotk4/pkg/cairo"
 	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
 )
 
 
@@ -18,14 +20,7 @@ func (app *App) createScoreCircle(score float64) *gtk.DrawingArea {
 		centerY := float64(height) / 2
 		radius := math.Min(float64(width), float64(height))/2 - 4
 
-		var r, g, b float64
-		if score < 30 {
-			r, g, b = 0.8, 0.2, 0.2
-		} else if score < 75 {
-			r, g, b = 0.9, 0.7, 0.1
-		} else {
-			r, g, b = 0.2, 0.7, 0.2
-		}
+		r, g, b := bandColor(stats.BandForScore(score))
 
 		cr.SetSourceRGB(r, g, b)
 		cr.Arc(centerX, centerY, radius, 0, 2*math.Pi)
@@ -44,9 +39,21 @@ func (app *App) createScoreCircle(score float64) *gtk.DrawingArea {
 	return area
 }
 
+// bandColor returns the color a quality band is drawn in
+func bandColor(band stats.Band) (r, g, b float64) {
+	switch band {
+	case stats.BandPoor:
+		return 0.8, 0.2, 0.2
+	case stats.BandModerate:
+		return 0.9, 0.7, 0.1
+	default:
+		return 0.2, 0.7, 0.2
+	}
+}
+
 func (app *App) formatValue(value float64, unit string) string {
 	if value == float64(int(value)) {
 		return fmt.Sprintf("%d %s", int(value), unit)
 	}
-	return fmt.Sprintf("%.1f %s", value, unit)
+	return fmt.Sprintf("%s %s", globals.Settings.UnitPreferences().FormatNumber(value, 1), unit)
 }
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ErrNotRunning is returned by the DBus client functions when no instance of the app is running
+var ErrNotRunning = errors.New("the app is not running")
+
+// callRunningInstance calls a method of the DBus service of a running instance
+func callRunningInstance(method string, args ...interface{}) error {
+	return callRunningInstanceWithResult(method, nil, args...)
+}
+
+// callRunningInstanceWithResult calls a method of the DBus service of a
+// running instance and stores what it returns in result
+func callRunningInstanceWithResult(method string, result []interface{}, args ...interface{}) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		// Without a session bus no instance can be reached
+		return fmt.Errorf("%w, failed to connect to session bus: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+
+	var running bool
+	err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, dbusName).Store(&running)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", dbusName, err)
+	}
+	if !running {
+		return ErrNotRunning
+	}
+
+	call := conn.Object(dbusName, dbus.ObjectPath(dbusPath)).Call(dbusInterface+"."+method, 0, args...)
+	if call.Err != nil || len(result) == 0 {
+		return call.Err
+	}
+	return call.Store(result...)
+}
+
+// SetSettingOnRunningInstance changes a setting through a running instance,
+// which saves it and updates the GUI and shell extension right away.
+// Returns ErrNotRunning when there is no running instance.
+func SetSettingOnRunningInstance(key string, value interface{}) error {
+	// DBus clients expect 32-bit integers
+	if number, ok := value.(int); ok {
+		value = int32(number)
+	}
+
+	return callRunningInstance("SetSetting", key, dbus.MakeVariant(value))
+}
+
+// RestoreDatabaseOnRunningInstance has a running instance restore the database
+// from a backup, so it doesn't keep writing measurements of devices by their
+// IDs in the replaced database. Returns the path of the backup of the replaced
+// database, or ErrNotRunning when there is no running instance.
+func RestoreDatabaseOnRunningInstance(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var snapshot string
+	err = callRunningInstanceWithResult("RestoreDatabase", []interface{}{&snapshot}, path)
+	return snapshot, err
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+)
+
+const (
+	dbusDevicesPath            = dbusPath + "/devices"
+	dbusDeviceInterface        = dbusInterface + ".Device"
+	dbusPropertiesInterface    = "org.freedesktop.DBus.Properties"
+	dbusIntrospectableIface    = "org.freedesktop.DBus.Introspectable"
+	dbusObjectManagerInterface = "org.freedesktop.DBus.ObjectManager"
+)
+
+// deviceObject is a device exported on the bus at its own object path
+type deviceObject struct {
+	path  dbus.ObjectPath
+	props *prop.Properties
+}
+
+// objectManagerIntrospectData describes the org.freedesktop.DBus.ObjectManager interface
+var objectManagerIntrospectData = introspect.Interface{
+	Name: dbusObjectManagerInterface,
+	Methods: []introspect.Method{
+		{
+			Name: "GetManagedObjects",
+			Args: []introspect.Arg{
+				{Name: "objects", Direction: "out", Type: "a{oa{sa{sv}}}"},
+			},
+		},
+	},
+	Signals: []introspect.Signal{
+		{
+			Name: "InterfacesAdded",
+			Args: []introspect.Arg{
+				{Name: "object_path", Type: "o"},
+				{Name: "interfaces_and_properties", Type: "a{sa{sv}}"},
+			},
+		},
+		{
+			Name: "InterfacesRemoved",
+			Args: []introspect.Arg{
+				{Name: "object_path", Type: "o"},
+				{Name: "interfaces", Type: "as"},
+			},
+		},
+	},
+}
+
+// deviceObjectPath returns the object path of a device. Characters that
+// aren't allowed in object paths are escaped as _XX hex sequences.
+func deviceObjectPath(serial string) dbus.ObjectPath {
+	var builder strings.Builder
+	for i := 0; i < len(serial); i++ {
+		c := serial[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "_%02x", c)
+		}
+	}
+
+	if builder.Len() == 0 {
+		builder.WriteString("_")
+	}
+
+	return dbus.ObjectPath(dbusDevicesPath + "/" + builder.String())
+}
+
+// deviceProperties returns the D-Bus properties of a device and its latest measurement
+func deviceProperties(deviceData *DeviceWithMeasurement) map[string]interface{} {
+	preferences := globals.Settings.UnitPreferences()
+	tags := deviceData.Device.TagNames()
+	ventilateIn, ventilationAdvice := ventilationForecast(deviceData, time.Now())
+
+	return map[string]interface{}{
+		"Name":       deviceData.Device.Name,
+		"Serial":     deviceData.Device.SerialNumber,
+		"Room":       deviceData.Device.Room,
+		"Tags":       tags,
+		"DeviceType": deviceData.Device.DeviceType,
+		"IPAddress":  deviceData.Device.IPAddress,
+		"Online":     deviceData.Device.IsOnline(time.Now()),
+		"LastSeen":   deviceData.Device.LastSeen.Unix(),
+
+		"Timestamp":   deviceData.Measurement.Timestamp.Unix(),
+		"Score":       deviceData.Measurement.Score,
+		"Temperature": preferences.Temperature.FromCelsius(deviceData.Measurement.Temperature),
+		"Humidity":    deviceData.Measurement.Humidity,
+		"CO2":         deviceData.Measurement.CO2,
+		"VOC":         preferences.VOC.FromPPB(deviceData.Measurement.VOC),
+		"PM25":        deviceData.Measurement.PM25,
+
+		"TemperatureUnit": preferences.Temperature.Symbol(),
+		"VOCUnit":         preferences.VOC.Symbol(),
+
+		"AQI":               indexValue(deviceData.Indices, deviceData.Indices.AQI),
+		"AQICategory":       deviceData.Indices.AQI.Name,
+		"CAQI":              indexValue(deviceData.Indices, deviceData.Indices.CAQI),
+		"CAQICategory":      deviceData.Indices.CAQI.Name,
+		"CO2Category":       deviceData.Indices.CO2.Name,
+		"VOCCategory":       deviceData.Indices.VOC.Name,
+		"DominantPollutant": string(deviceData.Indices.DominantPollutant),
+
+		"CO2Threshold":      int32(globals.Settings.CO2Threshold),
+		"VentilateIn":       ventilateIn,
+		"VentilationAdvice": ventilationAdvice,
+	}
+}
+
+// SyncDevices exports an object for every known device, updates the
+// properties of already exported devices and removes objects of devices
+// that no longer exist
+func (s *DBusService) SyncDevices() error {
+	devices, err := s.app.getDevicesWithMeasurements()
+	if err != nil {
+		return err
+	}
+
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	seen := make(map[string]bool, len(devices))
+	for i := range devices {
+		serial := devices[i].Device.SerialNumber
+		seen[serial] = true
+
+		if err := s.syncDevice(&devices[i]); err != nil {
+			s.app.logger.Error("Failed to export device on DBus", "serial", serial, "error", err)
+		}
+	}
+
+	for serial, object := range s.devices {
+		if !seen[serial] {
+			s.unexportDevice(serial, object)
+		}
+	}
+
+	return nil
+}
+
+// UpdateDevice refreshes the properties of a single device object, exporting it if needed
+func (s *DBusService) UpdateDevice(deviceData *DeviceWithMeasurement) error {
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	return s.syncDevice(deviceData)
+}
+
+// syncDevice exports or updates a device object. devicesMutex must be held.
+func (s *DBusService) syncDevice(deviceData *DeviceWithMeasurement) error {
+	serial := deviceData.Device.SerialNumber
+	values := deviceProperties(deviceData)
+
+	object, exists := s.devices[serial]
+	if !exists {
+		return s.exportDevice(serial, values)
+	}
+
+	for name, value := range values {
+		if !reflect.DeepEqual(object.props.GetMust(dbusDeviceInterface, name), value) {
+			object.props.SetMust(dbusDeviceInterface, name, value)
+		}
+	}
+
+	return nil
+}
+
+// exportDevice exports a new device object and announces it through the ObjectManager
+func (s *DBusService) exportDevice(serial string, values map[string]interface{}) error {
+	path := deviceObjectPath(serial)
+
+	properties := make(map[string]*prop.Prop, len(values))
+	for name, value := range values {
+		emit := prop.EmitTrue
+		if name == "Serial" {
+			emit = prop.EmitConst
+		}
+		properties[name] = &prop.Prop{Value: value, Emit: emit}
+	}
+
+	props, err := prop.Export(s.conn, path, prop.Map{dbusDeviceInterface: properties})
+	if err != nil {
+		return fmt.Errorf("failed to export properties: %w", err)
+	}
+
+	node := &introspect.Node{
+		Name: string(path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       dbusDeviceInterface,
+				Properties: props.Introspection(dbusDeviceInterface),
+			},
+		},
+	}
+
+	err = s.conn.Export(introspect.NewIntrospectable(node), path, dbusIntrospectableIface)
+	if err != nil {
+		s.conn.Export(nil, path, dbusPropertiesInterface)
+		return fmt.Errorf("failed to export introspection: %w", err)
+	}
+
+	object := &deviceObject{path: path, props: props}
+	s.devices[serial] = object
+
+	s.app.logger.Debug("Exported device on DBus", "serial", serial, "path", path)
+
+	return s.conn.Emit(dbus.ObjectPath(dbusPath), dbusObjectManagerInterface+".InterfacesAdded",
+		path, s.managedInterfaces(object))
+}
+
+// unexportDevice removes a device object and announces its removal. devicesMutex must be held.
+func (s *DBusService) unexportDevice(serial string, object *deviceObject) {
+	s.conn.Export(nil, object.path, dbusPropertiesInterface)
+	s.conn.Export(nil, object.path, dbusIntrospectableIface)
+	delete(s.devices, serial)
+
+	s.app.logger.Debug("Removed device from DBus", "serial", serial, "path", object.path)
+
+	err := s.conn.Emit(dbus.ObjectPath(dbusPath), dbusObjectManagerInterface+".InterfacesRemoved",
+		object.path, []string{dbusDeviceInterface, dbusPropertiesInterface, dbusIntrospectableIface})
+	if err != nil {
+		s.app.logger.Error("Failed to emit InterfacesRemoved", "serial", serial, "error", err)
+	}
+}
+
+// managedInterfaces returns the interfaces and properties of a device object as reported by the ObjectManager
+func (s *DBusService) managedInterfaces(object *deviceObject) map[string]map[string]dbus.Variant {
+	properties, _ := object.props.GetAll(dbusDeviceInterface)
+
+	return map[string]map[string]dbus.Variant{
+		dbusDeviceInterface:     properties,
+		dbusPropertiesInterface: {},
+		dbusIntrospectableIface: {},
+	}
+}
+
+// getManagedObjects implements org.freedesktop.DBus.ObjectManager.GetManagedObjects
+func (s *DBusService) getManagedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, *dbus.Error) {
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	objects := make(map[dbus.ObjectPath]map[string]map[string]dbus.Variant, len(s.devices))
+	for _, object := range s.devices {
+		objects[object.path] = s.managedInterfaces(object)
+	}
+
+	return objects, nil
+}
+
+// introspectDevices lists the exported device objects as children of the devices node
+func (s *DBusService) introspectDevices() (string, *dbus.Error) {
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	node := &introspect.Node{
+		Name:       dbusDevicesPath,
+		Interfaces: []introspect.Interface{introspect.IntrospectData},
+	}
+
+	names := make([]string, 0, len(s.devices))
+	for _, object := range s.devices {
+		names = append(names, strings.TrimPrefix(string(object.path), dbusDevicesPath+"/"))
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node.Children = append(node.Children, introspect.Node{Name: name})
+	}
+
+	return string(introspect.NewIntrospectable(node)), nil
+}
@@ -1,24 +1,46 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
 	"time"
 
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
 )
 
 const (
 	dbusName      = "io.stanko.AirMonitor"
 	dbusPath      = "/io/stanko/AirMonitor"
 	dbusInterface = "io.stanko.AirMonitor"
+
+	dbusErrorDeviceNotFound = dbusInterface + ".Error.DeviceNotFound"
+	dbusErrorInvalidArgs    = "org.freedesktop.DBus.Error.InvalidArgs"
 )
 
+// HistoryPoint is a single (timestamp, value) pair returned by GetHistory
+type HistoryPoint struct {
+	Timestamp int64
+	Value     float64
+}
+
 // DBusService handles DBUS communication for the air monitor
 type DBusService struct {
 	app  *App
 	conn *dbus.Conn
+
+	devices      map[string]*deviceObject // Exported device objects by serial number
+	devicesMutex sync.Mutex
 }
 
 // NewDBusService creates a new DBUS service for the app
@@ -29,8 +51,9 @@ func NewDBusService(app *App) (*DBusService, error) {
 	}
 
 	service := &DBusService{
-		app:  app,
-		conn: conn,
+		app:     app,
+		conn:    conn,
+		devices: make(map[string]*deviceObject),
 	}
 
 	// Export the service object
@@ -40,11 +63,32 @@ func NewDBusService(app *App) (*DBusService, error) {
 		return nil, fmt.Errorf("failed to export service: %w", err)
 	}
 
+	// Export the object manager for the per-device objects
+	err = conn.ExportMethodTable(map[string]interface{}{
+		"GetManagedObjects": service.getManagedObjects,
+	}, dbus.ObjectPath(dbusPath), dbusObjectManagerInterface)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to export object manager: %w", err)
+	}
+
+	err = conn.ExportMethodTable(map[string]interface{}{
+		"Introspect": service.introspectDevices,
+	}, dbus.ObjectPath(dbusDevicesPath), dbusIntrospectableIface)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to export devices introspection: %w", err)
+	}
+
 	// Export introspection data
 	node := &introspect.Node{
 		Name: dbusPath,
+		Children: []introspect.Node{
+			{Name: "devices"},
+		},
 		Interfaces: []introspect.Interface{
 			introspect.IntrospectData,
+			objectManagerIntrospectData,
 			{
 				Name: dbusInterface,
 				Methods: []introspect.Method{
@@ -54,6 +98,56 @@ func NewDBusService(app *App) (*DBusService, error) {
 							{Name: "device", Direction: "out", Type: "a{sv}"},
 						},
 					},
+					{
+						Name: "ListDevices",
+						Args: []introspect.Arg{
+							{Name: "devices", Direction: "out", Type: "aa{sv}"},
+						},
+					},
+					{
+						Name: "GetDevice",
+						Args: []introspect.Arg{
+							{Name: "serial", Direction: "in", Type: "s"},
+							{Name: "device", Direction: "out", Type: "a{sv}"},
+						},
+					},
+					{
+						Name: "GetHistory",
+						Args: []introspect.Arg{
+							{Name: "serial", Direction: "in", Type: "s"},
+							{Name: "metric", Direction: "in", Type: "s"},
+							{Name: "from", Direction: "in", Type: "x"},
+							{Name: "to", Direction: "in", Type: "x"},
+							{Name: "resolution", Direction: "in", Type: "u"},
+							{Name: "points", Direction: "out", Type: "a(xd)"},
+						},
+					},
+					{
+						Name: "SetSelectedDevice",
+						Args: []introspect.Arg{
+							{Name: "serial", Direction: "in", Type: "s"},
+						},
+					},
+					{
+						Name: "GetSettings",
+						Args: []introspect.Arg{
+							{Name: "settings", Direction: "out", Type: "a{sv}"},
+						},
+					},
+					{
+						Name: "SetSetting",
+						Args: []introspect.Arg{
+							{Name: "key", Direction: "in", Type: "s"},
+							{Name: "value", Direction: "in", Type: "v"},
+						},
+					},
+					{
+						Name: "RestoreDatabase",
+						Args: []introspect.Arg{
+							{Name: "path", Direction: "in", Type: "s"},
+							{Name: "snapshot", Direction: "out", Type: "s"},
+						},
+					},
 					{
 						Name: "OpenApp",
 					},
@@ -77,6 +171,19 @@ func NewDBusService(app *App) (*DBusService, error) {
 							{Name: "device", Type: "a{sv}"},
 						},
 					},
+					{
+						Name: "MeasurementStored",
+						Args: []introspect.Arg{
+							{Name: "serial", Type: "s"},
+							{Name: "device", Type: "a{sv}"},
+						},
+					},
+					{
+						Name: "SettingsChanged",
+						Args: []introspect.Arg{
+							{Name: "settings", Type: "a{sv}"},
+						},
+					},
 					{
 						Name: "VisibilityChanged",
 						Args: []introspect.Arg{
@@ -88,7 +195,7 @@ func NewDBusService(app *App) (*DBusService, error) {
 		},
 	}
 
-	err = conn.Export(introspect.NewIntrospectable(node), dbus.ObjectPath(dbusPath), "org.freedesktop.DBus.Introspectable")
+	err = conn.Export(introspect.NewIntrospectable(node), dbus.ObjectPath(dbusPath), dbusIntrospectableIface)
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to export introspection: %w", err)
@@ -122,16 +229,250 @@ func (s *DBusService) GetSelectedDevice() (map[string]dbus.Variant, *dbus.Error)
 		return map[string]dbus.Variant{}, nil
 	}
 
+	return devicePayload(selectedDevice), nil
+}
+
+// ListDevices returns all known devices with their latest measurements
+func (s *DBusService) ListDevices() ([]map[string]dbus.Variant, *dbus.Error) {
+	devices, err := s.app.getDevicesWithMeasurements()
+	if err != nil {
+		s.app.logger.Error("Failed to list devices for DBus", "error", err)
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	payloads := make([]map[string]dbus.Variant, 0, len(devices))
+	for i := range devices {
+		payloads = append(payloads, devicePayload(&devices[i]))
+	}
+
+	return payloads, nil
+}
+
+// GetDevice returns a device with its latest measurement by serial number
+func (s *DBusService) GetDevice(serial string) (map[string]dbus.Variant, *dbus.Error) {
+	device, dbusErr := s.findDevice(serial)
+	if dbusErr != nil {
+		return nil, dbusErr
+	}
+
+	return devicePayload(device), nil
+}
+
+// GetHistory returns the values of a metric of a device between two Unix
+// timestamps. A non-zero resolution, in seconds, averages the values into
+// buckets of that size. Values use the same units as the device dictionaries.
+func (s *DBusService) GetHistory(serial string, metric string, from int64, to int64, resolution uint32) ([]HistoryPoint, *dbus.Error) {
+	if _, ok := models.MeasurementMetrics[metric]; !ok {
+		return nil, dbus.NewError(dbusErrorInvalidArgs, []interface{}{fmt.Sprintf("unknown metric %q", metric)})
+	}
+
+	if to < from {
+		return nil, dbus.NewError(dbusErrorInvalidArgs, []interface{}{"from must not be after to"})
+	}
+
+	var device models.Device
+	err := database.DB.Where("serial_number = ?", serial).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, dbus.NewError(dbusErrorDeviceNotFound, []interface{}{fmt.Sprintf("no device with serial %q", serial)})
+	}
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	points, err := models.MeasurementHistory(database.DB, device.ID, metric,
+		time.Unix(from, 0), time.Unix(to, 0), time.Duration(resolution)*time.Second)
+	if err != nil {
+		s.app.logger.Error("Failed to get history for DBus", "serial", serial, "metric", metric, "error", err)
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	preferences := globals.Settings.UnitPreferences()
+	history := make([]HistoryPoint, 0, len(points))
+	for _, point := range points {
+		value := point.Value
+		switch metric {
+		case "temperature":
+			value = preferences.Temperature.FromCelsius(value)
+		case "voc":
+			value = preferences.VOC.FromPPB(value)
+		}
+
+		history = append(history, HistoryPoint{Timestamp: point.Timestamp.Unix(), Value: value})
+	}
+
+	return history, nil
+}
+
+// SetSelectedDevice changes the device shown in the status bar. An empty
+// serial clears the selection so the first device is shown.
+func (s *DBusService) SetSelectedDevice(serial string) *dbus.Error {
+	return s.SetSetting(config.KeyStatusBarDeviceSerialNumber, dbus.MakeVariant(serial))
+}
+
+// GetSettings returns all settings by key
+func (s *DBusService) GetSettings() (map[string]dbus.Variant, *dbus.Error) {
+	return settingsPayload(config.Keys...), nil
+}
+
+// SetSetting validates and changes a single setting, then updates the app
+// the same way as changing it on the settings page. The settings are only
+// touched on the main loop, the call waits for it to apply the change.
+func (s *DBusService) SetSetting(key string, value dbus.Variant) *dbus.Error {
+	if key == config.KeyStatusBarDeviceSerialNumber {
+		if serial, ok := value.Value().(string); ok && serial != "" {
+			if _, dbusErr := s.findDevice(serial); dbusErr != nil {
+				return dbusErr
+			}
+		}
+	}
+
+	result := make(chan *dbus.Error, 1)
+	glib.IdleAdd(func() bool {
+		result <- s.setSetting(key, value.Value())
+		return false // Don't repeat
+	})
+	return <-result
+}
+
+// setSetting changes a setting on a copy of the settings, which replaces them
+// once it's saved, so a setting that fails to save isn't applied either
+func (s *DBusService) setSetting(key string, value interface{}) *dbus.Error {
+	settings := *globals.Settings
+	if err := settings.Set(key, value); err != nil {
+		return dbus.NewError(dbusErrorInvalidArgs, []interface{}{err.Error()})
+	}
+
+	if err := settings.Save(); err != nil {
+		s.app.logger.Error("Failed to save settings", "error", err)
+		return dbus.MakeFailedError(err)
+	}
+
+	s.app.logger.Info("Setting changed over DBus", "key", key, "value", value)
+
+	globals.Settings.Update(&settings)
+	s.app.settingsUpdated(key)
+	s.app.settingsPage.syncSettings(s.app)
+	return nil
+}
+
+// RestoreDatabase replaces the database with a backup at an absolute path and
+// returns the path of the backup of the replaced database
+func (s *DBusService) RestoreDatabase(path string) (string, *dbus.Error) {
+	if !filepath.IsAbs(path) {
+		return "", dbus.NewError(dbusErrorInvalidArgs, []interface{}{"path must be absolute: " + path})
+	}
+
+	snapshot, err := s.app.restoreDatabase(path)
+	if err != nil {
+		s.app.logger.Error("Failed to restore database over DBus", "path", path, "error", err)
+		// Errors carry no other values, but the backup may have been made
+		if snapshot != "" {
+			err = fmt.Errorf("%w (the previous database was saved to %s)", err, snapshot)
+		}
+		return "", dbus.MakeFailedError(err)
+	}
+
+	return snapshot, nil
+}
+
+// settingsPayload converts settings into a DBus dictionary
+func settingsPayload(keys ...string) map[string]dbus.Variant {
+	payload := make(map[string]dbus.Variant, len(keys))
+	for _, key := range keys {
+		value, err := globals.Settings.Get(key)
+		if err != nil {
+			continue
+		}
+
+		// DBus clients expect 32-bit integers
+		if number, ok := value.(int); ok {
+			value = int32(number)
+		}
+
+		payload[key] = dbus.MakeVariant(value)
+	}
+
+	return payload
+}
+
+// findDevice loads a device by serial number, translating lookup failures into DBus errors
+func (s *DBusService) findDevice(serial string) (*DeviceWithMeasurement, *dbus.Error) {
+	device, err := s.app.getDeviceWithMeasurement(serial)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, dbus.NewError(dbusErrorDeviceNotFound, []interface{}{fmt.Sprintf("no device with serial %q", serial)})
+	}
+	if err != nil {
+		s.app.logger.Error("Failed to get device for DBus", "serial", serial, "error", err)
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	return device, nil
+}
+
+// devicePayload converts a device and its latest measurement into a DBus dictionary
+func devicePayload(deviceData *DeviceWithMeasurement) map[string]dbus.Variant {
+	tags := deviceData.Device.TagNames()
+	preferences := globals.Settings.UnitPreferences()
+	ventilateIn, ventilateAdvice := ventilationForecast(deviceData, time.Now())
+
 	return map[string]dbus.Variant{
-		"name":        dbus.MakeVariant(selectedDevice.Device.Name),
-		"score":       dbus.MakeVariant(selectedDevice.Measurement.Score),
-		"temperature": dbus.MakeVariant(selectedDevice.Measurement.Temperature),
-		"humidity":    dbus.MakeVariant(selectedDevice.Measurement.Humidity),
-		"co2":         dbus.MakeVariant(selectedDevice.Measurement.CO2),
-		"voc":         dbus.MakeVariant(selectedDevice.Measurement.VOC),
-		"pm25":        dbus.MakeVariant(selectedDevice.Measurement.PM25),
-		"timestamp":   dbus.MakeVariant(selectedDevice.Measurement.Timestamp.Unix()),
-	}, nil
+		"name":        dbus.MakeVariant(deviceData.Device.Name),
+		"serial":      dbus.MakeVariant(deviceData.Device.SerialNumber),
+		"room":        dbus.MakeVariant(deviceData.Device.Room),
+		"tags":        dbus.MakeVariant(tags),
+		"score":       dbus.MakeVariant(deviceData.Measurement.Score),
+		"temperature": dbus.MakeVariant(preferences.Temperature.FromCelsius(deviceData.Measurement.Temperature)),
+		"humidity":    dbus.MakeVariant(deviceData.Measurement.Humidity),
+		"co2":         dbus.MakeVariant(deviceData.Measurement.CO2),
+		"voc":         dbus.MakeVariant(preferences.VOC.FromPPB(deviceData.Measurement.VOC)),
+		"pm25":        dbus.MakeVariant(deviceData.Measurement.PM25),
+		"timestamp":   dbus.MakeVariant(deviceData.Measurement.Timestamp.Unix()),
+
+		"temperature_unit":  dbus.MakeVariant(preferences.Temperature.Symbol()),
+		"voc_unit":          dbus.MakeVariant(preferences.VOC.Symbol()),
+		"decimal_separator": dbus.MakeVariant(preferences.DecimalSeparator),
+
+		"aqi":                dbus.MakeVariant(indexValue(deviceData.Indices, deviceData.Indices.AQI)),
+		"aqi_category":       dbus.MakeVariant(deviceData.Indices.AQI.Name),
+		"caqi":               dbus.MakeVariant(indexValue(deviceData.Indices, deviceData.Indices.CAQI)),
+		"caqi_category":      dbus.MakeVariant(deviceData.Indices.CAQI.Name),
+		"co2_category":       dbus.MakeVariant(deviceData.Indices.CO2.Name),
+		"voc_category":       dbus.MakeVariant(deviceData.Indices.VOC.Name),
+		"dominant_pollutant": dbus.MakeVariant(string(deviceData.Indices.DominantPollutant)),
+
+		"co2_threshold":      dbus.MakeVariant(int32(globals.Settings.CO2Threshold)),
+		"ventilate_in":       dbus.MakeVariant(ventilateIn),
+		"ventilation_advice": dbus.MakeVariant(ventilateAdvice),
+	}
+}
+
+// indexValue returns the value of an index, -1 if there were no measurements
+// to compute the indices from. Their categories are empty then.
+func indexValue(indices airquality.Indices, level airquality.Level) int32 {
+	if !indices.Valid() {
+		return -1
+	}
+	return int32(level.Value)
+}
+
+// ventilationForecast returns in how many seconds CO₂ is forecast to reach
+// the configured threshold, -1 if it isn't expected to within the forecast
+// horizon, and advice like "Ventilate in ~15 min", empty if there is none
+func ventilationForecast(deviceData *DeviceWithMeasurement, now time.Time) (int64, string) {
+	until, ok := deviceData.CO2Forecast.Until(float64(globals.Settings.CO2Threshold), now)
+	if !ok {
+		return -1, ""
+	}
+
+	minutes := int(math.Round(until.Minutes()))
+	switch {
+	case minutes < 1:
+		return 0, "Ventilate now"
+	case minutes >= 10:
+		// The forecast isn't precise enough for single minutes that far ahead
+		minutes = int(math.Round(float64(minutes)/5) * 5)
+	}
+	return int64(until.Seconds()), fmt.Sprintf("Ventilate in ~%d min", minutes)
 }
 
 // OpenApp shows the main application window
@@ -178,20 +519,32 @@ func (s *DBusService) EmitDeviceUpdated() error {
 		return nil
 	}
 
-	deviceData := map[string]dbus.Variant{
-		"name":        dbus.MakeVariant(selectedDevice.Device.Name),
-		"score":       dbus.MakeVariant(selectedDevice.Measurement.Score),
-		"temperature": dbus.MakeVariant(selectedDevice.Measurement.Temperature),
-		"humidity":    dbus.MakeVariant(selectedDevice.Measurement.Humidity),
-		"co2":         dbus.MakeVariant(selectedDevice.Measurement.CO2),
-		"voc":         dbus.MakeVariant(selectedDevice.Measurement.VOC),
-		"pm25":        dbus.MakeVariant(selectedDevice.Measurement.PM25),
-		"timestamp":   dbus.MakeVariant(selectedDevice.Measurement.Timestamp.Unix()),
-	}
+	return s.emitDeviceUpdated(selectedDevice)
+}
+
+// emitDeviceUpdated sends a device update signal for an already loaded device
+func (s *DBusService) emitDeviceUpdated(selectedDevice *DeviceWithMeasurement) error {
+	deviceData := devicePayload(selectedDevice)
 
 	return s.conn.Emit(dbus.ObjectPath(dbusPath), dbusInterface+".DeviceUpdated", deviceData)
 }
 
+// EmitSettingsChanged sends a signal with the new values of the changed settings
+func (s *DBusService) EmitSettingsChanged(keys ...string) error {
+	return s.conn.Emit(dbus.ObjectPath(dbusPath), dbusInterface+".SettingsChanged", settingsPayload(keys...))
+}
+
+// EmitMeasurementStored sends a signal with the latest data of a device after a measurement was stored
+func (s *DBusService) EmitMeasurementStored(device *DeviceWithMeasurement) error {
+	serial := device.Device.SerialNumber
+
+	if err := s.UpdateDevice(device); err != nil {
+		s.app.logger.Error("Failed to update device object", "serial", serial, "error", err)
+	}
+
+	return s.conn.Emit(dbus.ObjectPath(dbusPath), dbusInterface+".MeasurementStored", serial, devicePayload(device))
+}
+
 // EmitVisibilityChanged sends a visibility change signal
 func (s *DBusService) EmitVisibilityChanged() error {
 	// Get visibility setting from the app's settings
@@ -210,6 +563,9 @@ func (s *DBusService) StartPeriodicUpdates() {
 			if err := s.EmitDeviceUpdated(); err != nil {
 				fmt.Printf("Failed to emit device update: %v\n", err)
 			}
+			if err := s.SyncDevices(); err != nil {
+				s.app.logger.Error("Failed to sync device objects", "error", err)
+			}
 		}
 	}()
 }
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"time"
+
+	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/cairo"
+	gdk "github.com/diamondburned/gotk4/pkg/gdk/v4"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/forecast"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// annotationColor is the color notes are marked with on the graph, the same as in reports
+var annotationColor = [3]float64{0.57, 0.25, 0.67}
+
+// annotationHitDistance is how far from a note, in pixels, the mouse can
+// be to point at it
+const annotationHitDistance = 6.0
+
+// annotationMaxMinutes is the longest a note added on the graph can last
+const annotationMaxMinutes = 24 * 60
+
+// loadAnnotations fetches the notes on the timeline of the device in the
+// time range of the loaded graph data, and ahead of it as far as a forecast
+// reaches when it includes the present
+func (dp *DevicePageState) loadAnnotations(app *App, graphState *GraphState) {
+	data := &graphState.data
+	to := data.to
+	if data.live {
+		to = to.Add(forecast.Horizon)
+	}
+
+	annotations, err := models.AnnotationsBetween(database.DB, []uint{data.deviceID}, data.from, to)
+	if err != nil {
+		app.logger.Error("Failed to fetch annotations for graph", "device_id", data.deviceID, "error", err)
+		return
+	}
+	data.annotations = annotations
+}
+
+// drawAnnotations marks the notes on the graph, points in time with a dashed
+// line and spans with a band, and labels them above the graph. The area
+// each note takes up above the graph is kept to find the note under the mouse.
+func (dp *DevicePageState) drawAnnotations(cr *cairo.Context, graphState *GraphState,
+	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime time.Time,
+) {
+	timeRange := endTime.Sub(startTime).Seconds()
+	xAt := func(at time.Time) float64 {
+		x := float64(marginLeft) + at.Sub(startTime).Seconds()/timeRange*float64(graphWidth)
+		return min(max(x, float64(marginLeft)), float64(marginLeft+graphWidth))
+	}
+
+	cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
+	cr.SetFontSize(10)
+
+	graphState.annotationAreas = make([][2]float64, len(graphState.annotations))
+	for i, annotation := range graphState.annotations {
+		fromX, toX := xAt(annotation.StartsAt), xAt(annotation.End())
+
+		if annotation.IsSpan() {
+			cr.SetSourceRGBA(annotationColor[0], annotationColor[1], annotationColor[2], 0.12)
+			cr.Rectangle(fromX, float64(marginTop), toX-fromX, float64(graphHeight))
+			cr.Fill()
+		} else {
+			cr.SetSourceRGBA(annotationColor[0], annotationColor[1], annotationColor[2], 0.8)
+			cr.SetLineWidth(1)
+			cr.SetDash([]float64{3, 3}, 0)
+			cr.MoveTo(fromX, float64(marginTop))
+			cr.LineTo(fromX, float64(marginTop+graphHeight))
+			cr.Stroke()
+			cr.SetDash(nil, 0)
+		}
+
+		// A flag above the graph where the note starts
+		cr.SetSourceRGB(annotationColor[0], annotationColor[1], annotationColor[2])
+		cr.MoveTo(fromX, float64(marginTop))
+		cr.LineTo(fromX-4, float64(marginTop-6))
+		cr.LineTo(fromX+4, float64(marginTop-6))
+		cr.ClosePath()
+		cr.Fill()
+
+		// The label runs up to the next note or the edge of the graph
+		labelEnd := float64(marginLeft + graphWidth)
+		if i+1 < len(graphState.annotations) {
+			labelEnd = xAt(graphState.annotations[i+1].StartsAt) - annotationHitDistance
+		}
+		label := ellipsize(cr, annotation.Text, labelEnd-fromX-6)
+		if label != "" {
+			cr.MoveTo(fromX+6, float64(marginTop-8))
+			cr.ShowText(label)
+		}
+
+		graphState.annotationAreas[i] = [2]float64{fromX - annotationHitDistance, max(toX, fromX+6+cr.TextExtents(label).XAdvance)}
+	}
+}
+
+// ellipsize shortens text with an ellipsis to fit a width in the current
+// font, returning nothing if not even the ellipsis fits
+func ellipsize(cr *cairo.Context, text string, width float64) string {
+	if cr.TextExtents(text).XAdvance <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if shortened := string(runes) + "…"; cr.TextExtents(shortened).XAdvance <= width {
+			return shortened
+		}
+	}
+	return ""
+}
+
+// annotationAt returns the index of the note above the graph at a position,
+// -1 if there is none
+func (graphState *GraphState) annotationAt(x, y float64, marginTop int) int {
+	if y < 0 || y > float64(marginTop)+annotationHitDistance {
+		return -1
+	}
+
+	// Later notes are drawn over earlier ones
+	for i := len(graphState.annotationAreas) - 1; i >= 0; i-- {
+		area := graphState.annotationAreas[i]
+		if x >= area[0] && x <= area[1]+annotationHitDistance && i < len(graphState.annotations) {
+			return i
+		}
+	}
+	return -1
+}
+
+// drawAnnotationHover draws a tooltip with the note under the mouse cursor
+func (dp *DevicePageState) drawAnnotationHover(cr *cairo.Context, graphState *GraphState, annotation models.Annotation,
+	marginLeft, marginTop, graphWidth int,
+) {
+	tooltipText := annotation.Label() + " - right-click to delete"
+	drawTooltipText(cr, tooltipText, graphState.hoverX, float64(marginTop)+20, float64(marginLeft+graphWidth))
+}
+
+// onGraphSecondaryClick offers to delete the note above the graph under the
+// mouse, or to add one at the clicked time
+func (dp *DevicePageState) onGraphSecondaryClick(app *App, graphState *GraphState, x, y float64) {
+	// Graph margins (should match drawGraph function)
+	marginTop := 20
+	marginLeft, graphWidth := graphState.plotArea()
+	if graphWidth <= 0 {
+		return
+	}
+
+	if index := graphState.annotationAt(x, y, marginTop); index >= 0 {
+		dp.showAnnotationDialog(app, graphState, graphState.annotations[index])
+		return
+	}
+
+	if x < float64(marginLeft) || x > float64(marginLeft+graphWidth) {
+		return
+	}
+
+	dp.showAddAnnotationDialog(app, graphState, graphState.timeAt(x).Truncate(time.Minute))
+}
+
+// showAddAnnotationDialog asks for the text of a note at a point in time and
+// how long it lasted
+func (dp *DevicePageState) showAddAnnotationDialog(app *App, graphState *GraphState, at time.Time) {
+	dialog := adw.NewMessageDialog(&app.mainWindow.Window, "Add Note",
+		"What happened at "+at.Local().Format("Mon Jan 2 15:04")+"? Notes are shown on the graph and listed in reports.")
+
+	textEntry := gtk.NewEntry()
+	textEntry.SetPlaceholderText("Window opened")
+
+	adjustment := gtk.NewAdjustment(0, 0, annotationMaxMinutes, 5, 30, 0)
+	durationSpin := gtk.NewSpinButton(adjustment, 1, 0)
+	durationSpin.SetTooltipText("0 for a point in time")
+
+	durationBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	durationLabel := gtk.NewLabel("Lasted")
+	durationBox.Append(durationLabel)
+	durationBox.Append(durationSpin)
+	unitLabel := gtk.NewLabel("minutes")
+	unitLabel.AddCSSClass("dim-label")
+	durationBox.Append(unitLabel)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 12)
+	content.Append(textEntry)
+	content.Append(durationBox)
+	dialog.SetExtraChild(content)
+
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("add", "Add")
+	dialog.SetResponseAppearance("add", adw.ResponseSuggested)
+	dialog.SetResponseEnabled("add", false)
+	dialog.SetDefaultResponse("add")
+	dialog.SetCloseResponse("cancel")
+
+	textEntry.ConnectChanged(func() {
+		dialog.SetResponseEnabled("add", models.NormalizeAnnotationText(textEntry.Text()) != "")
+	})
+	textEntry.ConnectActivate(func() {
+		if models.NormalizeAnnotationText(textEntry.Text()) != "" {
+			dialog.Response("add")
+		}
+	})
+
+	dialog.ConnectResponse(func(response string) {
+		text := textEntry.Text()
+		duration := time.Duration(durationSpin.Value()) * time.Minute
+		dialog.Destroy()
+		if response == "add" {
+			dp.addAnnotation(app, graphState, at, duration, text)
+		}
+	})
+
+	dialog.Present()
+}
+
+// showAnnotationDialog shows a note and offers to delete it
+func (dp *DevicePageState) showAnnotationDialog(app *App, graphState *GraphState, annotation models.Annotation) {
+	dialog := adw.NewMessageDialog(&app.mainWindow.Window, "Note", annotation.Label())
+	dialog.AddResponse("close", "Close")
+	dialog.AddResponse("delete", "Delete")
+	dialog.SetResponseAppearance("delete", adw.ResponseDestructive)
+	dialog.SetDefaultResponse("close")
+	dialog.SetCloseResponse("close")
+
+	dialog.ConnectResponse(func(response string) {
+		dialog.Destroy()
+		if response == "delete" {
+			dp.deleteAnnotation(app, graphState, annotation.ID)
+		}
+	})
+
+	dialog.Present()
+}
+
+// addAnnotation adds a note to the timeline of the device shown by the
+// graph, over a span if it has a duration
+func (dp *DevicePageState) addAnnotation(app *App, graphState *GraphState, at time.Time, duration time.Duration, text string) {
+	annotation := models.Annotation{
+		DeviceID: graphState.device.Device.ID,
+		StartsAt: at.UTC(),
+		Text:     models.NormalizeAnnotationText(text),
+	}
+	if duration > 0 {
+		end := annotation.StartsAt.Add(duration)
+		annotation.EndsAt = &end
+	}
+	app.logger.Info("Adding annotation", "device_id", annotation.DeviceID, "starts_at", annotation.StartsAt, "duration", duration)
+
+	err := database.Write(func(tx *gorm.DB) error {
+		return tx.Create(&annotation).Error
+	})
+	if err != nil {
+		app.logger.Error("Failed to add annotation", "device_id", annotation.DeviceID, "error", err)
+	}
+
+	dp.loadAnnotations(app, graphState)
+	graphState.drawingArea.QueueDraw()
+}
+
+// deleteAnnotation deletes a note and removes it from the graph
+func (dp *DevicePageState) deleteAnnotation(app *App, graphState *GraphState, id uint) {
+	app.logger.Info("Deleting annotation", "id", id)
+
+	err := database.Write(func(tx *gorm.DB) error {
+		return tx.Delete(&models.Annotation{}, id).Error
+	})
+	if err != nil {
+		app.logger.Error("Failed to delete annotation", "id", id, "error", err)
+	}
+
+	dp.loadAnnotations(app, graphState)
+	graphState.drawingArea.QueueDraw()
+}
+
+// addAnnotationController lets notes be added and deleted by right-clicking the graph
+func (dp *DevicePageState) addAnnotationController(app *App, graphState *GraphState) {
+	gesture := gtk.NewGestureClick()
+	gesture.SetButton(gdk.BUTTON_SECONDARY)
+	gesture.ConnectPressed(func(nPress int, x, y float64) {
+		dp.onGraphSecondaryClick(app, graphState, x, y)
+	})
+	graphState.drawingArea.AddController(gesture)
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/comfort"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+// ComfortState holds the comfort group of the device page
+type ComfortState struct {
+	mouldRow   *adw.ActionRow
+	mouldLabel *gtk.Label
+	device     models.Device
+	serial     string
+}
+
+// addComfortGroup creates the group with the comfort indices of the latest
+// measurement and the risk of mould in the device's room
+func (dp *DevicePageState) addComfortGroup(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement, widgets *deviceWidgets) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle("Comfort")
+	group.SetDescription("Computed from temperature and humidity")
+
+	metrics := []struct {
+		name     string
+		subtitle string
+		metric   MetricType
+	}{
+		{"Dew Point", "Surfaces colder than this get wet", MetricDewPoint},
+		{"Heat Index", "How hot it feels, US NWS", MetricHeatIndex},
+		{"Humidex", "How hot it feels, Environment Canada", MetricHumidex},
+		{"Absolute Humidity", "Water vapour in the air", MetricAbsoluteHumidity},
+	}
+
+	for _, metric := range metrics {
+		row := adw.NewActionRow()
+		row.SetTitle(metric.name)
+		row.SetSubtitle(metric.subtitle)
+		row.AddCSSClass("padded-row")
+
+		valueLabel := gtk.NewLabel(app.formatMetric(deviceData.Measurement, metric.metric))
+		valueLabel.AddCSSClass("numeric")
+		row.AddSuffix(valueLabel)
+		widgets.metricLabels[metric.metric] = valueLabel
+
+		group.Add(row)
+	}
+
+	state := &ComfortState{
+		mouldRow:   adw.NewActionRow(),
+		mouldLabel: gtk.NewLabel("…"),
+		device:     deviceData.Device,
+		serial:     deviceData.Device.SerialNumber,
+	}
+	state.mouldRow.SetTitle("Mould Risk")
+	state.mouldRow.AddCSSClass("padded-row")
+	state.mouldLabel.AddCSSClass("numeric")
+	state.mouldRow.AddSuffix(state.mouldLabel)
+	group.Add(state.mouldRow)
+	dp.currentComfort = state
+
+	container.Append(group)
+
+	dp.loadMouldRisk(app, state)
+}
+
+// loadMouldRisk judges the risk of mould in the background
+func (dp *DevicePageState) loadMouldRisk(app *App, state *ComfortState) {
+	go func() {
+		risk, err := comfort.MouldRiskForDevice(globals.StatisticsDB(), state.device, time.Now())
+
+		glib.IdleAdd(func() bool {
+			// The page may have moved on to another device or been rebuilt
+			if dp.currentComfort != state || dp.currentDeviceSerial != state.serial {
+				return false
+			}
+
+			if err != nil {
+				app.logger.Error("Failed to judge mould risk", "device_id", state.device.ID, "error", err)
+				state.mouldLabel.SetText("Unknown")
+				state.mouldRow.SetSubtitle("Failed to read the measurements")
+				return false
+			}
+
+			state.mouldLabel.SetText(risk.Level.Name)
+			state.mouldRow.SetSubtitle(mouldRiskSubtitle(risk))
+			return false
+		})
+	}()
+}
+
+// mouldRiskSubtitle explains a mould risk
+func mouldRiskSubtitle(risk comfort.MouldRisk) string {
+	if reasons := risk.Reasons(); len(reasons) > 0 {
+		return strings.Join(reasons, " · ")
+	}
+	if risk.SurfaceHumidity == nil {
+		return "Humidity over the last 24 hours, set the coldest surface temperature under Location to check for condensation"
+	}
+	return "Humidity over the last 24 hours and at the coldest surface"
+}
@@ -0,0 +1,339 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/cairo"
+	gdk "github.com/diamondburned/gotk4/pkg/gdk/v4"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// graphMaxHistory is how far back from now the graph can be moved
+const graphMaxHistory = 7 * 24 * time.Hour
+
+// graphData holds the measurements and notes of the graph's device over a
+// time range around the one the graph shows, so that the graph can be
+// redrawn, moved and zoomed without querying the database every time
+type graphData struct {
+	deviceID     uint
+	from         time.Time // Start of the range the data was loaded for, in UTC
+	to           time.Time // End of the range the data was loaded for, in UTC
+	live         bool      // Whether the range reaches the present, newer measurements are added as they arrive
+	measurements []models.Measurement
+	annotations  []models.Annotation
+}
+
+// covers reports whether the data of a device over a time range is loaded
+func (data graphData) covers(deviceID uint, from, to time.Time) bool {
+	if data.deviceID != deviceID || data.from.IsZero() {
+		return false
+	}
+	return !from.Before(data.from) && (data.live || !to.After(data.to))
+}
+
+// measurementsBetween returns the loaded measurements in a time range, oldest first
+func (data graphData) measurementsBetween(from, to time.Time) []models.Measurement {
+	start := sort.Search(len(data.measurements), func(i int) bool {
+		return !data.measurements[i].Timestamp.Before(from)
+	})
+	end := sort.Search(len(data.measurements), func(i int) bool {
+		return data.measurements[i].Timestamp.After(to)
+	})
+	return data.measurements[start:max(start, end)]
+}
+
+// annotationsBetween returns the loaded notes that overlap a time range
+func (data graphData) annotationsBetween(from, to time.Time) []models.Annotation {
+	var annotations []models.Annotation
+	for _, annotation := range data.annotations {
+		if !annotation.StartsAt.After(to) && !annotation.End().Before(from) {
+			annotations = append(annotations, annotation)
+		}
+	}
+	return annotations
+}
+
+// graphSelection is a range of time selected on the graph
+type graphSelection struct {
+	from time.Time
+	to   time.Time
+}
+
+// loadGraphData loads the measurements and notes of the time range the
+// graph shows unless they already are. Half a time window more is loaded on
+// each side, so the graph can be moved a bit before loading again.
+func (dp *DevicePageState) loadGraphData(app *App, graphState *GraphState) {
+	startTime, _, now := graphState.timeRange()
+	deviceID := graphState.device.Device.ID
+	if graphState.data.covers(deviceID, startTime, now) {
+		return
+	}
+
+	margin := graphState.timeWindow / 2
+	data := graphData{
+		deviceID: deviceID,
+		from:     startTime.Add(-margin),
+		to:       now.Add(margin),
+	}
+	if present := time.Now().UTC(); !data.to.Before(present) {
+		data.to = present
+		data.live = true
+	}
+
+	err := database.DB.Where("device_id = ? AND timestamp BETWEEN ? AND ?", deviceID, data.from, data.to).
+		Order("timestamp ASC").
+		Find(&data.measurements).Error
+	if err != nil {
+		app.logger.Error("Failed to fetch measurements for graph", "device_id", deviceID, "error", err)
+		return
+	}
+
+	graphState.data = data
+	dp.loadAnnotations(app, graphState)
+}
+
+// loadNewMeasurements adds the measurements stored since the graph data was
+// loaded, when it reaches the present
+func (dp *DevicePageState) loadNewMeasurements(app *App, graphState *GraphState) {
+	data := &graphState.data
+	if !data.live || data.deviceID != graphState.device.Device.ID {
+		return
+	}
+
+	// Measurements are written in batches, so ones older than the end of
+	// the loaded range can still arrive
+	after := data.from
+	if len(data.measurements) > 0 {
+		after = data.measurements[len(data.measurements)-1].Timestamp
+	}
+	present := time.Now().UTC()
+
+	var measurements []models.Measurement
+	err := database.DB.Where("device_id = ? AND timestamp > ? AND timestamp <= ?", data.deviceID, after, present).
+		Order("timestamp ASC").
+		Find(&measurements).Error
+	if err != nil {
+		app.logger.Error("Failed to fetch new measurements for graph", "device_id", data.deviceID, "error", err)
+		return
+	}
+
+	data.measurements = append(data.measurements, measurements...)
+	data.to = present
+	dp.loadAnnotations(app, graphState)
+}
+
+// setTimeView shows a time window ending offset from now on the graph,
+// limited to what the graph can show
+func (dp *DevicePageState) setTimeView(app *App, graphState *GraphState, window, offset time.Duration) {
+	window = min(max(window, GRAPH_MIN_WINDOW), GRAPH_MAX_WINDOW)
+
+	// Don't allow going into the future or too far back
+	offset = min(max(offset, -graphMaxHistory), 0)
+
+	graphState.timeWindow = window
+	graphState.timeOffset = offset
+
+	// Highlight the window button matching the zoom, if any
+	for duration, button := range graphState.windowButtons {
+		if duration == window {
+			button.AddCSSClass("suggested-action")
+		} else {
+			button.RemoveCSSClass("suggested-action")
+		}
+	}
+
+	if graphState.timeLabel != nil {
+		graphState.timeLabel.SetText(dp.getTimeWindowLabel(offset, window))
+	}
+
+	dp.loadGraphData(app, graphState)
+
+	// The mouse is over another measurement after moving the graph
+	if graphState.hoverX >= 0 {
+		graphState.hoveredPoint = dp.findClosestPoint(app, graphState, graphState.hoverX, graphState.hoverY)
+	}
+
+	graphState.drawingArea.QueueDraw()
+}
+
+// zoomGraph zooms from a time window and offset by a factor, larger than
+// one to zoom out, keeping the time at a horizontal position in place. While
+// the graph shows the latest measurements it keeps showing them.
+func (dp *DevicePageState) zoomGraph(app *App, graphState *GraphState, window, offset time.Duration, factor, x float64) {
+	zoomed := min(max(time.Duration(float64(window)*factor), GRAPH_MIN_WINDOW), GRAPH_MAX_WINDOW)
+	if offset == 0 {
+		dp.setTimeView(app, graphState, zoomed, 0)
+		return
+	}
+
+	marginLeft, graphWidth := graphState.plotArea()
+	if graphWidth <= 0 {
+		return
+	}
+
+	// Without a forecast the graph ends at the offset, the time at x is
+	// the same fraction of the window from the end before and after
+	fromEnd := 1 - min(max((x-float64(marginLeft))/float64(graphWidth), 0), 1)
+	offset += time.Duration(fromEnd * float64(zoomed-window))
+	dp.setTimeView(app, graphState, zoomed, offset)
+}
+
+// plotArea returns the left edge and width of the plot on the drawing area,
+// matching the margins of drawGraph
+func (graphState *GraphState) plotArea() (marginLeft, graphWidth int) {
+	marginLeft, marginRight := 60, 20
+	return marginLeft, graphState.drawingArea.Allocation().Width() - marginLeft - marginRight
+}
+
+// timeAt returns the time at a horizontal position on the graph, limited to
+// the time range it shows
+func (graphState *GraphState) timeAt(x float64) time.Time {
+	startTime, endTime, _ := graphState.timeRange()
+	marginLeft, graphWidth := graphState.plotArea()
+	if graphWidth <= 0 {
+		return startTime
+	}
+
+	fraction := min(max((x-float64(marginLeft))/float64(graphWidth), 0), 1)
+	return startTime.Add(time.Duration(fraction * float64(endTime.Sub(startTime))))
+}
+
+// addGraphGestures lets the graph be zoomed by scrolling or pinching, moved
+// by dragging or scrolling sideways, and a range of it selected by dragging
+// with Shift held
+func (dp *DevicePageState) addGraphGestures(app *App, graphState *GraphState) {
+	scroll := gtk.NewEventControllerScroll(gtk.EventControllerScrollBothAxes)
+	scroll.ConnectScroll(func(dx, dy float64) bool {
+		// Touchpads scroll by pixels rather than steps of a wheel
+		if scroll.Unit() == gdk.ScrollUnitSurface {
+			dx, dy = dx/GRAPH_SCROLL_STEP_PIXELS, dy/GRAPH_SCROLL_STEP_PIXELS
+		}
+
+		if dy != 0 {
+			dp.zoomGraph(app, graphState, graphState.timeWindow, graphState.timeOffset, math.Pow(GRAPH_ZOOM_STEP, dy), graphState.hoverX)
+		}
+		if dx != 0 {
+			step := time.Duration(dx * float64(graphState.timeWindow) / 10)
+			dp.setTimeView(app, graphState, graphState.timeWindow, graphState.timeOffset+step)
+		}
+		return true
+	})
+	graphState.drawingArea.AddController(scroll)
+
+	// The view when a drag or pinch started, which they move and zoom from
+	var startWindow, startOffset time.Duration
+	var startSpan time.Duration
+	var startX float64
+	var selecting bool
+
+	drag := gtk.NewGestureDrag()
+	drag.ConnectDragBegin(func(x, y float64) {
+		startWindow, startOffset, startX = graphState.timeWindow, graphState.timeOffset, x
+		startTime, endTime, _ := graphState.timeRange()
+		startSpan = endTime.Sub(startTime)
+		selecting = drag.CurrentEventState()&gdk.ShiftMask != 0
+	})
+	drag.ConnectDragUpdate(func(offsetX, offsetY float64) {
+		if selecting {
+			from, to := graphState.timeAt(startX), graphState.timeAt(startX+offsetX)
+			if to.Before(from) {
+				from, to = to, from
+			}
+			graphState.selection = &graphSelection{from: from, to: to}
+			graphState.drawingArea.QueueDraw()
+			return
+		}
+
+		_, graphWidth := graphState.plotArea()
+		if graphWidth <= 0 {
+			return
+		}
+		// Dragging to the right brings earlier measurements into view
+		moved := time.Duration(offsetX / float64(graphWidth) * float64(startSpan))
+		dp.setTimeView(app, graphState, startWindow, startOffset-moved)
+	})
+	drag.ConnectDragEnd(func(offsetX, offsetY float64) {
+		// A click without dragging clears the selection
+		if math.Abs(offsetX) < 3 && math.Abs(offsetY) < 3 && graphState.selection != nil {
+			graphState.selection = nil
+			graphState.drawingArea.QueueDraw()
+		}
+	})
+	graphState.drawingArea.AddController(drag)
+
+	var pinchX float64
+	pinch := gtk.NewGestureZoom()
+	pinch.ConnectBegin(func(sequence *gdk.EventSequence) {
+		startWindow, startOffset = graphState.timeWindow, graphState.timeOffset
+		pinchX = graphState.hoverX
+		if x, _, ok := pinch.BoundingBoxCenter(); ok {
+			pinchX = x
+		}
+	})
+	pinch.ConnectScaleChanged(func(scale float64) {
+		if scale > 0 {
+			dp.zoomGraph(app, graphState, startWindow, startOffset, 1/scale, pinchX)
+		}
+	})
+	graphState.drawingArea.AddController(pinch)
+}
+
+// drawSelection shades the selected range of the graph and shows the
+// statistics of the selected metric over it
+func (dp *DevicePageState) drawSelection(app *App, cr *cairo.Context, graphState *GraphState,
+	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime time.Time, metricInfo MetricInfo,
+	preferences units.Preferences,
+) {
+	selection := graphState.selection
+	if selection.to.Before(startTime) || selection.from.After(endTime) {
+		return
+	}
+
+	timeRange := endTime.Sub(startTime).Seconds()
+	xAt := func(at time.Time) float64 {
+		x := float64(marginLeft) + at.Sub(startTime).Seconds()/timeRange*float64(graphWidth)
+		return min(max(x, float64(marginLeft)), float64(marginLeft+graphWidth))
+	}
+	fromX, toX := xAt(selection.from), xAt(selection.to)
+
+	cr.SetSourceRGBA(0.21, 0.52, 0.89, 0.15)
+	cr.Rectangle(fromX, float64(marginTop), toX-fromX, float64(graphHeight))
+	cr.Fill()
+
+	cr.SetSourceRGBA(0.21, 0.52, 0.89, 0.8)
+	cr.SetLineWidth(1)
+	for _, x := range []float64{fromX, toX} {
+		cr.MoveTo(x, float64(marginTop))
+		cr.LineTo(x, float64(marginTop+graphHeight))
+	}
+	cr.Stroke()
+
+	layout := "15:04"
+	if selection.to.Sub(selection.from) >= 24*time.Hour || selection.from.Local().YearDay() != selection.to.Local().YearDay() {
+		layout = "Jan 2 15:04"
+	}
+	span := fmt.Sprintf("%s - %s (%s)", selection.from.Local().Format(layout), selection.to.Local().Format(layout),
+		stats.FormatDuration(selection.to.Sub(selection.from)))
+
+	measurements := graphState.data.measurementsBetween(selection.from, selection.to)
+	values := make([]float64, len(measurements))
+	for i, measurement := range measurements {
+		values[i] = metricValue(measurement, graphState.selectedMetric, preferences)
+	}
+
+	text := span + ": no data"
+	if len(values) > 0 {
+		summary := stats.Summarize(values)
+		text = fmt.Sprintf("%s: average %s, min %s, max %s", span, app.formatValue(math.Round(summary.Mean*10)/10, metricInfo.Unit),
+			app.formatValue(summary.Min, metricInfo.Unit), app.formatValue(summary.Max, metricInfo.Unit))
+	}
+
+	drawTooltipText(cr, text, (fromX+toX)/2, float64(marginTop)+40, float64(marginLeft+graphWidth))
+}
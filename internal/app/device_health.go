@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	gio "github.com/diamondburned/gotk4/pkg/gio/v2"
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/anomaly"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// HealthState holds the sensor health group of the device page
+type HealthState struct {
+	group    *adw.PreferencesGroup
+	rows     []gtk.Widgetter // Rows showing the current warnings
+	deviceID uint
+	serial   string
+}
+
+// checkDeviceHealth flags anomalies in the recent measurements of every
+// device, and sends a notification when a device starts to look faulty
+func (app *App) checkDeviceHealth() {
+	app.healthMutex.Lock()
+	defer app.healthMutex.Unlock()
+
+	var devices []models.Device
+	if err := database.DB.Order("id").Find(&devices).Error; err != nil {
+		app.logger.Error("Failed to load devices for the health check", "error", err)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-anomaly.HealthPeriod)
+
+	for _, device := range devices {
+		var changed int
+		err := database.Write(func(tx *gorm.DB) error {
+			var err error
+			changed, err = anomaly.FlagDevice(tx, device.ID, from, to)
+			return err
+		})
+		if err != nil {
+			app.logger.Error("Failed to flag anomalies", "device_id", device.ID, "error", err)
+			continue
+		}
+		if changed > 0 {
+			app.logger.Info("Flagged anomalies", "device_id", device.ID, "changed", changed)
+		}
+
+		health, err := anomaly.CheckDevice(database.DB, device.ID, from, to)
+		if err != nil {
+			app.logger.Error("Failed to check device health", "device_id", device.ID, "error", err)
+			continue
+		}
+
+		faulty := health.Faulty()
+		if faulty == app.faultyDevices[device.ID] {
+			continue
+		}
+		app.faultyDevices[device.ID] = faulty
+
+		device := device
+		warnings := health.Warnings()
+		glib.IdleAdd(func() bool {
+			id := "device-health-" + device.SerialNumber
+			if !faulty {
+				app.WithdrawNotification(id)
+				return false
+			}
+
+			app.logger.Warn("Device looks faulty", "device_id", device.ID, "warnings", warnings)
+
+			notification := gio.NewNotification(device.Name + " may be faulty")
+			notification.SetBody(warnings[0])
+			notification.SetPriority(gio.NotificationPriorityHigh)
+			app.SendNotification(id, notification)
+			return false
+		})
+	}
+}
+
+// addHealthGroup creates the group listing problems with the device's recent measurements
+func (dp *DevicePageState) addHealthGroup(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle("Sensor Health")
+
+	state := &HealthState{
+		group:    group,
+		deviceID: deviceData.Device.ID,
+		serial:   deviceData.Device.SerialNumber,
+	}
+	dp.currentHealth = state
+
+	container.Append(group)
+
+	dp.loadHealth(app, state)
+}
+
+// loadHealth checks the measurements in the background
+func (dp *DevicePageState) loadHealth(app *App, state *HealthState) {
+	period := fmt.Sprintf("Last %.0f hours", anomaly.HealthPeriod.Hours())
+	state.group.SetDescription(period)
+
+	go func() {
+		to := time.Now()
+		health, err := anomaly.CheckDevice(database.DB, state.deviceID, to.Add(-anomaly.HealthPeriod), to)
+
+		glib.IdleAdd(func() bool {
+			// The page may have moved on to another device or been rebuilt
+			if dp.currentHealth != state || dp.currentDeviceSerial != state.serial {
+				return false
+			}
+
+			if err != nil {
+				app.logger.Error("Failed to check device health", "device_id", state.deviceID, "error", err)
+				state.group.SetDescription("Failed to check the measurements")
+				state.setRows(nil)
+				return false
+			}
+
+			state.group.SetDescription(fmt.Sprintf("%s, %d measurements", period, health.Count))
+			state.setRows(healthRows(health))
+			return false
+		})
+	}()
+}
+
+// setRows replaces the rows of the group
+func (state *HealthState) setRows(rows []gtk.Widgetter) {
+	for _, row := range state.rows {
+		state.group.Remove(row)
+	}
+
+	state.rows = rows
+	for _, row := range rows {
+		state.group.Add(row)
+	}
+}
+
+// healthRows creates a row per warning, or one saying all is well
+func healthRows(health anomaly.Health) []gtk.Widgetter {
+	warnings := health.Warnings()
+	if len(warnings) == 0 {
+		row := adw.NewActionRow()
+		row.SetTitle("No problems found")
+		row.AddPrefix(gtk.NewImageFromIconName("emblem-ok-symbolic"))
+		row.AddCSSClass("padded-row")
+		return []gtk.Widgetter{row}
+	}
+
+	rows := make([]gtk.Widgetter, 0, len(warnings))
+	for _, warning := range warnings {
+		row := adw.NewActionRow()
+		row.SetTitle(warning)
+		row.AddPrefix(gtk.NewImageFromIconName("dialog-warning-symbolic"))
+		row.AddCSSClass("padded-row")
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,322 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/cairo"
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/report"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+)
+
+// heatmapPeriods are the numbers of days the heatmap can cover
+var heatmapPeriods = []struct {
+	name string
+	days int
+}{
+	{"7 days", 7},
+	{"14 days", 14},
+	{"28 days", 28},
+}
+
+// defaultHeatmapPeriod is the index of the period shown until another is picked
+const defaultHeatmapPeriod = 0
+
+// Layout of the heatmap
+const (
+	heatmapMarginLeft   = 72
+	heatmapMarginRight  = 20
+	heatmapMarginTop    = 24
+	heatmapRowHeight    = 18
+	heatmapLegendHeight = 40
+	heatmapLegendWidth  = 200
+)
+
+// HeatmapState holds the heatmap group of the device page
+type HeatmapState struct {
+	group       *adw.PreferencesGroup
+	drawingArea *gtk.DrawingArea
+	heatmap     stats.Heatmap
+	loaded      bool
+	hoverDay    int // Row of the hovered cell, -1 if none
+	hoverHour   int // Column of the hovered cell
+	deviceID    uint
+	serial      string
+}
+
+// addHeatmap creates the group showing the average of a metric per day and
+// hour, which makes recurring patterns like stuffy afternoons stand out
+func (dp *DevicePageState) addHeatmap(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle("Heatmap")
+
+	state := &HeatmapState{
+		group:    group,
+		hoverDay: -1,
+		deviceID: deviceData.Device.ID,
+		serial:   deviceData.Device.SerialNumber,
+	}
+	dp.currentHeatmap = state
+
+	metricNames := make([]string, len(stats.Metrics))
+	for i, metric := range stats.Metrics {
+		metricNames[i] = metric.DisplayName()
+	}
+
+	metricDropdown := gtk.NewDropDownFromStrings(metricNames)
+	metricDropdown.SetVAlign(gtk.AlignCenter)
+	metricDropdown.SetSelected(uint(dp.heatmapMetricIndex()))
+	metricDropdown.Connect("notify::selected", func() {
+		dp.heatmapMetric = stats.Metrics[metricDropdown.Selected()]
+		dp.loadHeatmap(app, state)
+	})
+
+	periodNames := make([]string, len(heatmapPeriods))
+	for i, period := range heatmapPeriods {
+		periodNames[i] = period.name
+	}
+
+	periodDropdown := gtk.NewDropDownFromStrings(periodNames)
+	periodDropdown.SetVAlign(gtk.AlignCenter)
+	periodDropdown.SetSelected(uint(dp.heatmapPeriodIndex()))
+	periodDropdown.Connect("notify::selected", func() {
+		dp.heatmapDays = heatmapPeriods[periodDropdown.Selected()].days
+		dp.loadHeatmap(app, state)
+	})
+
+	controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	controls.Append(metricDropdown)
+	controls.Append(periodDropdown)
+	group.SetHeaderSuffix(controls)
+
+	state.drawingArea = gtk.NewDrawingArea()
+	state.drawingArea.SetHExpand(true)
+	state.drawingArea.SetDrawFunc(func(area *gtk.DrawingArea, cr *cairo.Context, width, height int) {
+		state.draw(cr, width, height)
+	})
+
+	motionController := gtk.NewEventControllerMotion()
+	motionController.ConnectMotion(func(x, y float64) {
+		state.hover(x, y)
+	})
+	motionController.ConnectLeave(func() {
+		state.hoverDay = -1
+		state.drawingArea.QueueDraw()
+	})
+	state.drawingArea.AddController(motionController)
+
+	group.Add(state.drawingArea)
+	container.Append(group)
+
+	dp.loadHeatmap(app, state)
+}
+
+// heatmapMetricIndex returns the index of the metric the heatmap shows
+func (dp *DevicePageState) heatmapMetricIndex() int {
+	for i, metric := range stats.Metrics {
+		if metric == dp.heatmapMetric {
+			return i
+		}
+	}
+	return 0
+}
+
+// heatmapPeriodIndex returns the index of the selected heatmap period
+func (dp *DevicePageState) heatmapPeriodIndex() int {
+	for i, period := range heatmapPeriods {
+		if period.days == dp.heatmapDays {
+			return i
+		}
+	}
+	return defaultHeatmapPeriod
+}
+
+// loadHeatmap aggregates the measurements into hours in the background
+func (dp *DevicePageState) loadHeatmap(app *App, state *HeatmapState) {
+	metric := stats.Metrics[dp.heatmapMetricIndex()]
+	period := heatmapPeriods[dp.heatmapPeriodIndex()]
+	state.group.SetDescription(fmt.Sprintf("Loading the last %s…", period.name))
+	state.drawingArea.SetContentHeight(heatmapMarginTop + period.days*heatmapRowHeight + heatmapLegendHeight)
+
+	go func() {
+		heatmap, err := stats.HeatmapForDevice(globals.StatisticsDB(), state.deviceID, metric, period.days, time.Now())
+
+		glib.IdleAdd(func() bool {
+			// The page may have moved on to another device or been rebuilt
+			if dp.currentHeatmap != state || dp.currentDeviceSerial != state.serial {
+				return false
+			}
+
+			if err != nil {
+				app.logger.Error("Failed to compute heatmap", "device_id", state.deviceID, "error", err)
+				state.group.SetDescription("Failed to compute the heatmap")
+				state.loaded = false
+				state.drawingArea.QueueDraw()
+				return false
+			}
+
+			state.group.SetDescription(fmt.Sprintf("Average %s per hour over the last %s", metric.DisplayName(), period.name))
+			state.heatmap = heatmap
+			state.loaded = true
+			state.drawingArea.QueueDraw()
+			return false
+		})
+	}()
+}
+
+// cellWidth returns the width of an hour in the heatmap
+func (state *HeatmapState) cellWidth(width int) float64 {
+	return float64(width-heatmapMarginLeft-heatmapMarginRight) / 24
+}
+
+// hover finds the cell under the mouse cursor
+func (state *HeatmapState) hover(x, y float64) {
+	cellWidth := state.cellWidth(state.drawingArea.Width())
+	day := int((y - heatmapMarginTop) / heatmapRowHeight)
+	hour := int((x - heatmapMarginLeft) / cellWidth)
+
+	if x < heatmapMarginLeft || y < heatmapMarginTop || day >= len(state.heatmap.Days) || hour >= 24 {
+		day = -1
+	}
+
+	if day != state.hoverDay || hour != state.hoverHour {
+		state.hoverDay, state.hoverHour = day, hour
+		state.drawingArea.QueueDraw()
+	}
+}
+
+// draw renders the heatmap with day labels on the left, hours on top and
+// a legend below
+func (state *HeatmapState) draw(cr *cairo.Context, width, height int) {
+	cr.SetSourceRGB(1, 1, 1)
+	cr.Paint()
+
+	cellWidth := state.cellWidth(width)
+	if cellWidth <= 0 || !state.loaded {
+		return
+	}
+
+	heatmap := state.heatmap
+	if !heatmap.HasData() {
+		cr.SetSourceRGB(0.5, 0.5, 0.5)
+		cr.MoveTo(float64(width/2-50), float64(height/2))
+		cr.ShowText("No data available")
+		return
+	}
+
+	cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
+	cr.SetFontSize(10)
+
+	// Hours of the day
+	cr.SetSourceRGB(0.3, 0.3, 0.3)
+	for hour := 0; hour < 24; hour += 3 {
+		cr.MoveTo(heatmapMarginLeft+float64(hour)*cellWidth+2, heatmapMarginTop-8)
+		cr.ShowText(fmt.Sprintf("%02d:00", hour))
+	}
+
+	for i, day := range heatmap.Days {
+		y := float64(heatmapMarginTop + i*heatmapRowHeight)
+
+		cr.SetSourceRGB(0.3, 0.3, 0.3)
+		cr.MoveTo(5, y+heatmapRowHeight-5)
+		cr.ShowText(day.Format("Mon 2 Jan"))
+
+		for hour, cell := range heatmap.Cells[i] {
+			if cell.Count == 0 {
+				cr.SetSourceRGB(0.95, 0.95, 0.95)
+			} else {
+				r, g, b := heatmapColor(heatmap, cell.Value)
+				cr.SetSourceRGB(r, g, b)
+			}
+			cr.Rectangle(heatmapMarginLeft+float64(hour)*cellWidth, y, cellWidth-1, heatmapRowHeight-1)
+			cr.Fill()
+		}
+	}
+
+	state.drawLegend(cr, float64(heatmapMarginTop+len(heatmap.Days)*heatmapRowHeight))
+
+	if state.hoverDay >= 0 && state.hoverDay < len(heatmap.Days) {
+		cell := heatmap.Cells[state.hoverDay][state.hoverHour]
+		value := "no data"
+		if cell.Count > 0 {
+			value = report.FormatValue(heatmap.Metric, cell.Value, globals.Settings.UnitPreferences())
+		}
+
+		x := heatmapMarginLeft + (float64(state.hoverHour)+0.5)*cellWidth
+		y := float64(heatmapMarginTop + state.hoverDay*heatmapRowHeight)
+		text := fmt.Sprintf("%s, %s - %s", heatmap.Days[state.hoverDay].Format("Mon 2 Jan"), report.HourLabel(state.hoverHour), value)
+		drawTooltipText(cr, text, x, y, float64(width))
+	}
+}
+
+// drawLegend draws the colors from the lowest to the highest value below the grid
+func (state *HeatmapState) drawLegend(cr *cairo.Context, top float64) {
+	heatmap := state.heatmap
+	low, high := heatmap.Min, heatmap.Max
+	if heatmap.Metric == stats.MetricScore {
+		low, high = 0, 100
+	}
+
+	y := top + 12
+	steps := 50
+	for i := 0; i < steps; i++ {
+		r, g, b := heatmapColor(heatmap, low+(high-low)*float64(i)/float64(steps-1))
+		cr.SetSourceRGB(r, g, b)
+		cr.Rectangle(heatmapMarginLeft+float64(i)*heatmapLegendWidth/float64(steps), y, heatmapLegendWidth/float64(steps)+0.5, 8)
+		cr.Fill()
+	}
+
+	preferences := globals.Settings.UnitPreferences()
+	cr.SetSourceRGB(0.3, 0.3, 0.3)
+	cr.MoveTo(heatmapMarginLeft, y+20)
+	cr.ShowText(report.FormatValue(heatmap.Metric, low, preferences))
+
+	highLabel := report.FormatValue(heatmap.Metric, high, preferences)
+	extents := cr.TextExtents(highLabel)
+	cr.MoveTo(heatmapMarginLeft+heatmapLegendWidth-extents.Width, y+20)
+	cr.ShowText(highLabel)
+}
+
+// heatmapColor returns the color of a value in the heatmap. Scores go from
+// the color of poor to that of good air, like the score circle. Other
+// metrics go from white to the color of their graph, darker is higher.
+func heatmapColor(heatmap stats.Heatmap, value float64) (r, g, b float64) {
+	if heatmap.Metric == stats.MetricScore {
+		poorR, poorG, poorB := bandColor(stats.BandPoor)
+		moderateR, moderateG, moderateB := bandColor(stats.BandModerate)
+		goodR, goodG, goodB := bandColor(stats.BandGood)
+		middle := float64(stats.PoorScoreBelow+stats.ModerateScoreBelow) / 2
+
+		switch {
+		case value <= stats.PoorScoreBelow:
+			return poorR, poorG, poorB
+		case value < middle:
+			t := (value - stats.PoorScoreBelow) / (middle - stats.PoorScoreBelow)
+			return mix(poorR, moderateR, t), mix(poorG, moderateG, t), mix(poorB, moderateB, t)
+		case value < stats.ModerateScoreBelow:
+			t := (value - middle) / (stats.ModerateScoreBelow - middle)
+			return mix(moderateR, goodR, t), mix(moderateG, goodG, t), mix(moderateB, goodB, t)
+		default:
+			return goodR, goodG, goodB
+		}
+	}
+
+	t := 1.0
+	if heatmap.Max > heatmap.Min {
+		t = (value - heatmap.Min) / (heatmap.Max - heatmap.Min)
+	}
+
+	// Start a little above white so the lowest values stay visible
+	t = 0.15 + 0.85*t
+	color := getMetricInfo(globals.Settings.UnitPreferences())[statisticsMetricTypes[heatmap.Metric]].Color
+	return mix(1, color[0], t), mix(1, color[1], t), mix(1, color[2], t)
+}
+
+// mix interpolates between two color components
+func mix(from, to, t float64) float64 {
+	return from + (to-from)*t
+}
@@ -2,13 +2,20 @@ package app
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
 )
 
 // IndexPageState holds all state related to the device index page
 type IndexPageState struct {
-	listBox *gtk.ListBox
+	listBox    *gtk.ListBox
+	devices    []DeviceWithMeasurement    // Devices currently listed
+	deviceRows map[string]*gtk.ListBoxRow // Device rows by serial number
+	roomRows   map[string]*gtk.ListBoxRow // Room header rows by room
 }
 
 
@@ -38,6 +45,9 @@ func (ip *IndexPageState) populate(app *App) {
 	for ip.listBox.FirstChild() != nil {
 		ip.listBox.Remove(ip.listBox.FirstChild())
 	}
+	ip.devices = nil
+	ip.deviceRows = make(map[string]*gtk.ListBoxRow)
+	ip.roomRows = make(map[string]*gtk.ListBoxRow)
 
 	// Fetch devices from database
 	devices, err := app.getDevicesWithMeasurements()
@@ -93,10 +103,156 @@ func (ip *IndexPageState) populate(app *App) {
 		return
 	}
 
+	ip.devices = devices
+
+	groups := groupDevicesByRoom(devices)
+	for _, group := range groups {
+		// Only show room headers once at least one device has a room
+		if len(groups) > 1 || group.room != "" {
+			roomRow := ip.createRoomRow(devices, group)
+			ip.roomRows[group.room] = roomRow
+			ip.listBox.Append(roomRow)
+		}
+
+		for _, i := range group.indices {
+			row := ip.createDeviceRow(app, devices[i], i)
+			ip.deviceRows[devices[i].Device.SerialNumber] = row
+			ip.listBox.Append(row)
+		}
+	}
+}
+
+// updateDevice shows new data of a single device without reloading the
+// others. Unknown devices and room changes rebuild the whole list.
+func (ip *IndexPageState) updateDevice(app *App, deviceData DeviceWithMeasurement) {
+	if ip.listBox == nil {
+		return
+	}
+
+	serial := deviceData.Device.SerialNumber
+	row, exists := ip.deviceRows[serial]
+	if !exists {
+		ip.populate(app)
+		return
+	}
+
+	for i := range ip.devices {
+		if ip.devices[i].Device.SerialNumber != serial {
+			continue
+		}
+
+		if ip.devices[i].Device.Room != deviceData.Device.Room {
+			ip.populate(app)
+			return
+		}
+
+		ip.devices[i] = deviceData
+		row.SetChild(ip.createDeviceRowContent(app, deviceData))
+		break
+	}
+
+	// Update the averages in the header of the device's room
+	roomRow, exists := ip.roomRows[deviceData.Device.Room]
+	if !exists {
+		return
+	}
+	for _, group := range groupDevicesByRoom(ip.devices) {
+		if group.room == deviceData.Device.Room {
+			roomRow.SetChild(ip.createRoomHeader(ip.devices, group))
+			break
+		}
+	}
+}
+
+// roomGroup holds the indices of all devices located in the same room
+type roomGroup struct {
+	room    string
+	indices []int
+}
+
+// groupDevicesByRoom groups devices by room, sorted by room name with
+// devices without a room last. Indices point into the given slice.
+func groupDevicesByRoom(devices []DeviceWithMeasurement) []roomGroup {
+	groupsByRoom := make(map[string]*roomGroup)
+	var groups []*roomGroup
+
 	for i, deviceData := range devices {
-		row := ip.createDeviceRow(app, deviceData, i)
-		ip.listBox.Append(row)
+		room := deviceData.Device.Room
+		group, exists := groupsByRoom[room]
+		if !exists {
+			group = &roomGroup{room: room}
+			groupsByRoom[room] = group
+			groups = append(groups, group)
+		}
+		group.indices = append(group.indices, i)
 	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].room == "" || groups[j].room == "" {
+			return groups[j].room == "" && groups[i].room != ""
+		}
+		return groups[i].room < groups[j].room
+	})
+
+	result := make([]roomGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	return result
+}
+
+// createRoomRow creates a non-interactive header row summarizing a room
+func (ip *IndexPageState) createRoomRow(devices []DeviceWithMeasurement, group roomGroup) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+	row.SetActivatable(false)
+	row.SetSelectable(false)
+	row.SetChild(ip.createRoomHeader(devices, group))
+	return row
+}
+
+// createRoomHeader creates the room name and average readings shown in a room header row
+func (ip *IndexPageState) createRoomHeader(devices []DeviceWithMeasurement, group roomGroup) *gtk.Box {
+	headerBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	headerBox.SetMarginTop(8)
+	headerBox.SetMarginBottom(8)
+	headerBox.SetMarginStart(16)
+	headerBox.SetMarginEnd(16)
+
+	title := group.room
+	if title == "" {
+		title = "No room"
+	}
+
+	titleLabel := gtk.NewLabel(title)
+	titleLabel.SetHAlign(gtk.AlignStart)
+	titleLabel.SetHExpand(true)
+	titleLabel.AddCSSClass("heading")
+	headerBox.Append(titleLabel)
+
+	measurements := make([]models.Measurement, 0, len(group.indices))
+	for _, i := range group.indices {
+		measurements = append(measurements, devices[i].Measurement)
+	}
+	average := models.AverageMeasurements(measurements)
+
+	preferences := globals.Settings.UnitPreferences()
+	summary := fmt.Sprintf("%d devices · Avg. score %.0f · %s %s · %.0f ppm CO₂",
+		len(group.indices),
+		average.Score,
+		preferences.FormatNumber(preferences.Temperature.FromCelsius(average.Temperature), 1),
+		preferences.Temperature.Symbol(),
+		average.CO2,
+	)
+	if len(group.indices) == 1 {
+		summary = fmt.Sprintf("1 device · Score %.0f", average.Score)
+	}
+
+	summaryLabel := gtk.NewLabel(summary)
+	summaryLabel.AddCSSClass("dim-label")
+	summaryLabel.AddCSSClass("caption")
+	headerBox.Append(summaryLabel)
+
+	return headerBox
 }
 
 func (ip *IndexPageState) refresh(app *App) {
@@ -117,7 +273,19 @@ func (ip *IndexPageState) show(app *App) {
 func (ip *IndexPageState) createDeviceRow(app *App, deviceData DeviceWithMeasurement, index int) *gtk.ListBoxRow {
 	row := gtk.NewListBoxRow()
 	row.SetActivatable(true)
+	row.SetChild(ip.createDeviceRowContent(app, deviceData))
+
+	gesture := gtk.NewGestureClick()
+	gesture.ConnectPressed(func(nPress int, x, y float64) {
+		app.devicePage.show(app, index)
+	})
+	row.AddController(gesture)
 
+	return row
+}
+
+// createDeviceRowContent creates the score and readings shown in a device row
+func (ip *IndexPageState) createDeviceRowContent(app *App, deviceData DeviceWithMeasurement) *gtk.Box {
 	mainBox := gtk.NewBox(gtk.OrientationHorizontal, 16)
 	mainBox.SetMarginTop(12)
 	mainBox.SetMarginBottom(12)
@@ -137,22 +305,30 @@ func (ip *IndexPageState) createDeviceRow(app *App, deviceData DeviceWithMeasure
 	deviceNameLabel.AddCSSClass("heading")
 	textBox.Append(deviceNameLabel)
 
-	roomLabel := gtk.NewLabel(fmt.Sprintf("Score: %.0f", deviceData.Measurement.Score))
+	aqi, co2, worst := "—", "—", "—"
+	if deviceData.Indices.Valid() {
+		aqi = fmt.Sprint(deviceData.Indices.AQI.Value)
+		co2 = strings.TrimPrefix(deviceData.Indices.CO2.Name, "Category ")
+		worst = deviceData.Indices.DominantPollutant.DisplayName()
+	}
+	roomLabel := gtk.NewLabel(fmt.Sprintf("Score: %.0f · AQI %s · CO₂ %s · Worst: %s",
+		deviceData.Measurement.Score, aqi, co2, worst))
 	roomLabel.SetHAlign(gtk.AlignStart)
 	roomLabel.SetXAlign(0)
 	roomLabel.AddCSSClass("dim-label")
 	textBox.Append(roomLabel)
 
-	mainBox.Append(textBox)
-	row.SetChild(mainBox)
+	if tags := deviceData.Device.TagNames(); len(tags) > 0 {
+		tagsLabel := gtk.NewLabel(strings.Join(tags, ", "))
+		tagsLabel.SetHAlign(gtk.AlignStart)
+		tagsLabel.SetXAlign(0)
+		tagsLabel.AddCSSClass("dim-label")
+		tagsLabel.AddCSSClass("caption")
+		textBox.Append(tagsLabel)
+	}
 
+	mainBox.Append(textBox)
 	mainBox.SetObjectProperty("cursor", "pointer")
 
-	gesture := gtk.NewGestureClick()
-	gesture.ConnectPressed(func(nPress int, x, y float64) {
-		app.devicePage.show(app, index)
-	})
-	row.AddController(gesture)
-
-	return row
+	return mainBox
 }
\ No newline at end of file
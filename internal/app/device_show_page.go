@@ -3,6 +3,8 @@ package app
 import (
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
@@ -10,8 +12,16 @@ import (
 	gdk "github.com/diamondburned/gotk4/pkg/gdk/v4"
 	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
 	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/anomaly"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/comfort"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/forecast"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+	"gorm.io/gorm"
 )
 
 // MetricType represents different measurement types for graphing
@@ -24,6 +34,10 @@ const (
 	MetricVOC
 	MetricPM25
 	MetricScore
+	MetricDewPoint
+	MetricHeatIndex
+	MetricHumidex
+	MetricAbsoluteHumidity
 )
 
 // GraphState holds the current state of the graph
@@ -40,15 +54,41 @@ type GraphState struct {
 	hoverX         float64 // X coordinate of mouse hover (-1 if not hovering)
 	hoverY         float64 // Y coordinate of mouse hover
 	hoveredPoint   int     // Index of hovered measurement point (-1 if none)
+
+	data            graphData           // Measurements and notes around the time range, see loadGraphData
+	selection       *graphSelection     // Range selected by dragging with Shift held, nil if none
+	annotations     []models.Annotation // Notes in the time range of the graph, as last drawn
+	annotationAreas [][2]float64        // Horizontal extent of each note above the graph, as last drawn
 }
 
 // DevicePageState holds all state related to the device detail page
 type DevicePageState struct {
 	currentDeviceSerial   string              // Serial number of currently shown device, empty if none
 	isEditingDeviceName   bool                // Flag to prevent UI refresh while editing device name
+	isEditingLocation     bool                // Flag to prevent UI refresh while editing room or tags
 	currentGraphState     *GraphState         // State of the current device graph
 	currentScrollPosition float64             // Scroll position of current device page
 	currentDeviceScrolled *gtk.ScrolledWindow // Reused scrolled window to maintain scroll position
+	currentWidgets        *deviceWidgets      // Widgets showing the readings of the current device
+	currentStatistics     *StatisticsState    // Statistics group of the current device
+	statisticsPeriod      time.Duration       // Period the statistics summarize, zero for the default
+	currentHeatmap        *HeatmapState       // Heatmap of the current device
+	currentHealth         *HealthState        // Sensor health group of the current device
+	currentVentilation    *VentilationState   // Ventilation group of the current device
+	currentComfort        *ComfortState       // Comfort group of the current device
+	heatmapMetric         stats.Metric        // Metric the heatmap shows, empty for the score
+	heatmapDays           int                 // Days the heatmap covers, zero for the default
+}
+
+// deviceWidgets holds the widgets of the device page that show readings,
+// so they can be updated in place when a new measurement arrives
+type deviceWidgets struct {
+	header        *gtk.Box
+	scoreCircle   *gtk.DrawingArea
+	scoreLabel    *gtk.Label
+	metricLabels  map[MetricType]*gtk.Label
+	indexLabels   []*gtk.Label
+	lastSeenLabel *gtk.Label
 }
 
 // showDevicePage displays the device detail page
@@ -102,11 +142,15 @@ func (dp *DevicePageState) show(app *App, deviceIndex int) {
 	contentBox.SetMarginStart(24)
 	contentBox.SetMarginEnd(24)
 
+	widgets := &deviceWidgets{metricLabels: make(map[MetricType]*gtk.Label)}
+
 	deviceHeader := gtk.NewBox(gtk.OrientationHorizontal, 16)
 	deviceHeader.SetHAlign(gtk.AlignCenter)
+	widgets.header = deviceHeader
 
 	scoreCircle := app.createScoreCircle(deviceData.Measurement.Score)
 	deviceHeader.Append(scoreCircle)
+	widgets.scoreCircle = scoreCircle
 
 	headerTextBox := gtk.NewBox(gtk.OrientationVertical, 4)
 	headerTextBox.SetVAlign(gtk.AlignCenter)
@@ -117,6 +161,7 @@ func (dp *DevicePageState) show(app *App, deviceIndex int) {
 	scoreLabel := gtk.NewLabel(fmt.Sprintf("Air Quality Score: %.0f", deviceData.Measurement.Score))
 	scoreLabel.AddCSSClass("subtitle")
 	headerTextBox.Append(scoreLabel)
+	widgets.scoreLabel = scoreLabel
 
 	deviceHeader.Append(headerTextBox)
 	contentBox.Append(deviceHeader)
@@ -125,15 +170,14 @@ func (dp *DevicePageState) show(app *App, deviceIndex int) {
 	metricsGroup.SetTitle("Current Measurements")
 
 	metrics := []struct {
-		name  string
-		value float64
-		unit  string
+		name   string
+		metric MetricType
 	}{
-		{"Temperature", deviceData.Measurement.Temperature, "°C"},
-		{"Humidity", deviceData.Measurement.Humidity, "%"},
-		{"CO₂", deviceData.Measurement.CO2, "ppm"},
-		{"VOC", deviceData.Measurement.VOC, "ppb"},
-		{"PM2.5", deviceData.Measurement.PM25, "μg/m³"},
+		{"Temperature", MetricTemperature},
+		{"Humidity", MetricHumidity},
+		{"CO₂", MetricCO2},
+		{"VOC", MetricVOC},
+		{"PM2.5", MetricPM25},
 	}
 
 	for _, metric := range metrics {
@@ -141,19 +185,69 @@ func (dp *DevicePageState) show(app *App, deviceIndex int) {
 		row.SetTitle(metric.name)
 		row.AddCSSClass("padded-row")
 
-		valueText := app.formatValue(metric.value, metric.unit)
-		valueLabel := gtk.NewLabel(valueText)
+		valueLabel := gtk.NewLabel(app.formatMetric(deviceData.Measurement, metric.metric))
 		valueLabel.AddCSSClass("numeric")
 		row.AddSuffix(valueLabel)
+		widgets.metricLabels[metric.metric] = valueLabel
 
 		metricsGroup.Add(row)
 	}
 
 	contentBox.Append(metricsGroup)
 
+	indicesGroup := adw.NewPreferencesGroup()
+	indicesGroup.SetTitle("Air Quality Indices")
+	indicesGroup.SetDescription("Computed from stored measurements")
+
+	indexValues := formatIndices(deviceData.Indices)
+	indices := []struct {
+		name     string
+		subtitle string
+	}{
+		{"US AQI", "EPA, PM2.5 24 hour mean"},
+		{"EU CAQI", "PM2.5 1 hour mean"},
+		{"CO₂ Ventilation", "EN 16798-1"},
+		{"VOC", "TVOC 1 hour mean"},
+		{"Dominant Pollutant", "Worst relative to its own scale"},
+	}
+
+	for i, index := range indices {
+		row := adw.NewActionRow()
+		row.SetTitle(index.name)
+		row.SetSubtitle(index.subtitle)
+		row.AddCSSClass("padded-row")
+
+		valueLabel := gtk.NewLabel(indexValues[i])
+		valueLabel.AddCSSClass("numeric")
+		row.AddSuffix(valueLabel)
+		widgets.indexLabels = append(widgets.indexLabels, valueLabel)
+
+		indicesGroup.Add(row)
+	}
+
+	contentBox.Append(indicesGroup)
+
+	// Add how the air feels and the risk of mould
+	dp.addComfortGroup(app, contentBox, &deviceData, widgets)
+
+	// Add problems with the sensor's recent measurements
+	dp.addHealthGroup(app, contentBox, &deviceData)
+
 	// Add 24-hour graph with navigation
 	dp.addGraph(app, contentBox, &deviceData)
 
+	// Add statistics over the last days
+	dp.addStatisticsGroup(app, contentBox, &deviceData)
+
+	// Add the heatmap of the last weeks
+	dp.addHeatmap(app, contentBox, &deviceData)
+
+	// Add the ventilation and occupancy estimated from CO₂
+	dp.addVentilationGroup(app, contentBox, &deviceData)
+
+	// Add room and tag editing
+	dp.addLocationGroup(app, contentBox, &deviceData)
+
 	deviceInfoGroup := adw.NewPreferencesGroup()
 	deviceInfoGroup.SetTitle("Device Information")
 
@@ -175,12 +269,16 @@ func (dp *DevicePageState) show(app *App, deviceIndex int) {
 		valueLabel := gtk.NewLabel(item.value)
 		valueLabel.AddCSSClass("dim-label")
 		row.AddSuffix(valueLabel)
+		if item.title == "Last Seen" {
+			widgets.lastSeenLabel = valueLabel
+		}
 
 		deviceInfoGroup.Add(row)
 	}
 
 	contentBox.Append(deviceInfoGroup)
 	scrolled.SetChild(contentBox)
+	dp.currentWidgets = widgets
 
 	pageName := fmt.Sprintf("device-%d", deviceIndex)
 
@@ -232,12 +330,76 @@ func (dp *DevicePageState) refresh(app *App) {
 	app.indexPage.show(app)
 }
 
+// updateDevice shows a new measurement of the current device in place,
+// without rebuilding the page
+func (dp *DevicePageState) updateDevice(app *App, deviceData DeviceWithMeasurement) {
+	widgets := dp.currentWidgets
+	if widgets == nil || dp.currentDeviceSerial != deviceData.Device.SerialNumber {
+		return
+	}
+
+	scoreCircle := app.createScoreCircle(deviceData.Measurement.Score)
+	widgets.header.Remove(widgets.scoreCircle)
+	widgets.header.Prepend(scoreCircle)
+	widgets.scoreCircle = scoreCircle
+
+	widgets.scoreLabel.SetText(fmt.Sprintf("Air Quality Score: %.0f", deviceData.Measurement.Score))
+
+	for metric, label := range widgets.metricLabels {
+		label.SetText(app.formatMetric(deviceData.Measurement, metric))
+	}
+
+	for i, value := range formatIndices(deviceData.Indices) {
+		widgets.indexLabels[i].SetText(value)
+	}
+
+	widgets.lastSeenLabel.SetText(deviceData.Device.LastSeen.Format("Jan 2, 15:04"))
+
+	// Add the new measurements to the graph, and redraw it when it shows the latest ones
+	if graphState := dp.currentGraphState; graphState != nil {
+		graphState.device = &deviceData
+		dp.loadNewMeasurements(app, graphState)
+		if graphState.timeOffset == 0 {
+			graphState.drawingArea.QueueDraw()
+		}
+	}
+}
+
+// formatMetric formats a metric of a measurement in the preferred unit
+func (app *App) formatMetric(measurement models.Measurement, metric MetricType) string {
+	preferences := globals.Settings.UnitPreferences()
+	return app.formatValue(metricValue(measurement, metric, preferences), getMetricInfo(preferences)[metric].Unit)
+}
+
+// formatIndices formats the air quality indices in the order they are shown
+// on the device page, as dashes if there were no measurements to compute them from
+func formatIndices(indices airquality.Indices) []string {
+	if !indices.Valid() {
+		return []string{"—", "—", "—", "—", "—"}
+	}
+
+	return []string{
+		fmt.Sprintf("%d · %s", indices.AQI.Value, indices.AQI.Name),
+		fmt.Sprintf("%d · %s", indices.CAQI.Value, indices.CAQI.Name),
+		indices.CO2.Name,
+		indices.VOC.Name,
+		indices.DominantPollutant.DisplayName(),
+	}
+}
+
 // clearState clears the device page state when leaving the page
 func (dp *DevicePageState) clearState() {
 	dp.currentDeviceSerial = ""
+	dp.isEditingLocation = false
 	dp.currentGraphState = nil
 	dp.currentScrollPosition = 0
 	dp.currentDeviceScrolled = nil
+	dp.currentWidgets = nil
+	dp.currentStatistics = nil
+	dp.currentHeatmap = nil
+	dp.currentHealth = nil
+	dp.currentVentilation = nil
+	dp.currentComfort = nil
 }
 
 // setupEditableDeviceName creates an editable device name widget
@@ -336,7 +498,9 @@ func (dp *DevicePageState) updateName(app *App, deviceID uint, newName string, d
 	app.logger.Info("Updating device name", "device_id", deviceID, "new_name", newName)
 
 	// Update device name in database
-	err := database.DB.Model(&models.Device{}).Where("id = ?", deviceID).Update("name", newName).Error
+	err := database.Write(func(tx *gorm.DB) error {
+		return tx.Model(&models.Device{}).Where("id = ?", deviceID).Update("name", newName).Error
+	})
 	if err != nil {
 		app.logger.Error("Failed to update device name", "device_id", deviceID, "error", err)
 		dp.isEditingDeviceName = false // Clear flag so UI can refresh normally
@@ -348,6 +512,7 @@ func (dp *DevicePageState) updateName(app *App, deviceID uint, newName string, d
 	// Clear editing flag and refresh the UI
 	dp.isEditingDeviceName = false
 	app.refreshDevicesFromDatabaseSafe()
+	app.syncDBusDevices()
 
 	// The page will be refreshed automatically, but we need to update the window title
 	glib.IdleAdd(func() bool {
@@ -356,6 +521,184 @@ func (dp *DevicePageState) updateName(app *App, deviceID uint, newName string, d
 	})
 }
 
+// addLocationGroup creates the group for editing the device's room, room volume, surface temperature and tags
+func (dp *DevicePageState) addLocationGroup(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement) {
+	locationGroup := adw.NewPreferencesGroup()
+	locationGroup.SetTitle("Location")
+	locationGroup.SetDescription("Rooms group devices on the overview, the volume and coldest surface estimate ventilation and mould risk, tags are comma separated")
+
+	roomRow := adw.NewEntryRow()
+	roomRow.SetTitle("Room")
+	roomRow.SetText(deviceData.Device.Room)
+	roomRow.SetShowApplyButton(true)
+	roomRow.ConnectChanged(func() {
+		dp.isEditingLocation = true
+	})
+	roomRow.ConnectApply(func() {
+		dp.updateRoom(app, deviceData.Device.ID, roomRow.Text())
+	})
+	locationGroup.Add(roomRow)
+
+	volumeRow := adw.NewEntryRow()
+	volumeRow.SetTitle("Room Volume (m³)")
+	if deviceData.Device.RoomVolume > 0 {
+		volumeRow.SetText(strconv.FormatFloat(deviceData.Device.RoomVolume, 'f', -1, 64))
+	}
+	volumeRow.SetInputPurpose(gtk.InputPurposeNumber)
+	volumeRow.SetShowApplyButton(true)
+	volumeRow.ConnectChanged(func() {
+		dp.isEditingLocation = true
+		volumeRow.RemoveCSSClass("error")
+	})
+	volumeRow.ConnectApply(func() {
+		volume, err := parseRoomVolume(volumeRow.Text())
+		if err != nil {
+			volumeRow.AddCSSClass("error")
+			return
+		}
+		dp.updateRoomVolume(app, deviceData.Device.ID, volume)
+	})
+	locationGroup.Add(volumeRow)
+
+	temperatureUnit := globals.Settings.UnitPreferences().Temperature
+	surfaceRow := adw.NewEntryRow()
+	surfaceRow.SetTitle("Coldest Surface Temperature (" + temperatureUnit.Symbol() + ")")
+	if temperature := deviceData.Device.SurfaceTemperature; temperature != nil {
+		surfaceRow.SetText(strconv.FormatFloat(math.Round(temperatureUnit.FromCelsius(*temperature)*10)/10, 'f', -1, 64))
+	}
+	surfaceRow.SetInputPurpose(gtk.InputPurposeNumber)
+	surfaceRow.SetShowApplyButton(true)
+	surfaceRow.ConnectChanged(func() {
+		dp.isEditingLocation = true
+		surfaceRow.RemoveCSSClass("error")
+	})
+	surfaceRow.ConnectApply(func() {
+		if strings.TrimSpace(surfaceRow.Text()) == "" {
+			dp.updateSurfaceTemperature(app, deviceData.Device.ID, nil)
+			return
+		}
+
+		value, err := parseNumber(surfaceRow.Text())
+		if err != nil {
+			surfaceRow.AddCSSClass("error")
+			return
+		}
+		celsius := temperatureUnit.ToCelsius(value)
+		dp.updateSurfaceTemperature(app, deviceData.Device.ID, &celsius)
+	})
+	locationGroup.Add(surfaceRow)
+
+	tagsRow := adw.NewEntryRow()
+	tagsRow.SetTitle("Tags")
+	tagsRow.SetText(strings.Join(deviceData.Device.TagNames(), ", "))
+	tagsRow.SetShowApplyButton(true)
+	tagsRow.ConnectChanged(func() {
+		dp.isEditingLocation = true
+	})
+	tagsRow.ConnectApply(func() {
+		dp.updateTags(app, deviceData.Device, tagsRow.Text())
+	})
+	locationGroup.Add(tagsRow)
+
+	container.Append(locationGroup)
+}
+
+// updateRoom updates the device room in the database
+func (dp *DevicePageState) updateRoom(app *App, deviceID uint, room string) {
+	room = models.NormalizeRoom(room)
+	app.logger.Info("Updating device room", "device_id", deviceID, "room", room)
+
+	err := database.Write(func(tx *gorm.DB) error {
+		return tx.Model(&models.Device{}).Where("id = ?", deviceID).Update("room", room).Error
+	})
+	if err != nil {
+		app.logger.Error("Failed to update device room", "device_id", deviceID, "error", err)
+	}
+
+	dp.isEditingLocation = false
+	app.refreshDevicesFromDatabaseSafe()
+	app.syncDBusDevices()
+}
+
+// parseRoomVolume parses a room volume in m³, accepting a decimal comma,
+// an empty text clears the volume
+func parseRoomVolume(text string) (float64, error) {
+	if strings.TrimSpace(text) == "" {
+		return 0, nil
+	}
+
+	volume, err := parseNumber(text)
+	if err != nil || volume < 0 {
+		return 0, fmt.Errorf("invalid room volume %q", text)
+	}
+	return volume, nil
+}
+
+// parseNumber parses a number typed in by the user, accepting a decimal comma
+func parseNumber(text string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(strings.ReplaceAll(text, ",", ".")), 64)
+}
+
+// updateSurfaceTemperature updates the temperature in °C of the coldest
+// surface of the device's room, nil to clear it, and judges the mould
+// risk again with it
+func (dp *DevicePageState) updateSurfaceTemperature(app *App, deviceID uint, temperature *float64) {
+	app.logger.Info("Updating device surface temperature", "device_id", deviceID, "temperature", temperature)
+
+	err := database.Write(func(tx *gorm.DB) error {
+		return tx.Model(&models.Device{}).Where("id = ?", deviceID).Update("surface_temperature", temperature).Error
+	})
+	if err != nil {
+		app.logger.Error("Failed to update device surface temperature", "device_id", deviceID, "error", err)
+	}
+
+	dp.isEditingLocation = false
+	app.refreshDevicesFromDatabaseSafe()
+
+	if state := dp.currentComfort; err == nil && state != nil && state.device.ID == deviceID {
+		state.device.SurfaceTemperature = temperature
+		dp.loadMouldRisk(app, state)
+	}
+}
+
+// updateRoomVolume updates the device room volume in the database and
+// estimates the ventilation again with it
+func (dp *DevicePageState) updateRoomVolume(app *App, deviceID uint, volume float64) {
+	app.logger.Info("Updating device room volume", "device_id", deviceID, "volume", volume)
+
+	err := database.Write(func(tx *gorm.DB) error {
+		return tx.Model(&models.Device{}).Where("id = ?", deviceID).Update("room_volume", volume).Error
+	})
+	if err != nil {
+		app.logger.Error("Failed to update device room volume", "device_id", deviceID, "error", err)
+	}
+
+	dp.isEditingLocation = false
+	app.refreshDevicesFromDatabaseSafe()
+
+	if state := dp.currentVentilation; err == nil && state != nil && state.device.ID == deviceID {
+		state.device.RoomVolume = volume
+		dp.loadVentilation(app, state)
+	}
+}
+
+// updateTags replaces the device tags in the database
+func (dp *DevicePageState) updateTags(app *App, device models.Device, input string) {
+	tags := models.ParseTags(input)
+	app.logger.Info("Updating device tags", "device_id", device.ID, "tags", tags)
+
+	err := database.Write(func(tx *gorm.DB) error {
+		return device.ReplaceTags(tx, tags)
+	})
+	if err != nil {
+		app.logger.Error("Failed to update device tags", "device_id", device.ID, "error", err)
+	}
+
+	dp.isEditingLocation = false
+	app.refreshDevicesFromDatabaseSafe()
+	app.syncDBusDevices()
+}
+
 // MetricInfo holds display information for each metric
 type MetricInfo struct {
 	Name  string
@@ -364,17 +707,48 @@ type MetricInfo struct {
 }
 
 // getMetricInfo returns display information for each metric type
-func getMetricInfo() map[MetricType]MetricInfo {
+func getMetricInfo(preferences units.Preferences) map[MetricType]MetricInfo {
 	return map[MetricType]MetricInfo{
-		MetricTemperature: {"Temperature", "°C", [3]float64{0.96, 0.47, 0.24}}, // Orange
-		MetricHumidity:    {"Humidity", "%", [3]float64{0.20, 0.74, 0.96}},     // Blue
-		MetricCO2:         {"CO₂", "ppm", [3]float64{0.95, 0.61, 0.23}},        // Yellow-Orange
-		MetricVOC:         {"VOC", "ppb", [3]float64{0.58, 0.75, 0.33}},        // Green
-		MetricPM25:        {"PM2.5", "μg/m³", [3]float64{0.88, 0.32, 0.43}},    // Red
-		MetricScore:       {"Score", "", [3]float64{0.45, 0.67, 0.89}},         // Light Blue
+		MetricTemperature:      {"Temperature", preferences.Temperature.Symbol(), [3]float64{0.96, 0.47, 0.24}}, // Orange
+		MetricHumidity:         {"Humidity", "%", [3]float64{0.20, 0.74, 0.96}},                                 // Blue
+		MetricCO2:              {"CO₂", "ppm", [3]float64{0.95, 0.61, 0.23}},                                    // Yellow-Orange
+		MetricVOC:              {"VOC", preferences.VOC.Symbol(), [3]float64{0.58, 0.75, 0.33}},                 // Green
+		MetricPM25:             {"PM2.5", "μg/m³", [3]float64{0.88, 0.32, 0.43}},                                // Red
+		MetricScore:            {"Score", "", [3]float64{0.45, 0.67, 0.89}},                                     // Light Blue
+		MetricDewPoint:         {"Dew Point", preferences.Temperature.Symbol(), [3]float64{0.31, 0.52, 0.80}},   // Steel Blue
+		MetricHeatIndex:        {"Heat Index", preferences.Temperature.Symbol(), [3]float64{0.85, 0.33, 0.18}},  // Dark Orange
+		MetricHumidex:          {"Humidex", "", [3]float64{0.67, 0.40, 0.76}},                                   // Purple
+		MetricAbsoluteHumidity: {"Abs. Humidity", "g/m³", [3]float64{0.16, 0.63, 0.60}},                         // Teal
 	}
 }
 
+// metricValue extracts a metric from a measurement, converted to the preferred unit
+func metricValue(measurement models.Measurement, metric MetricType, preferences units.Preferences) float64 {
+	switch metric {
+	case MetricTemperature:
+		return preferences.Temperature.FromCelsius(measurement.Temperature)
+	case MetricHumidity:
+		return measurement.Humidity
+	case MetricCO2:
+		return measurement.CO2
+	case MetricVOC:
+		return preferences.VOC.FromPPB(measurement.VOC)
+	case MetricPM25:
+		return measurement.PM25
+	case MetricScore:
+		return measurement.Score
+	case MetricDewPoint:
+		return preferences.Temperature.FromCelsius(comfort.DewPointOf(measurement))
+	case MetricHeatIndex:
+		return preferences.Temperature.FromCelsius(comfort.HeatIndex(measurement.Temperature, measurement.Humidity))
+	case MetricHumidex:
+		return comfort.Humidex(measurement.Temperature, comfort.DewPointOf(measurement))
+	case MetricAbsoluteHumidity:
+		return comfort.AbsoluteHumidity(measurement.Temperature, measurement.Humidity)
+	}
+	return 0
+}
+
 // addMeasurementGraph creates and adds the measurement graph widget
 func (dp *DevicePageState) addGraph(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement) {
 	graphGroup := adw.NewPreferencesGroup()
@@ -389,6 +763,8 @@ func (dp *DevicePageState) addGraph(app *App, container *gtk.Box, deviceData *De
 		// Clear button references since we're recreating the UI
 		graphState.metricButtons = make(map[MetricType]*gtk.Button)
 		graphState.windowButtons = make(map[time.Duration]*gtk.Button)
+		// Load the data again, the page is rebuilt when it changed
+		graphState.data = graphData{}
 	} else {
 		// Create new graph state
 		graphState = &GraphState{
@@ -403,6 +779,7 @@ func (dp *DevicePageState) addGraph(app *App, container *gtk.Box, deviceData *De
 		}
 		dp.currentGraphState = graphState
 	}
+	dp.loadGraphData(app, graphState)
 
 	// Metric selector buttons
 	buttonRow := gtk.NewBox(gtk.OrientationHorizontal, 8)
@@ -410,9 +787,15 @@ func (dp *DevicePageState) addGraph(app *App, container *gtk.Box, deviceData *De
 	buttonRow.SetMarginTop(12)
 	buttonRow.SetMarginBottom(12)
 
+	// Comfort indices get a row of their own below the measured metrics
+	comfortRow := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	comfortRow.SetHAlign(gtk.AlignCenter)
+	comfortRow.SetMarginBottom(12)
+
 	// Create buttons in a consistent order
-	metricOrder := []MetricType{MetricScore, MetricTemperature, MetricHumidity, MetricCO2, MetricVOC, MetricPM25}
-	metricInfos := getMetricInfo()
+	metricOrder := []MetricType{MetricScore, MetricTemperature, MetricHumidity, MetricCO2, MetricVOC, MetricPM25,
+		MetricDewPoint, MetricHeatIndex, MetricHumidex, MetricAbsoluteHumidity}
+	metricInfos := getMetricInfo(globals.Settings.UnitPreferences())
 
 	for _, metricType := range metricOrder {
 		info := metricInfos[metricType]
@@ -433,7 +816,11 @@ func (dp *DevicePageState) addGraph(app *App, container *gtk.Box, deviceData *De
 			dp.selectMetric(app, graphState, currentMetric)
 		})
 
-		buttonRow.Append(button)
+		if metricType >= MetricDewPoint {
+			comfortRow.Append(button)
+		} else {
+			buttonRow.Append(button)
+		}
 	}
 
 	// Time navigation controls
@@ -535,17 +922,26 @@ func (dp *DevicePageState) addGraph(app *App, container *gtk.Box, deviceData *De
 	})
 	graphState.drawingArea.AddController(motionController)
 
+	dp.addAnnotationController(app, graphState)
+	dp.addGraphGestures(app, graphState)
+
 	// Wrap drawing area in a fixed-size container to prevent layout changes
 	graphContainer := gtk.NewBox(gtk.OrientationVertical, 0)
 	graphContainer.SetSizeRequest(-1, 300) // Fixed height
 	graphContainer.SetVExpand(false)
 	graphContainer.Append(graphState.drawingArea)
 
+	hintLabel := gtk.NewLabel("Scroll to zoom, drag to move, Shift+drag to select a range, right-click to add a note")
+	hintLabel.AddCSSClass("caption")
+	hintLabel.AddCSSClass("dim-label")
+
 	// Assemble the graph widget
 	graphBox := gtk.NewBox(gtk.OrientationVertical, 8)
 	graphBox.Append(buttonRow)
+	graphBox.Append(comfortRow)
 	graphBox.Append(navRow)
 	graphBox.Append(graphContainer)
+	graphBox.Append(hintLabel)
 
 	graphGroup.Add(graphBox)
 	container.Append(graphGroup)
@@ -573,68 +969,28 @@ func (dp *DevicePageState) selectMetric(app *App, graphState *GraphState, metric
 	graphState.drawingArea.QueueDraw()
 }
 
-// selectTimeWindow changes the time window duration
+// selectTimeWindow changes the time window duration, back to the current time
 func (dp *DevicePageState) selectTimeWindow(app *App, graphState *GraphState, duration time.Duration) {
-	// Update button styles - remove suggested-action from all buttons
-	for _, button := range graphState.windowButtons {
-		button.RemoveCSSClass("suggested-action")
-	}
-
-	// Add suggested-action to the selected button
-	if selectedButton, exists := graphState.windowButtons[duration]; exists {
-		selectedButton.AddCSSClass("suggested-action")
-	}
-
-	// Update time window
-	graphState.timeWindow = duration
-
-	// Reset time offset to current time when changing window
-	graphState.timeOffset = 0
-
-	// Update time label
-	if graphState.timeLabel != nil {
-		graphState.timeLabel.SetText(dp.getTimeWindowLabel(graphState.timeOffset, graphState.timeWindow))
-	}
-
-	// Redraw graph
-	graphState.drawingArea.QueueDraw()
+	dp.setTimeView(app, graphState, duration, 0)
 }
 
 // navigateTime moves the time window and updates the graph
 func (dp *DevicePageState) navigateTime(app *App, graphState *GraphState, deltaTime time.Duration) {
-	newOffset := graphState.timeOffset + deltaTime
-
-	// Don't allow going into the future
-	if newOffset > 0 {
-		newOffset = 0
-	}
-
-	// Don't allow going too far back (e.g., more than 7 days)
-	maxBack := -7 * 24 * time.Hour
-	if newOffset < maxBack {
-		newOffset = maxBack
-	}
-
-	graphState.timeOffset = newOffset
-
-	// Update time label
-	if graphState.timeLabel != nil {
-		graphState.timeLabel.SetText(dp.getTimeWindowLabel(newOffset, graphState.timeWindow))
-	}
-
-	// Redraw the graph
-	graphState.drawingArea.QueueDraw()
+	dp.setTimeView(app, graphState, graphState.timeWindow, graphState.timeOffset+deltaTime)
 }
 
 // getTimeWindowLabel returns a human-readable label for the current time window
 func (dp *DevicePageState) getTimeWindowLabel(offset time.Duration, windowDuration time.Duration) string {
-	windowHours := int(windowDuration.Hours())
-
 	if offset == 0 {
-		if windowHours == 1 {
+		switch {
+		case windowDuration == time.Hour:
 			return "Last hour"
+		case windowDuration%time.Hour == 0:
+			return fmt.Sprintf("Last %d hours", int(windowDuration.Hours()))
+		default:
+			// Zoomed to a window between the buttons
+			return "Last " + stats.FormatDuration(windowDuration)
 		}
-		return fmt.Sprintf("Last %d hours", windowHours)
 	}
 
 	endTime := time.Now().Add(offset)
@@ -670,37 +1026,39 @@ func (dp *DevicePageState) drawGraph(app *App, cr *cairo.Context, graphState *Gr
 		return
 	}
 
-	// Get measurements for the current time window
-	measurements := dp.getMeasurementsForTimeWindow(app, graphState.device.Device.ID, graphState.timeOffset, graphState.timeWindow)
+	// Time range - use UTC to match database timestamps
+	startTime, endTime, now := graphState.timeRange()
+
+	// Get the loaded measurements and notes of the current time window
+	measurements := graphState.data.measurementsBetween(startTime, now)
+	graphState.annotations = graphState.data.annotationsBetween(startTime, endTime)
 	if len(measurements) == 0 {
+		graphState.annotationAreas = nil
 		dp.drawNoDataMessage(cr, width, height)
 		return
 	}
 
 	// Get metric info
-	metricInfos := getMetricInfo()
+	preferences := globals.Settings.UnitPreferences()
+	metricInfos := getMetricInfo(preferences)
 	metricInfo := metricInfos[graphState.selectedMetric]
 
-	// Extract values for the selected metric
+	// Extract values for the selected metric, converted to the preferred unit
 	values := make([]float64, len(measurements))
 	times := make([]time.Time, len(measurements))
 
 	for i, m := range measurements {
 		times[i] = m.Timestamp
-		switch graphState.selectedMetric {
-		case MetricTemperature:
-			values[i] = m.Temperature
-		case MetricHumidity:
-			values[i] = m.Humidity
-		case MetricCO2:
-			values[i] = m.CO2
-		case MetricVOC:
-			values[i] = m.VOC
-		case MetricPM25:
-			values[i] = m.PM25
-		case MetricScore:
-			values[i] = m.Score
-		}
+		values[i] = metricValue(m, graphState.selectedMetric, preferences)
+	}
+
+	// The projection of the latest measurements, and for CO₂ the level at
+	// which the room should be ventilated
+	projection, showForecast := graphState.forecast()
+	var threshold *float64
+	if showForecast && graphState.selectedMetric == MetricCO2 {
+		ppm := float64(globals.Settings.CO2Threshold)
+		threshold = &ppm
 	}
 
 	// Find value range
@@ -713,6 +1071,17 @@ func (dp *DevicePageState) drawGraph(app *App, cr *cairo.Context, graphState *Gr
 			maxVal = v
 		}
 	}
+	if showForecast {
+		for _, v := range []float64{projection.Level, projection.At(endTime)} {
+			minVal, maxVal = math.Min(minVal, v), math.Max(maxVal, v)
+		}
+		// Only make room for the threshold when it's about to be reached
+		if threshold != nil {
+			if crossing, ok := projection.Crossing(*threshold); ok && !crossing.After(endTime) {
+				maxVal = math.Max(maxVal, *threshold)
+			}
+		}
+	}
 
 	// Add some padding to the range
 	range_ := maxVal - minVal
@@ -723,13 +1092,16 @@ func (dp *DevicePageState) drawGraph(app *App, cr *cairo.Context, graphState *Gr
 	minVal -= padding
 	maxVal += padding
 
-	// Time range - use UTC to match database timestamps
-	endTime := time.Now().UTC().Add(graphState.timeOffset)
-	startTime := endTime.Add(-graphState.timeWindow)
-
 	// Draw grid and axes
 	dp.drawGridAndAxes(cr, marginLeft, marginTop, graphWidth, graphHeight,
-		startTime, endTime, minVal, maxVal, metricInfo.Unit)
+		startTime, endTime, minVal, maxVal, metricInfo.Unit, preferences)
+
+	// Shade the spans without measurements, the forecast shows what's ahead
+	gaps := graphGaps(times, startTime, now)
+	dp.drawGaps(cr, gaps, marginLeft, marginTop, graphWidth, graphHeight, startTime, endTime)
+
+	// Mark the notes on the timeline
+	dp.drawAnnotations(cr, graphState, marginLeft, marginTop, graphWidth, graphHeight, startTime, endTime)
 
 	// Draw the area under the curve
 	dp.drawGraphArea(cr, measurements, values, times, marginLeft, marginTop,
@@ -739,30 +1111,69 @@ func (dp *DevicePageState) drawGraph(app *App, cr *cairo.Context, graphState *Gr
 	dp.drawGraphLine(cr, measurements, values, times, marginLeft, marginTop,
 		graphWidth, graphHeight, startTime, endTime, minVal, maxVal, metricInfo.Color)
 
+	// Mark measurements that look like sensor faults
+	dp.drawAnomalyMarkers(cr, measurements, values, times, marginLeft, marginTop,
+		graphWidth, graphHeight, startTime, endTime, minVal, maxVal)
+
+	// Draw the projection past the latest measurement
+	if showForecast {
+		dp.drawForecast(cr, projection, threshold, marginLeft, marginTop, graphWidth, graphHeight,
+			startTime, endTime, now, minVal, maxVal, metricInfo.Color, preferences)
+	}
+
+	// Shade the selected range with its statistics
+	if graphState.selection != nil {
+		dp.drawSelection(app, cr, graphState, marginLeft, marginTop, graphWidth, graphHeight,
+			startTime, endTime, metricInfo, preferences)
+	}
+
 	// Draw hover effects if mouse is over the graph
-	if graphState.hoveredPoint >= 0 && graphState.hoveredPoint < len(measurements) {
+	hoverTime := startTime.Add(time.Duration((graphState.hoverX - float64(marginLeft)) / float64(graphWidth) * float64(endTime.Sub(startTime))))
+	if index := graphState.annotationAt(graphState.hoverX, graphState.hoverY, marginTop); index >= 0 {
+		dp.drawAnnotationHover(cr, graphState, graphState.annotations[index], marginLeft, marginTop, graphWidth)
+	} else if graphState.hoveredPoint >= 0 && graphState.hoveredPoint < len(measurements) {
 		dp.drawHoverEffects(app, cr, graphState, measurements, values, times, marginLeft, marginTop,
 			graphWidth, graphHeight, startTime, endTime, minVal, maxVal, metricInfo)
+	} else if graphState.hoverX >= float64(marginLeft) && graphState.hoverX <= float64(marginLeft+graphWidth) {
+		if showForecast && hoverTime.After(now) {
+			dp.drawForecastHover(app, cr, graphState, projection, hoverTime, marginLeft, marginTop, graphWidth, graphHeight,
+				minVal, maxVal, metricInfo)
+		} else {
+			dp.drawGapHover(cr, graphState, gaps, marginLeft, marginTop, graphWidth, graphHeight, startTime, endTime, now)
+		}
 	}
 }
 
-// getMeasurementsForTimeWindow fetches measurements for the specified time window
-func (dp *DevicePageState) getMeasurementsForTimeWindow(app *App, deviceID uint, offset time.Duration, windowDuration time.Duration) []models.Measurement {
-	// Use UTC time to match database timestamps
-	endTime := time.Now().UTC().Add(offset)
-	startTime := endTime.Add(-windowDuration)
+// forecast returns the projection of the selected metric, and false if the
+// graph doesn't show one. Only the latest measurements are projected and
+// only CO₂ and the score can be.
+func (graphState *GraphState) forecast() (forecast.Forecast, bool) {
+	if graphState.timeOffset != 0 {
+		return forecast.Forecast{}, false
+	}
 
-	var measurements []models.Measurement
-	err := database.DB.Where("device_id = ? AND timestamp BETWEEN ? AND ?",
-		deviceID, startTime, endTime).
-		Order("timestamp ASC").
-		Find(&measurements).Error
-	if err != nil {
-		app.logger.Error("Failed to fetch measurements for graph", "error", err)
-		return nil
+	var projection forecast.Forecast
+	switch graphState.selectedMetric {
+	case MetricCO2:
+		projection = graphState.device.CO2Forecast
+	case MetricScore:
+		projection = graphState.device.ScoreForecast
 	}
+	return projection, projection.Valid()
+}
+
+// timeRange returns the span of time the graph shows and the time its
+// measurements end at. The graph extends past that by GRAPH_FORECAST_SHARE
+// of the time window, up to the forecast horizon, to fit a forecast.
+func (graphState *GraphState) timeRange() (startTime, endTime, now time.Time) {
+	now = time.Now().UTC().Add(graphState.timeOffset)
+	startTime = now.Add(-graphState.timeWindow)
+	endTime = now
 
-	return measurements
+	if _, ok := graphState.forecast(); ok {
+		endTime = now.Add(min(time.Duration(float64(graphState.timeWindow)*GRAPH_FORECAST_SHARE), forecast.Horizon))
+	}
+	return startTime, endTime, now
 }
 
 // drawNoDataMessage displays a message when no data is available
@@ -774,7 +1185,7 @@ func (dp *DevicePageState) drawNoDataMessage(cr *cairo.Context, width, height in
 
 // drawGridAndAxes draws the graph grid and axis labels
 func (dp *DevicePageState) drawGridAndAxes(cr *cairo.Context, marginLeft, marginTop, graphWidth, graphHeight int,
-	startTime, endTime time.Time, minVal, maxVal float64, unit string,
+	startTime, endTime time.Time, minVal, maxVal float64, unit string, preferences units.Preferences,
 ) {
 	// Set grid color
 	cr.SetSourceRGB(0.9, 0.9, 0.9)
@@ -803,7 +1214,7 @@ func (dp *DevicePageState) drawGridAndAxes(cr *cairo.Context, marginLeft, margin
 	for i := 0; i <= numYLines; i++ {
 		y := marginTop + int(float64(i)/float64(numYLines)*float64(graphHeight))
 		value := maxVal - (float64(i)/float64(numYLines))*(maxVal-minVal)
-		label := fmt.Sprintf("%.1f", value)
+		label := preferences.FormatNumber(value, 1)
 		if unit != "" {
 			label += " " + unit
 		}
@@ -826,7 +1237,73 @@ func (dp *DevicePageState) drawGridAndAxes(cr *cairo.Context, marginLeft, margin
 	}
 }
 
-// drawGraphArea draws the filled area under the graph line
+// graphSegments splits the measurements into runs without gaps longer than
+// GRAPH_GAP_AFTER, returned as start and end (exclusive) indexes
+func graphSegments(times []time.Time) [][2]int {
+	var segments [][2]int
+	start := 0
+	for i := 1; i <= len(times); i++ {
+		if i < len(times) && times[i].Sub(times[i-1]) <= GRAPH_GAP_AFTER {
+			continue
+		}
+		segments = append(segments, [2]int{start, i})
+		start = i
+	}
+	return segments
+}
+
+// graphGaps returns the spans of the time window without measurements,
+// including those before the first and after the last measurement
+func graphGaps(times []time.Time, startTime, endTime time.Time) []anomaly.Gap {
+	if len(times) == 0 {
+		return []anomaly.Gap{{From: startTime, To: endTime}}
+	}
+
+	var gaps []anomaly.Gap
+	if times[0].Sub(startTime) > GRAPH_GAP_AFTER {
+		gaps = append(gaps, anomaly.Gap{From: startTime, To: times[0]})
+	}
+	for i := 1; i < len(times); i++ {
+		if times[i].Sub(times[i-1]) > GRAPH_GAP_AFTER {
+			gaps = append(gaps, anomaly.Gap{From: times[i-1], To: times[i]})
+		}
+	}
+	if last := times[len(times)-1]; endTime.Sub(last) > GRAPH_GAP_AFTER {
+		gaps = append(gaps, anomaly.Gap{From: last, To: endTime})
+	}
+	return gaps
+}
+
+// drawGaps shades the spans of the graph without measurements
+func (dp *DevicePageState) drawGaps(cr *cairo.Context, gaps []anomaly.Gap, marginLeft, marginTop, graphWidth, graphHeight int,
+	startTime, endTime time.Time,
+) {
+	timeRange := endTime.Sub(startTime).Seconds()
+
+	cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
+	cr.SetFontSize(11)
+	labelExtents := cr.TextExtents("No data")
+
+	for _, gap := range gaps {
+		fromX := float64(marginLeft) + gap.From.Sub(startTime).Seconds()/timeRange*float64(graphWidth)
+		toX := float64(marginLeft) + gap.To.Sub(startTime).Seconds()/timeRange*float64(graphWidth)
+
+		cr.SetSourceRGBA(0.5, 0.5, 0.5, 0.12)
+		cr.Rectangle(fromX, float64(marginTop), toX-fromX, float64(graphHeight))
+		cr.Fill()
+
+		// Only label spans wide enough to fit the label
+		if toX-fromX < labelExtents.Width+10 {
+			continue
+		}
+		cr.SetSourceRGB(0.5, 0.5, 0.5)
+		cr.MoveTo((fromX+toX)/2-labelExtents.Width/2, float64(marginTop+graphHeight/2))
+		cr.ShowText("No data")
+	}
+}
+
+// drawGraphArea draws the filled area under the graph line, separately for
+// each run of measurements so that gaps stay empty
 func (dp *DevicePageState) drawGraphArea(cr *cairo.Context, measurements []models.Measurement, values []float64, times []time.Time,
 	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime time.Time, minVal, maxVal float64, color [3]float64,
 ) {
@@ -837,34 +1314,37 @@ func (dp *DevicePageState) drawGraphArea(cr *cairo.Context, measurements []model
 	// Set fill color with transparency
 	cr.SetSourceRGBA(color[0], color[1], color[2], 0.3)
 
-	// Start from bottom-left
 	timeRange := endTime.Sub(startTime).Seconds()
 	valueRange := maxVal - minVal
 
-	// Move to first point
-	firstTime := times[0].Sub(startTime).Seconds()
-	firstX := marginLeft + int(firstTime/timeRange*float64(graphWidth))
-	firstY := marginTop + int((maxVal-values[0])/valueRange*float64(graphHeight))
-	cr.MoveTo(float64(firstX), float64(marginTop+graphHeight)) // Start from bottom
-	cr.LineTo(float64(firstX), float64(firstY))
-
-	// Draw line through all points
-	for i := 1; i < len(values); i++ {
-		timePos := times[i].Sub(startTime).Seconds()
-		x := marginLeft + int(timePos/timeRange*float64(graphWidth))
-		y := marginTop + int((maxVal-values[i])/valueRange*float64(graphHeight))
-		cr.LineTo(float64(x), float64(y))
-	}
-
-	// Close the area back to bottom
-	lastTime := times[len(times)-1].Sub(startTime).Seconds()
-	lastX := marginLeft + int(lastTime/timeRange*float64(graphWidth))
-	cr.LineTo(float64(lastX), float64(marginTop+graphHeight))
-	cr.ClosePath()
+	for _, segment := range graphSegments(times) {
+		first, last := segment[0], segment[1]-1
+
+		// Start from the bottom below the first point
+		firstTime := times[first].Sub(startTime).Seconds()
+		firstX := marginLeft + int(firstTime/timeRange*float64(graphWidth))
+		firstY := marginTop + int((maxVal-values[first])/valueRange*float64(graphHeight))
+		cr.MoveTo(float64(firstX), float64(marginTop+graphHeight))
+		cr.LineTo(float64(firstX), float64(firstY))
+
+		// Draw line through all points of the run
+		for i := first + 1; i <= last; i++ {
+			timePos := times[i].Sub(startTime).Seconds()
+			x := marginLeft + int(timePos/timeRange*float64(graphWidth))
+			y := marginTop + int((maxVal-values[i])/valueRange*float64(graphHeight))
+			cr.LineTo(float64(x), float64(y))
+		}
+
+		// Close the area back to bottom
+		lastTime := times[last].Sub(startTime).Seconds()
+		lastX := marginLeft + int(lastTime/timeRange*float64(graphWidth))
+		cr.LineTo(float64(lastX), float64(marginTop+graphHeight))
+		cr.ClosePath()
+	}
 	cr.Fill()
 }
 
-// drawGraphLine draws the graph line
+// drawGraphLine draws the graph line, broken where measurements are missing
 func (dp *DevicePageState) drawGraphLine(cr *cairo.Context, measurements []models.Measurement, values []float64, times []time.Time,
 	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime time.Time, minVal, maxVal float64, color [3]float64,
 ) {
@@ -879,21 +1359,130 @@ func (dp *DevicePageState) drawGraphLine(cr *cairo.Context, measurements []model
 	timeRange := endTime.Sub(startTime).Seconds()
 	valueRange := maxVal - minVal
 
-	// Move to first point
-	firstTime := times[0].Sub(startTime).Seconds()
-	firstX := marginLeft + int(firstTime/timeRange*float64(graphWidth))
-	firstY := marginTop + int((maxVal-values[0])/valueRange*float64(graphHeight))
-	cr.MoveTo(float64(firstX), float64(firstY))
+	for _, segment := range graphSegments(times) {
+		first, last := segment[0], segment[1]-1
+
+		firstTime := times[first].Sub(startTime).Seconds()
+		firstX := marginLeft + int(firstTime/timeRange*float64(graphWidth))
+		firstY := marginTop + int((maxVal-values[first])/valueRange*float64(graphHeight))
+
+		// A lone measurement between gaps has no line, draw it as a dot
+		if first == last {
+			cr.NewSubPath()
+			cr.Arc(float64(firstX), float64(firstY), 2, 0, 2*math.Pi)
+			cr.Fill()
+			continue
+		}
+
+		// Move to first point
+		cr.MoveTo(float64(firstX), float64(firstY))
+
+		// Draw line through all points of the run
+		for i := first + 1; i <= last; i++ {
+			timePos := times[i].Sub(startTime).Seconds()
+			x := marginLeft + int(timePos/timeRange*float64(graphWidth))
+			y := marginTop + int((maxVal-values[i])/valueRange*float64(graphHeight))
+			cr.LineTo(float64(x), float64(y))
+		}
+		cr.Stroke()
+	}
+}
+
+// drawAnomalyMarkers draws a red ring around measurements flagged as anomalies
+func (dp *DevicePageState) drawAnomalyMarkers(cr *cairo.Context, measurements []models.Measurement, values []float64, times []time.Time,
+	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime time.Time, minVal, maxVal float64,
+) {
+	timeRange := endTime.Sub(startTime).Seconds()
+	valueRange := maxVal - minVal
+
+	cr.SetSourceRGB(0.8, 0.2, 0.2)
+	cr.SetLineWidth(1.5)
+
+	for i, measurement := range measurements {
+		if measurement.Anomalies == 0 {
+			continue
+		}
+
+		x := float64(marginLeft) + times[i].Sub(startTime).Seconds()/timeRange*float64(graphWidth)
+		y := float64(marginTop) + (maxVal-values[i])/valueRange*float64(graphHeight)
+		cr.NewSubPath()
+		cr.Arc(x, y, 3.5, 0, 2*math.Pi)
+		cr.Stroke()
+	}
+}
+
+// drawForecast draws the projection as a dashed line from the latest
+// measurements, and for CO₂ the threshold with where the projection reaches it
+func (dp *DevicePageState) drawForecast(cr *cairo.Context, projection forecast.Forecast, threshold *float64,
+	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime, now time.Time, minVal, maxVal float64,
+	color [3]float64, preferences units.Preferences,
+) {
+	timeRange := endTime.Sub(startTime).Seconds()
+	valueRange := maxVal - minVal
 
-	// Draw line through all points
-	for i := 1; i < len(values); i++ {
-		timePos := times[i].Sub(startTime).Seconds()
-		x := marginLeft + int(timePos/timeRange*float64(graphWidth))
-		y := marginTop + int((maxVal-values[i])/valueRange*float64(graphHeight))
-		cr.LineTo(float64(x), float64(y))
+	toX := func(at time.Time) float64 {
+		return float64(marginLeft) + at.Sub(startTime).Seconds()/timeRange*float64(graphWidth)
+	}
+	toY := func(value float64) float64 {
+		return float64(marginTop) + (maxVal-value)/valueRange*float64(graphHeight)
 	}
 
+	// Separate what was measured from what is projected
+	cr.SetSourceRGBA(0.5, 0.5, 0.5, 0.6)
+	cr.SetLineWidth(1)
+	cr.SetDash([]float64{2, 3}, 0)
+	cr.MoveTo(toX(now), float64(marginTop))
+	cr.LineTo(toX(now), float64(marginTop+graphHeight))
 	cr.Stroke()
+
+	cr.SetSourceRGBA(color[0], color[1], color[2], 0.8)
+	cr.SetLineWidth(2)
+	cr.SetDash([]float64{6, 4}, 0)
+	cr.MoveTo(toX(projection.From), toY(projection.Level))
+	for _, point := range projection.Points[1:] {
+		if point.Timestamp.After(endTime) {
+			break
+		}
+		cr.LineTo(toX(point.Timestamp), toY(point.Value))
+	}
+	cr.LineTo(toX(endTime), toY(projection.At(endTime)))
+	cr.Stroke()
+
+	if threshold != nil && *threshold >= minVal && *threshold <= maxVal {
+		cr.SetSourceRGBA(0.8, 0.2, 0.2, 0.7)
+		cr.SetLineWidth(1)
+		cr.SetDash([]float64{4, 4}, 0)
+		cr.MoveTo(float64(marginLeft), toY(*threshold))
+		cr.LineTo(float64(marginLeft+graphWidth), toY(*threshold))
+		cr.Stroke()
+
+		cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
+		cr.SetFontSize(11)
+		label := "Ventilate at " + preferences.FormatNumber(*threshold, 0) + " ppm"
+		labelExtents := cr.TextExtents(label)
+		cr.MoveTo(float64(marginLeft+graphWidth)-labelExtents.Width-4, toY(*threshold)-4)
+		cr.ShowText(label)
+
+		// Mark where the projection is expected to reach the threshold
+		if crossing, ok := projection.Crossing(*threshold); ok && crossing.After(now) && !crossing.After(endTime) {
+			cr.NewSubPath()
+			cr.Arc(toX(crossing), toY(*threshold), 4, 0, 2*math.Pi)
+			cr.Fill()
+		}
+	}
+
+	cr.SetDash(nil, 0)
+}
+
+// drawForecastHover draws a tooltip with the projected value under the mouse cursor
+func (dp *DevicePageState) drawForecastHover(app *App, cr *cairo.Context, graphState *GraphState, projection forecast.Forecast,
+	hoverTime time.Time, marginLeft, marginTop, graphWidth, graphHeight int, minVal, maxVal float64, metricInfo MetricInfo,
+) {
+	value := projection.At(hoverTime)
+	pointY := float64(marginTop) + (maxVal-value)/(maxVal-minVal)*float64(graphHeight)
+
+	tooltipText := fmt.Sprintf("Forecast %s - ~%s", hoverTime.Local().Format("15:04"), app.formatValue(math.Round(value), metricInfo.Unit))
+	drawTooltipText(cr, tooltipText, graphState.hoverX, pointY, float64(marginLeft+graphWidth))
 }
 
 // onGraphMouseMotion handles mouse motion over the graph
@@ -920,8 +1509,9 @@ func (dp *DevicePageState) onGraphMouseLeave(app *App, graphState *GraphState) {
 
 // findClosestPoint finds the measurement point closest to the mouse cursor
 func (dp *DevicePageState) findClosestPoint(app *App, graphState *GraphState, mouseX, mouseY float64) int {
-	// Get measurements for the current time window
-	measurements := dp.getMeasurementsForTimeWindow(app, graphState.device.Device.ID, graphState.timeOffset, graphState.timeWindow)
+	// Get the loaded measurements of the current time window
+	startTime, endTime, now := graphState.timeRange()
+	measurements := graphState.data.measurementsBetween(startTime, now)
 	if len(measurements) == 0 {
 		return -1
 	}
@@ -942,9 +1532,6 @@ func (dp *DevicePageState) findClosestPoint(app *App, graphState *GraphState, mo
 		return -1
 	}
 
-	// Time range
-	endTime := time.Now().UTC().Add(graphState.timeOffset)
-	startTime := endTime.Add(-graphState.timeWindow)
 	timeRange := endTime.Sub(startTime).Seconds()
 
 	// Find closest point
@@ -1007,18 +1594,55 @@ func (dp *DevicePageState) drawHoverEffects(app *App, cr *cairo.Context, graphSt
 	cr.Stroke()
 
 	// Draw tooltip
-	dp.drawTooltip(app, cr, measurement, value, metricInfo, float64(pointX), float64(pointY))
+	dp.drawTooltip(app, cr, measurement, value, metricInfo, float64(pointX), float64(pointY), float64(marginLeft+graphWidth))
+}
+
+// drawGapHover draws a tooltip with the span without measurements under the mouse cursor
+func (dp *DevicePageState) drawGapHover(cr *cairo.Context, graphState *GraphState, gaps []anomaly.Gap,
+	marginLeft, marginTop, graphWidth, graphHeight int, startTime, endTime, now time.Time,
+) {
+	hoverTime := startTime.Add(time.Duration((graphState.hoverX - float64(marginLeft)) / float64(graphWidth) * float64(endTime.Sub(startTime))))
+
+	for _, gap := range gaps {
+		if hoverTime.Before(gap.From) || hoverTime.After(gap.To) {
+			continue
+		}
+
+		var tooltipText string
+		switch {
+		case gap.From.Equal(startTime) && gap.To.Equal(now):
+			tooltipText = "No data"
+		case gap.From.Equal(startTime):
+			tooltipText = "No data until " + gap.To.Local().Format("15:04")
+		case gap.To.Equal(now):
+			tooltipText = "No data since " + gap.From.Local().Format("15:04")
+		default:
+			tooltipText = fmt.Sprintf("No data from %s to %s (%s)", gap.From.Local().Format("15:04"),
+				gap.To.Local().Format("15:04"), stats.FormatDuration(gap.Duration()))
+		}
+
+		drawTooltipText(cr, tooltipText, graphState.hoverX, float64(marginTop+graphHeight/2), float64(marginLeft+graphWidth))
+		return
+	}
 }
 
 // drawTooltip draws a tooltip showing the measurement value
 func (dp *DevicePageState) drawTooltip(app *App, cr *cairo.Context, measurement models.Measurement, value float64,
-	metricInfo MetricInfo, pointX, pointY float64,
+	metricInfo MetricInfo, pointX, pointY, maxX float64,
 ) {
 	// Format the tooltip text
 	timeStr := measurement.Timestamp.Local().Format("15:04:05")
 	valueStr := app.formatValue(value, metricInfo.Unit)
 	tooltipText := fmt.Sprintf("%s - %s", timeStr, valueStr)
+	if measurement.Anomalies != 0 {
+		tooltipText += " (" + measurement.Anomalies.String() + ")"
+	}
+	drawTooltipText(cr, tooltipText, pointX, pointY, maxX)
+}
 
+// drawTooltipText draws a tooltip with the given text above a point,
+// keeping it left of maxX
+func drawTooltipText(cr *cairo.Context, tooltipText string, pointX, pointY, maxX float64) {
 	// Set font for measuring text
 	cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
 	cr.SetFontSize(12)
@@ -1033,7 +1657,10 @@ func (dp *DevicePageState) drawTooltip(app *App, cr *cairo.Context, measurement
 	tooltipX := pointX - tooltipWidth/2
 	tooltipY := pointY - tooltipHeight - 10
 
-	// Adjust if tooltip would go off the left edge
+	// Adjust if tooltip would go off the right or left edge
+	if tooltipX+tooltipWidth > maxX {
+		tooltipX = maxX - tooltipWidth
+	}
 	if tooltipX < 0 {
 		tooltipX = 0
 	}
@@ -1059,4 +1686,3 @@ func (dp *DevicePageState) drawTooltip(app *App, cr *cairo.Context, measurement
 	cr.MoveTo(tooltipX+padding, tooltipY+padding+textExtents.Height)
 	cr.ShowText(tooltipText)
 }
-
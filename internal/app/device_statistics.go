@@ -0,0 +1,197 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+)
+
+// statisticsPeriods are the periods the statistics group can summarize
+var statisticsPeriods = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"24 hours", 24 * time.Hour},
+	{"7 days", 7 * 24 * time.Hour},
+	{"30 days", 30 * 24 * time.Hour},
+}
+
+// defaultStatisticsPeriod is the index of the period shown until another is picked
+const defaultStatisticsPeriod = 1
+
+// statisticsMetricTypes maps statistics metrics to the metrics of the graph
+var statisticsMetricTypes = map[stats.Metric]MetricType{
+	stats.MetricScore:       MetricScore,
+	stats.MetricTemperature: MetricTemperature,
+	stats.MetricHumidity:    MetricHumidity,
+	stats.MetricCO2:         MetricCO2,
+	stats.MetricVOC:         MetricVOC,
+	stats.MetricPM25:        MetricPM25,
+}
+
+// StatisticsState holds the statistics group of the device page
+type StatisticsState struct {
+	group    *adw.PreferencesGroup
+	rows     []gtk.Widgetter // Rows showing the current report
+	deviceID uint
+	serial   string
+}
+
+// addStatisticsGroup creates the group summarizing the device's measurements over a period
+func (dp *DevicePageState) addStatisticsGroup(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle("Statistics")
+
+	state := &StatisticsState{
+		group:    group,
+		deviceID: deviceData.Device.ID,
+		serial:   deviceData.Device.SerialNumber,
+	}
+	dp.currentStatistics = state
+
+	names := make([]string, len(statisticsPeriods))
+	for i, period := range statisticsPeriods {
+		names[i] = period.name
+	}
+
+	periodDropdown := gtk.NewDropDownFromStrings(names)
+	periodDropdown.SetVAlign(gtk.AlignCenter)
+	periodDropdown.SetSelected(uint(dp.statisticsPeriodIndex()))
+	periodDropdown.Connect("notify::selected", func() {
+		dp.statisticsPeriod = statisticsPeriods[periodDropdown.Selected()].duration
+		dp.loadStatistics(app, state)
+	})
+	group.SetHeaderSuffix(periodDropdown)
+
+	container.Append(group)
+
+	dp.loadStatistics(app, state)
+}
+
+// statisticsPeriodIndex returns the index of the selected statistics period
+func (dp *DevicePageState) statisticsPeriodIndex() int {
+	for i, period := range statisticsPeriods {
+		if period.duration == dp.statisticsPeriod {
+			return i
+		}
+	}
+	return defaultStatisticsPeriod
+}
+
+// loadStatistics computes the statistics in the background, the
+// measurements of a month take a moment to go through
+func (dp *DevicePageState) loadStatistics(app *App, state *StatisticsState) {
+	period := statisticsPeriods[dp.statisticsPeriodIndex()]
+	state.group.SetDescription("Loading the last " + period.name + "…")
+
+	go func() {
+		to := time.Now()
+		report, err := stats.ForDevice(globals.StatisticsDB(), state.deviceID, to.Add(-period.duration), to)
+
+		glib.IdleAdd(func() bool {
+			// The page may have moved on to another device or been rebuilt
+			if dp.currentStatistics != state || dp.currentDeviceSerial != state.serial {
+				return false
+			}
+
+			if err != nil {
+				app.logger.Error("Failed to compute statistics", "device_id", state.deviceID, "error", err)
+				state.group.SetDescription("Failed to compute statistics")
+				state.setRows(nil)
+				return false
+			}
+
+			state.group.SetDescription(fmt.Sprintf("Last %s, %d measurements", period.name, report.Count))
+			state.setRows(app.statisticsRows(report))
+			return false
+		})
+	}()
+}
+
+// setRows replaces the rows of the group
+func (state *StatisticsState) setRows(rows []gtk.Widgetter) {
+	for _, row := range state.rows {
+		state.group.Remove(row)
+	}
+
+	state.rows = rows
+	for _, row := range rows {
+		state.group.Add(row)
+	}
+}
+
+// statisticsRows creates the rows showing a statistics report
+func (app *App) statisticsRows(report stats.Report) []gtk.Widgetter {
+	if report.Count == 0 {
+		row := adw.NewActionRow()
+		row.SetTitle("No measurements in this period")
+		row.AddCSSClass("padded-row")
+		return []gtk.Widgetter{row}
+	}
+
+	preferences := globals.Settings.UnitPreferences()
+	metricInfos := getMetricInfo(preferences)
+	var rows []gtk.Widgetter
+
+	for _, metric := range stats.Metrics {
+		metricType := statisticsMetricTypes[metric]
+		unit := metricInfos[metricType].Unit
+
+		summary := report.Metrics[metric]
+		switch metric {
+		case stats.MetricTemperature:
+			summary = summary.Map(preferences.Temperature.FromCelsius)
+		case stats.MetricVOC:
+			summary = summary.Map(preferences.VOC.FromPPB)
+		}
+
+		row := adw.NewActionRow()
+		row.SetTitle(metric.DisplayName())
+		row.SetSubtitle(fmt.Sprintf("Min %s · Median %s · P95 %s · Max %s",
+			app.formatValue(summary.Min, unit),
+			app.formatValue(summary.Median, unit),
+			app.formatValue(summary.P95, unit),
+			app.formatValue(summary.Max, unit),
+		))
+		row.AddCSSClass("padded-row")
+
+		meanLabel := gtk.NewLabel("Mean " + app.formatValue(summary.Mean, unit))
+		meanLabel.AddCSSClass("numeric")
+		row.AddSuffix(meanLabel)
+
+		rows = append(rows, row)
+	}
+
+	for _, band := range stats.Bands {
+		row := adw.NewActionRow()
+		row.SetTitle(band.DisplayName() + " air")
+		row.SetSubtitle("Time spent in the band")
+		row.AddCSSClass("padded-row")
+
+		valueLabel := gtk.NewLabel(fmt.Sprintf("%s · %.0f%%",
+			stats.FormatDuration(report.TimeInBand[band]), report.BandShare(band)*100))
+		valueLabel.AddCSSClass("numeric")
+		row.AddSuffix(valueLabel)
+
+		rows = append(rows, row)
+	}
+
+	hours := make([]string, 0, len(report.WorstHours))
+	for _, hour := range report.WorstHours {
+		hours = append(hours, fmt.Sprintf("%02d:00 (score %.0f)", hour.Hour, hour.Score))
+	}
+
+	worstRow := adw.NewActionRow()
+	worstRow.SetTitle("Worst Hours")
+	worstRow.SetSubtitle(strings.Join(hours, " · "))
+	worstRow.AddCSSClass("padded-row")
+	rows = append(rows, worstRow)
+
+	return rows
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
+	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/ventilation"
+)
+
+// ventilationPeriod is how far back the ventilation of a room is estimated
+// from, long enough to include several times the room emptied
+const ventilationPeriod = 7 * 24 * time.Hour
+
+// VentilationState holds the ventilation group of the device page
+type VentilationState struct {
+	group  *adw.PreferencesGroup
+	rows   []gtk.Widgetter // Rows showing the current estimate
+	device models.Device
+	serial string
+}
+
+// addVentilationGroup creates the group estimating the ventilation and
+// occupancy of the device's room from its CO₂
+func (dp *DevicePageState) addVentilationGroup(app *App, container *gtk.Box, deviceData *DeviceWithMeasurement) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle("Ventilation")
+
+	state := &VentilationState{
+		group:  group,
+		device: deviceData.Device,
+		serial: deviceData.Device.SerialNumber,
+	}
+	dp.currentVentilation = state
+
+	container.Append(group)
+
+	dp.loadVentilation(app, state)
+}
+
+// loadVentilation estimates the ventilation in the background
+func (dp *DevicePageState) loadVentilation(app *App, state *VentilationState) {
+	period := fmt.Sprintf("Last %.0f days", ventilationPeriod.Hours()/24)
+	state.group.SetDescription(period + ", loading…")
+
+	go func() {
+		to := time.Now()
+		estimate, err := ventilation.ForDevice(globals.StatisticsDB(), state.device, to.Add(-ventilationPeriod), to)
+
+		glib.IdleAdd(func() bool {
+			// The page may have moved on to another device or been rebuilt
+			if dp.currentVentilation != state || dp.currentDeviceSerial != state.serial {
+				return false
+			}
+
+			if err != nil {
+				app.logger.Error("Failed to estimate ventilation", "device_id", state.device.ID, "error", err)
+				state.group.SetDescription("Failed to estimate the ventilation")
+				state.setRows(nil)
+				return false
+			}
+
+			state.group.SetDescription(period + ", estimated from CO₂")
+			state.setRows(ventilationRows(estimate))
+			return false
+		})
+	}()
+}
+
+// setRows replaces the rows of the group
+func (state *VentilationState) setRows(rows []gtk.Widgetter) {
+	for _, row := range state.rows {
+		state.group.Remove(row)
+	}
+
+	state.rows = rows
+	for _, row := range rows {
+		state.group.Add(row)
+	}
+}
+
+// ventilationRows creates the rows showing a ventilation estimate
+func ventilationRows(estimate ventilation.Estimate) []gtk.Widgetter {
+	preferences := globals.Settings.UnitPreferences()
+
+	newRow := func(title, subtitle, value string) gtk.Widgetter {
+		row := adw.NewActionRow()
+		row.SetTitle(title)
+		row.SetSubtitle(subtitle)
+		row.AddCSSClass("padded-row")
+
+		valueLabel := gtk.NewLabel(value)
+		valueLabel.AddCSSClass("numeric")
+		row.AddSuffix(valueLabel)
+		return row
+	}
+
+	var rows []gtk.Widgetter
+
+	airChanges, ok := estimate.AirChanges()
+	if !ok {
+		rows = append(rows, newRow("Air Changes", "CO₂ never fell for long enough to tell", "–"))
+	} else {
+		rows = append(rows, newRow("Air Changes",
+			fmt.Sprintf("Estimated from %d falls of CO₂", len(estimate.Decays)),
+			preferences.FormatNumber(airChanges, 1)+" per hour"))
+	}
+
+	if estimate.Volume <= 0 {
+		rows = append(rows, newRow("Outdoor Airflow", "Set the room volume under Location to estimate", "–"))
+	} else if airflow, ok := estimate.Airflow(); ok {
+		rows = append(rows, newRow("Outdoor Airflow",
+			fmt.Sprintf("For a room of %s m³", preferences.FormatNumber(estimate.Volume, 0)),
+			preferences.FormatNumber(airflow, 0)+" m³/h"))
+	}
+
+	if perPerson, ok := estimate.AirflowPerPerson(); ok {
+		rows = append(rows, newRow("Airflow per Person",
+			fmt.Sprintf("EN 16798-1 %s at ~%.0f people", ventilation.AirflowCategory(perPerson).Name, math.Round(estimate.PeakPeople())),
+			preferences.FormatNumber(perPerson, 1)+" l/s"))
+	}
+
+	occupiedSubtitle := "No rise of CO₂ from people found"
+	if count := len(estimate.Occupancies); count > 0 {
+		last := estimate.Occupancies[count-1]
+		occupiedSubtitle = fmt.Sprintf("%d periods, the last %s–%s", count,
+			last.From.Local().Format("Mon 15:04"), last.To.Local().Format("15:04"))
+		if last.People > 0 {
+			occupiedSubtitle += fmt.Sprintf(" with ~%.0f people", math.Max(1, math.Round(last.People)))
+		}
+	}
+	rows = append(rows, newRow("Occupied", occupiedSubtitle, stats.FormatDuration(estimate.OccupiedTime())))
+
+	return rows
+}
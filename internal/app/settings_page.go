@@ -1,9 +1,14 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	adw "github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	gio "github.com/diamondburned/gotk4/pkg/gio/v2"
 	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
 	gtk "github.com/diamondburned/gotk4/pkg/gtk/v4"
 	pango "github.com/diamondburned/gotk4/pkg/pango"
@@ -11,6 +16,7 @@ import (
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/licenses"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/version"
 )
 
@@ -19,7 +25,18 @@ type SettingsPageState struct {
 	// UI widget references for potential future use
 	visibilitySwitch    *gtk.Switch
 	deviceDropdown      *gtk.DropDown
+	deviceList          *gtk.StringList
 	retentionSpinButton *gtk.SpinButton
+	backupIntervalSpin  *gtk.SpinButton
+	backupCountSpin     *gtk.SpinButton
+	sizeLabel           *gtk.Label
+	temperatureUnitRow  *adw.ComboRow
+	vocUnitRow          *adw.ComboRow
+	weeklyReportSwitch  *gtk.Switch
+	anomaliesSwitch     *gtk.Switch
+	co2ThresholdSpin    *gtk.SpinButton
+
+	syncing bool // Set while widgets are updated from settings changed elsewhere
 }
 
 // SettingsPageState methods
@@ -72,8 +89,77 @@ func (sp *SettingsPageState) setup(app *App) {
 	sp.setupDropdown(app, deviceRow)
 
 	shellGroup.Add(deviceRow)
+
+	// CO₂ threshold row
+	co2ThresholdRow := adw.NewActionRow()
+	co2ThresholdRow.SetTitle("CO₂ Threshold")
+	co2ThresholdRow.SetSubtitle("Level to ventilate at, the forecast tells how soon it will be reached")
+	co2ThresholdRow.AddCSSClass("padded-row")
+
+	sp.co2ThresholdSpin = sp.newSpinButton(globals.Settings.CO2Threshold,
+		config.MinCO2Threshold, config.MaxCO2Threshold, func(ppm int) {
+			sp.onCO2ThresholdChanged(app, ppm)
+		})
+	sp.co2ThresholdSpin.SetIncrements(50, 200)
+	co2ThresholdRow.AddSuffix(sp.spinButtonWithUnit(sp.co2ThresholdSpin, "ppm"))
+	shellGroup.Add(co2ThresholdRow)
+
 	contentBox.Append(shellGroup)
 
+	// Units settings group
+	unitsGroup := adw.NewPreferencesGroup()
+	unitsGroup.SetTitle("Units")
+	unitsGroup.SetDescription("Configure how measurements are displayed")
+	unitsGroup.SetMarginStart(12)
+	unitsGroup.SetMarginEnd(12)
+
+	sp.setupUnitRows(app)
+	unitsGroup.Add(sp.temperatureUnitRow)
+	unitsGroup.Add(sp.vocUnitRow)
+	contentBox.Append(unitsGroup)
+
+	// Reports settings group
+	reportsGroup := adw.NewPreferencesGroup()
+	reportsGroup.SetTitle("Reports")
+	reportsGroup.SetDescription("Summaries of the air quality over time")
+	reportsGroup.SetMarginStart(12)
+	reportsGroup.SetMarginEnd(12)
+
+	weeklyReportRow := adw.NewActionRow()
+	weeklyReportRow.SetTitle("Weekly Report")
+	weeklyReportRow.SetSubtitle("Get a notification with a report of the previous week on Monday mornings")
+	weeklyReportRow.AddCSSClass("padded-row")
+
+	sp.weeklyReportSwitch = gtk.NewSwitch()
+	sp.weeklyReportSwitch.SetVAlign(gtk.AlignCenter)
+	sp.weeklyReportSwitch.SetActive(globals.Settings.WeeklyReport)
+	sp.weeklyReportSwitch.Connect("state-set", func(state bool) bool {
+		sp.onWeeklyReportChanged(app, state)
+		return false // Allow the state change to proceed
+	})
+	weeklyReportRow.AddSuffix(sp.weeklyReportSwitch)
+	weeklyReportRow.SetActivatableWidget(sp.weeklyReportSwitch)
+
+	reportsGroup.Add(weeklyReportRow)
+
+	anomaliesRow := adw.NewActionRow()
+	anomaliesRow.SetTitle("Exclude Anomalies")
+	anomaliesRow.SetSubtitle("Leave measurements that look like sensor faults out of statistics, heatmaps and reports")
+	anomaliesRow.AddCSSClass("padded-row")
+
+	sp.anomaliesSwitch = gtk.NewSwitch()
+	sp.anomaliesSwitch.SetVAlign(gtk.AlignCenter)
+	sp.anomaliesSwitch.SetActive(globals.Settings.ExcludeAnomalies)
+	sp.anomaliesSwitch.Connect("state-set", func(state bool) bool {
+		sp.onExcludeAnomaliesChanged(app, state)
+		return false // Allow the state change to proceed
+	})
+	anomaliesRow.AddSuffix(sp.anomaliesSwitch)
+	anomaliesRow.SetActivatableWidget(sp.anomaliesSwitch)
+
+	reportsGroup.Add(anomaliesRow)
+	contentBox.Append(reportsGroup)
+
 	// Data Retention settings group
 	dataGroup := adw.NewPreferencesGroup()
 	dataGroup.SetTitle("Data Management")
@@ -88,25 +174,12 @@ func (sp *SettingsPageState) setup(app *App) {
 	retentionRow.AddCSSClass("padded-row")
 
 	// Create spin button for retention period
-	retentionAdjustment := gtk.NewAdjustment(float64(globals.Settings.DataRetentionPeriod), 1, 365, 1, 7, 0)
-	sp.retentionSpinButton = gtk.NewSpinButton(retentionAdjustment, 1, 0)
-	sp.retentionSpinButton.SetVAlign(gtk.AlignCenter)
-	sp.retentionSpinButton.SetValue(float64(globals.Settings.DataRetentionPeriod))
-
-	// Connect to value changes
-	sp.retentionSpinButton.ConnectValueChanged(func() {
-		sp.onRetentionChanged(app, int(sp.retentionSpinButton.Value()))
-	})
+	sp.retentionSpinButton = sp.newSpinButton(globals.Settings.DataRetentionPeriod,
+		config.MinDataRetentionPeriod, config.MaxDataRetentionPeriod, func(days int) {
+			sp.onRetentionChanged(app, days)
+		})
 
-	// Add suffix label for "days"
-	suffixBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
-	suffixBox.Append(sp.retentionSpinButton)
-	daysLabel := gtk.NewLabel("days")
-	daysLabel.AddCSSClass("dim-label")
-	daysLabel.SetVAlign(gtk.AlignCenter)
-	suffixBox.Append(daysLabel)
-
-	retentionRow.AddSuffix(suffixBox)
+	retentionRow.AddSuffix(sp.spinButtonWithUnit(sp.retentionSpinButton, "days"))
 	dataGroup.Add(retentionRow)
 
 	// Database size row
@@ -116,30 +189,69 @@ func (sp *SettingsPageState) setup(app *App) {
 	sizeRow.AddCSSClass("padded-row")
 
 	// Get and format database size
-	sizeLabel := gtk.NewLabel("Calculating...")
-	sizeLabel.AddCSSClass("dim-label")
-	sizeLabel.SetVAlign(gtk.AlignCenter)
-
-	// Load size asynchronously to avoid blocking UI
-	go func() {
-		if size, err := database.GetSize(); err == nil {
-			sizeText := sp.formatFileSize(size)
-			// Update UI from main thread
-			glib.IdleAdd(func() bool {
-				sizeLabel.SetText(sizeText)
-				return false
-			})
-		} else {
-			glib.IdleAdd(func() bool {
-				sizeLabel.SetText("Error reading size")
-				return false
-			})
-		}
-	}()
+	sp.sizeLabel = gtk.NewLabel("Calculating...")
+	sp.sizeLabel.AddCSSClass("dim-label")
+	sp.sizeLabel.SetVAlign(gtk.AlignCenter)
+	sp.updateDatabaseSize()
 
-	sizeRow.AddSuffix(sizeLabel)
+	sizeRow.AddSuffix(sp.sizeLabel)
 	dataGroup.Add(sizeRow)
 
+	// Automatic backup rows
+	backupIntervalRow := adw.NewActionRow()
+	backupIntervalRow.SetTitle("Automatic Backups")
+	backupIntervalRow.SetSubtitle("Days between backups of the database, 0 turns them off")
+	backupIntervalRow.AddCSSClass("padded-row")
+
+	sp.backupIntervalSpin = sp.newSpinButton(globals.Settings.BackupInterval,
+		config.MinBackupInterval, config.MaxBackupInterval, func(value int) {
+			sp.onBackupSettingChanged(app, config.KeyBackupInterval, value)
+		})
+	backupIntervalRow.AddSuffix(sp.spinButtonWithUnit(sp.backupIntervalSpin, "days"))
+	dataGroup.Add(backupIntervalRow)
+
+	backupCountRow := adw.NewActionRow()
+	backupCountRow.SetTitle("Backups to Keep")
+	backupCountRow.SetSubtitle("Older automatic backups are deleted")
+	backupCountRow.AddCSSClass("padded-row")
+
+	sp.backupCountSpin = sp.newSpinButton(globals.Settings.BackupCount,
+		config.MinBackupCount, config.MaxBackupCount, func(value int) {
+			sp.onBackupSettingChanged(app, config.KeyBackupCount, value)
+		})
+	backupCountRow.AddSuffix(sp.backupCountSpin)
+	dataGroup.Add(backupCountRow)
+
+	// Manual backup and restore rows
+	backupRow := adw.NewActionRow()
+	backupRow.SetTitle("Back Up Database")
+	backupRow.SetSubtitle("Save a copy of all measurements and devices")
+	backupRow.AddCSSClass("padded-row")
+
+	backupButton := gtk.NewButton()
+	backupButton.SetLabel("Back Up…")
+	backupButton.SetVAlign(gtk.AlignCenter)
+	backupButton.ConnectClicked(func() {
+		sp.onBackupClicked(app)
+	})
+	backupRow.AddSuffix(backupButton)
+	dataGroup.Add(backupRow)
+
+	restoreRow := adw.NewActionRow()
+	restoreRow.SetTitle("Restore Database")
+	restoreRow.SetSubtitle("Replace all data with a backup")
+	restoreRow.AddCSSClass("padded-row")
+
+	restoreButton := gtk.NewButton()
+	restoreButton.SetLabel("Restore…")
+	restoreButton.SetVAlign(gtk.AlignCenter)
+	restoreButton.AddCSSClass("destructive-action")
+	restoreButton.ConnectClicked(func() {
+		sp.onRestoreClicked(app)
+	})
+	restoreRow.AddSuffix(restoreButton)
+	dataGroup.Add(restoreRow)
+
 	contentBox.Append(dataGroup)
 
 	// About/License settings group
@@ -241,6 +353,7 @@ func (sp *SettingsPageState) show(app *App) {
 func (sp *SettingsPageState) setupDropdown(app *App, deviceRow *adw.ActionRow) {
 	// Create string list model for the dropdown
 	stringList := gtk.NewStringList(nil)
+	sp.deviceList = stringList
 
 	// Create dropdown
 	sp.deviceDropdown = gtk.NewDropDown(stringList, nil)
@@ -295,8 +408,43 @@ func (sp *SettingsPageState) refreshDropdown(app *App, stringList *gtk.StringLis
 	sp.deviceDropdown.SetSelected(uint(selectedIndex))
 }
 
+// syncSettings updates the widgets after settings were changed elsewhere
+func (sp *SettingsPageState) syncSettings(app *App) {
+	if sp.deviceDropdown == nil {
+		return
+	}
+
+	sp.syncing = true
+	defer func() { sp.syncing = false }()
+
+	sp.visibilitySwitch.SetActive(globals.Settings.ShowShellExtension)
+	sp.retentionSpinButton.SetValue(float64(globals.Settings.DataRetentionPeriod))
+	sp.backupIntervalSpin.SetValue(float64(globals.Settings.BackupInterval))
+	sp.backupCountSpin.SetValue(float64(globals.Settings.BackupCount))
+	sp.weeklyReportSwitch.SetActive(globals.Settings.WeeklyReport)
+	sp.anomaliesSwitch.SetActive(globals.Settings.ExcludeAnomalies)
+	sp.co2ThresholdSpin.SetValue(float64(globals.Settings.CO2Threshold))
+	sp.refreshDropdown(app, sp.deviceList)
+
+	preferences := globals.Settings.UnitPreferences()
+	for i, unit := range units.TemperatureUnits {
+		if unit == preferences.Temperature {
+			sp.temperatureUnitRow.SetSelected(uint(i))
+		}
+	}
+	for i, unit := range units.VOCUnits {
+		if unit == preferences.VOC {
+			sp.vocUnitRow.SetSelected(uint(i))
+		}
+	}
+}
+
 // onDeviceSelectionChanged handles device selection changes in the dropdown
 func (sp *SettingsPageState) onSelectionChanged(app *App, selectedIndex uint32, stringList *gtk.StringList) {
+	if sp.syncing {
+		return
+	}
+
 	if selectedIndex == 0 {
 		// "No device selected" option chosen
 		globals.Settings.StatusBarDeviceSerialNumber = nil
@@ -316,16 +464,9 @@ func (sp *SettingsPageState) onSelectionChanged(app *App, selectedIndex uint32,
 		}
 	}
 
-	// Save settings
-	err := globals.Settings.Save()
-	if err != nil {
+	// Save settings and update shell extension with new selection
+	if err := app.applySettings(config.KeyStatusBarDeviceSerialNumber); err != nil {
 		app.logger.Error("Failed to save settings", "error", err)
-		return
-	}
-
-	// Update shell extension with new selection
-	if app.dbusService != nil {
-		app.dbusService.EmitDeviceUpdated()
 	}
 }
 
@@ -343,40 +484,307 @@ func (sp *SettingsPageState) setupToggle(app *App) {
 
 // onVisibilityToggleChanged handles changes to the shell extension visibility setting
 func (sp *SettingsPageState) onToggleChanged(app *App, visible bool) {
+	if sp.syncing {
+		return
+	}
+
 	app.logger.Info("Shell extension visibility changed", "visible", visible)
 
 	// Update settings
 	globals.Settings.ShowShellExtension = visible
 
-	// Save settings
-	err := globals.Settings.Save()
-	if err != nil {
+	// Save settings and update shell extension
+	if err := app.applySettings(config.KeyShowShellExtension); err != nil {
 		app.logger.Error("Failed to save visibility setting", "error", err)
+	}
+}
+
+// onWeeklyReportChanged handles turning the weekly report notification on and off
+func (sp *SettingsPageState) onWeeklyReportChanged(app *App, enabled bool) {
+	if sp.syncing {
+		return
+	}
+
+	app.logger.Info("Weekly report changed", "enabled", enabled)
+
+	globals.Settings.WeeklyReport = enabled
+
+	if err := app.applySettings(config.KeyWeeklyReport); err != nil {
+		app.logger.Error("Failed to save weekly report setting", "error", err)
+	}
+}
+
+// onExcludeAnomaliesChanged handles leaving anomalies out of statistics or not
+func (sp *SettingsPageState) onExcludeAnomaliesChanged(app *App, exclude bool) {
+	if sp.syncing {
+		return
+	}
+
+	app.logger.Info("Exclude anomalies changed", "exclude", exclude)
+
+	globals.Settings.ExcludeAnomalies = exclude
+
+	if err := app.applySettings(config.KeyExcludeAnomalies); err != nil {
+		app.logger.Error("Failed to save exclude anomalies setting", "error", err)
+	}
+}
+
+// onCO2ThresholdChanged handles changes to the CO₂ level to ventilate at
+func (sp *SettingsPageState) onCO2ThresholdChanged(app *App, ppm int) {
+	if sp.syncing {
+		return
+	}
+
+	if err := globals.Settings.Set(config.KeyCO2Threshold, ppm); err != nil {
+		app.logger.Error("Invalid CO₂ threshold", "ppm", ppm, "error", err)
+		return
+	}
+
+	app.logger.Info("CO₂ threshold changed", "ppm", ppm)
+
+	if err := app.applySettings(config.KeyCO2Threshold); err != nil {
+		app.logger.Error("Failed to save CO₂ threshold", "error", err)
+	}
+}
+
+// setupUnitRows creates the unit selection rows
+func (sp *SettingsPageState) setupUnitRows(app *App) {
+	temperatureNames := make([]string, 0, len(units.TemperatureUnits))
+	selectedTemperature := uint(0)
+	for i, unit := range units.TemperatureUnits {
+		temperatureNames = append(temperatureNames, fmt.Sprintf("%s (%s)", unit.DisplayName(), unit.Symbol()))
+		if unit == globals.Settings.UnitPreferences().Temperature {
+			selectedTemperature = uint(i)
+		}
+	}
+
+	sp.temperatureUnitRow = adw.NewComboRow()
+	sp.temperatureUnitRow.SetTitle("Temperature")
+	sp.temperatureUnitRow.SetSubtitle("Unit used for temperatures")
+	sp.temperatureUnitRow.AddCSSClass("padded-row")
+	sp.temperatureUnitRow.SetModel(gtk.NewStringList(temperatureNames))
+	sp.temperatureUnitRow.SetSelected(selectedTemperature)
+	sp.temperatureUnitRow.Connect("notify::selected", func() {
+		index := int(sp.temperatureUnitRow.Selected())
+		if index >= 0 && index < len(units.TemperatureUnits) {
+			sp.onUnitsChanged(app, config.KeyTemperatureUnit, func() {
+				globals.Settings.TemperatureUnit = units.TemperatureUnits[index]
+			})
+		}
+	})
+
+	vocNames := make([]string, 0, len(units.VOCUnits))
+	selectedVOC := uint(0)
+	for i, unit := range units.VOCUnits {
+		vocNames = append(vocNames, unit.Symbol())
+		if unit == globals.Settings.UnitPreferences().VOC {
+			selectedVOC = uint(i)
+		}
+	}
+
+	sp.vocUnitRow = adw.NewComboRow()
+	sp.vocUnitRow.SetTitle("VOC")
+	sp.vocUnitRow.SetSubtitle("µg/m³ assumes the standard TVOC mixture")
+	sp.vocUnitRow.AddCSSClass("padded-row")
+	sp.vocUnitRow.SetModel(gtk.NewStringList(vocNames))
+	sp.vocUnitRow.SetSelected(selectedVOC)
+	sp.vocUnitRow.Connect("notify::selected", func() {
+		index := int(sp.vocUnitRow.Selected())
+		if index >= 0 && index < len(units.VOCUnits) {
+			sp.onUnitsChanged(app, config.KeyVOCUnit, func() {
+				globals.Settings.VOCUnit = units.VOCUnits[index]
+			})
+		}
+	})
+}
+
+// onUnitsChanged applies a unit change, saves it and refreshes everything that displays measurements
+func (sp *SettingsPageState) onUnitsChanged(app *App, key string, apply func()) {
+	if sp.syncing {
 		return
 	}
 
-	// Update shell extension
-	if app.dbusService != nil {
-		app.dbusService.EmitVisibilityChanged()
+	apply()
+	app.logger.Info("Units changed",
+		"temperature", globals.Settings.TemperatureUnit,
+		"voc", globals.Settings.VOCUnit)
+
+	// Save settings and refresh everything that displays measurements
+	if err := app.applySettings(key); err != nil {
+		app.logger.Error("Failed to save unit settings", "error", err)
 	}
 }
 
 // onRetentionPeriodChanged handles changes to the data retention period setting
 func (sp *SettingsPageState) onRetentionChanged(app *App, days int) {
+	if sp.syncing {
+		return
+	}
+
 	app.logger.Info("Data retention period changed", "new_days", days, "old_days", globals.Settings.DataRetentionPeriod)
 
 	// Update settings
 	globals.Settings.DataRetentionPeriod = days
 
-	// Save settings
-	err := globals.Settings.Save()
-	if err != nil {
+	// Save settings and trigger immediate cleanup with new retention period
+	if err := app.applySettings(config.KeyDataRetentionPeriod); err != nil {
 		app.logger.Error("Failed to save retention period setting", "error", err)
+	}
+}
+
+// newSpinButton creates a spin button for a whole number setting
+func (sp *SettingsPageState) newSpinButton(value int, min int, max int, onChange func(int)) *gtk.SpinButton {
+	adjustment := gtk.NewAdjustment(float64(value), float64(min), float64(max), 1, 7, 0)
+	spinButton := gtk.NewSpinButton(adjustment, 1, 0)
+	spinButton.SetVAlign(gtk.AlignCenter)
+	spinButton.SetValue(float64(value))
+	spinButton.ConnectValueChanged(func() {
+		onChange(int(spinButton.Value()))
+	})
+	return spinButton
+}
+
+// spinButtonWithUnit puts a unit label after a spin button
+func (sp *SettingsPageState) spinButtonWithUnit(spinButton *gtk.SpinButton, unit string) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	box.Append(spinButton)
+
+	label := gtk.NewLabel(unit)
+	label.AddCSSClass("dim-label")
+	label.SetVAlign(gtk.AlignCenter)
+	box.Append(label)
+
+	return box
+}
+
+// onBackupSettingChanged handles changes to the automatic backup settings
+func (sp *SettingsPageState) onBackupSettingChanged(app *App, key string, value int) {
+	if sp.syncing {
+		return
+	}
+
+	if err := globals.Settings.Set(key, value); err != nil {
+		app.logger.Error("Invalid backup setting", "key", key, "value", value, "error", err)
 		return
 	}
 
-	// Trigger immediate cleanup with new retention period
-	app.cleanupOldMeasurements()
+	app.logger.Info("Backup setting changed", "key", key, "value", value)
+
+	if err := app.applySettings(key); err != nil {
+		app.logger.Error("Failed to save backup setting", "key", key, "error", err)
+	}
+}
+
+// updateDatabaseSize shows the current size of the database
+func (sp *SettingsPageState) updateDatabaseSize() {
+	// Load size asynchronously to avoid blocking UI
+	go func() {
+		sizeText := "Error reading size"
+		if size, err := database.GetSize(); err == nil {
+			sizeText = sp.formatFileSize(size)
+		}
+
+		// Update UI from main thread
+		glib.IdleAdd(func() bool {
+			sp.sizeLabel.SetText(sizeText)
+			return false
+		})
+	}()
+}
+
+// onBackupClicked asks where to save a backup of the database and writes it
+func (sp *SettingsPageState) onBackupClicked(app *App) {
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle("Back Up Database")
+	dialog.SetInitialFolder(gio.NewFileForPath(config.BackupDir()))
+	dialog.SetInitialName(filepath.Base(database.DefaultBackupPath(time.Now())))
+
+	dialog.Save(context.Background(), &app.mainWindow.Window, func(result gio.AsyncResulter) {
+		file, err := dialog.SaveFinish(result)
+		if err != nil {
+			return // Cancelled
+		}
+		path := file.Path()
+
+		go func() {
+			// The file dialog already asked whether to replace an existing file
+			os.Remove(path)
+
+			err := database.Backup(database.DB, path)
+			glib.IdleAdd(func() bool {
+				if err != nil {
+					app.logger.Error("Failed to back up database", "path", path, "error", err)
+					sp.showMessage(app, "Backup Failed", err.Error())
+				} else {
+					app.logger.Info("Backed up database", "path", path)
+					sp.showMessage(app, "Backup Complete", "The database was saved to "+path)
+				}
+				return false
+			})
+		}()
+	})
+}
+
+// onRestoreClicked asks for a backup and replaces the database with it after confirmation
+func (sp *SettingsPageState) onRestoreClicked(app *App) {
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle("Restore Database")
+	dialog.SetInitialFolder(gio.NewFileForPath(config.BackupDir()))
+
+	dialog.Open(context.Background(), &app.mainWindow.Window, func(result gio.AsyncResulter) {
+		file, err := dialog.OpenFinish(result)
+		if err != nil {
+			return // Cancelled
+		}
+		path := file.Path()
+
+		confirm := adw.NewMessageDialog(&app.mainWindow.Window, "Restore Database?",
+			"All current measurements and devices will be replaced with the ones in "+filepath.Base(path)+
+				". A backup of the current database is made first.")
+		confirm.AddResponse("cancel", "Cancel")
+		confirm.AddResponse("restore", "Restore")
+		confirm.SetResponseAppearance("restore", adw.ResponseDestructive)
+		confirm.SetDefaultResponse("cancel")
+		confirm.SetCloseResponse("cancel")
+
+		confirm.ConnectResponse(func(response string) {
+			confirm.Destroy()
+			if response == "restore" {
+				sp.restore(app, path)
+			}
+		})
+
+		confirm.Present()
+	})
+}
+
+// restore replaces the database with a backup and reloads everything that shows its data
+func (sp *SettingsPageState) restore(app *App, path string) {
+	go func() {
+		snapshot, err := app.restoreDatabase(path)
+
+		glib.IdleAdd(func() bool {
+			if err != nil {
+				app.logger.Error("Failed to restore database", "path", path, "error", err)
+				sp.showMessage(app, "Restore Failed", err.Error())
+				return false
+			}
+
+			sp.showMessage(app, "Restore Complete", "The previous database was saved to "+snapshot)
+			return false
+		})
+	}()
+}
+
+// showMessage shows a dialog with a message and a close button
+func (sp *SettingsPageState) showMessage(app *App, heading string, body string) {
+	dialog := adw.NewMessageDialog(&app.mainWindow.Window, heading, body)
+	dialog.AddResponse("close", "Close")
+	dialog.SetDefaultResponse("close")
+	dialog.ConnectResponse(func(response string) {
+		dialog.Destroy()
+	})
+	dialog.Present()
 }
 
 // formatFileSize formats bytes into a human-readable string
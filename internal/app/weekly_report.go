@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gio "github.com/diamondburned/gotk4/pkg/gio/v2"
+	glib "github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/report"
+)
+
+const (
+	// WEEKLY_REPORT_HOUR is the hour on Monday from which the weekly report is sent
+	WEEKLY_REPORT_HOUR = 8
+
+	// weeklyReportSections is how many sections are summarized in the notification
+	weeklyReportSections = 3
+)
+
+// setupReportActions registers the action that opens a report from a notification
+func (app *App) setupReportActions() {
+	openReport := gio.NewSimpleAction("open-report", glib.NewVariantType("s"))
+	openReport.ConnectActivate(func(parameter *glib.Variant) {
+		path := parameter.String()
+		if err := gio.AppInfoLaunchDefaultForURI(gio.NewFileForPath(path).URI(), nil); err != nil {
+			app.logger.Error("Failed to open report", "path", path, "error", err)
+		}
+	})
+	app.AddAction(openReport)
+}
+
+// sendWeeklyReportIfDue saves a report of last week and sends a notification
+// about it on Monday mornings. The saved report marks the week as done, so
+// restarting the app doesn't send it again.
+func (app *App) sendWeeklyReportIfDue() {
+	app.reportMutex.Lock()
+	defer app.reportMutex.Unlock()
+
+	now := time.Now()
+	if !globals.Settings.WeeklyReport || now.Weekday() != time.Monday || now.Hour() < WEEKLY_REPORT_HOUR {
+		return
+	}
+
+	from, _ := report.PeriodWeek.Bounds(now)
+	path := filepath.Join(config.ReportDir(), "weekly-report-"+from.Format("2006-01-02")+".html")
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+
+	var devices []models.Device
+	if err := database.DB.Order("id").Find(&devices).Error; err != nil {
+		app.logger.Error("Failed to load devices for the weekly report", "error", err)
+		return
+	}
+	if len(devices) == 0 {
+		return
+	}
+
+	document, err := report.Build(globals.StatisticsDB(), report.PeriodWeek, report.RoomSubjects(devices), now)
+	if err != nil {
+		app.logger.Error("Failed to build the weekly report", "error", err)
+		return
+	}
+
+	preferences := globals.Settings.UnitPreferences()
+	if err := app.saveReport(path, document); err != nil {
+		app.logger.Error("Failed to save the weekly report", "path", path, "error", err)
+		return
+	}
+
+	app.logger.Info("Saved the weekly report", "path", path)
+
+	var lines []string
+	for i, section := range document.Sections {
+		if i == weeklyReportSections {
+			lines = append(lines, fmt.Sprintf("and %d more", len(document.Sections)-i))
+			break
+		}
+		lines = append(lines, report.Summary(section, preferences))
+	}
+
+	glib.IdleAdd(func() bool {
+		notification := gio.NewNotification(document.Period.Title())
+		notification.SetBody(document.PeriodLabel() + "\n" + strings.Join(lines, "\n"))
+		notification.SetDefaultActionAndTarget("app.open-report", glib.NewVariantString(path))
+		app.SendNotification("weekly-report", notification)
+		return false
+	})
+}
+
+// saveReport writes a report as HTML, through a temporary file so a
+// failed write doesn't count as sent
+func (app *App) saveReport(path string, document report.Document) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".report-*.html")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := report.WriteHTML(file, document, globals.Settings.UnitPreferences()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var (
+	annotateAt      string
+	annotateUntil   string
+	annotateFor     string
+	annotateText    string
+	annotationRange string
+)
+
+// annotationTimeLayouts are the layouts times can be given in, in local time
+// unless they include a zone
+var annotationTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+}
+
+// annotateCmd represents the annotate command
+var annotateCmd = &cobra.Command{
+	Use:   "annotate <device_id_or_serial>",
+	Short: "Add a note to the timeline of a device",
+	Long: `Add a note to the timeline of a device, at a point in time or over a span, like
+"window opened", "cleaning" or "party". Notes are shown on the graph of the
+device and listed in reports, so spikes are easier to explain weeks later.
+
+Times can be "now", a time of today like 14:30, a date and time like
+"2025-06-10 14:30", or how long ago like 30m or 2h. A span ends at --until or
+lasts --for a duration.
+
+Examples:
+  gnome-desktop-air-monitor annotate awair-element_12345 --text "Window opened"
+  gnome-desktop-air-monitor annotate awair-element_12345 --at 14:30 --for 2h --text "Party"
+  gnome-desktop-air-monitor annotate 1 --at "2025-06-10 09:00" --until "2025-06-10 11:00" --text "Cleaning"`,
+	Args: cobra.ExactArgs(1),
+	Run:  runAnnotate,
+}
+
+// annotationCmd represents the annotation command
+var annotationCmd = &cobra.Command{
+	Use:     "annotation",
+	Aliases: []string{"annotations"},
+	Short:   "List and delete notes on the timeline of devices",
+	Long:    `List and delete the notes added to the timeline of devices with 'annotate' or on the graph.`,
+}
+
+// annotationListCmd represents the annotation list command
+var annotationListCmd = &cobra.Command{
+	Use:     "list [device_id_or_serial]",
+	Aliases: []string{"ls"},
+	Short:   "List notes on the timeline of devices",
+	Long: `List the notes on the timeline of a device, or of every device narrowed down with
+--room and --tag, over the last --range.
+
+Examples:
+  gnome-desktop-air-monitor annotation list
+  gnome-desktop-air-monitor annotation list awair-element_12345 --range 30d --format table`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: readOnlyAnnotation,
+	Run:         runAnnotationList,
+}
+
+// annotationDeleteCmd represents the annotation delete command
+var annotationDeleteCmd = &cobra.Command{
+	Use:     "delete <annotation_id>...",
+	Aliases: []string{"rm"},
+	Short:   "Delete notes from the timeline of devices",
+	Long: `Delete notes by the IDs shown by 'annotation list'.
+
+Examples:
+  gnome-desktop-air-monitor annotation delete 12`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runAnnotationDelete,
+}
+
+func runAnnotate(cmd *cobra.Command, args []string) {
+	device, err := findDevice(args[0])
+	if err != nil {
+		globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	text := models.NormalizeAnnotationText(annotateText)
+	if text == "" {
+		fmt.Fprintln(os.Stderr, "Error: The note can't be empty, set it with --text")
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	startsAt, err := parseAnnotationTime(annotateAt, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var endsAt *time.Time
+	switch {
+	case annotateUntil != "" && annotateFor != "":
+		fmt.Fprintln(os.Stderr, "Error: Use either --until or --for, not both")
+		os.Exit(1)
+	case annotateUntil != "":
+		end, err := parseAnnotationTime(annotateUntil, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		endsAt = &end
+	case annotateFor != "":
+		duration, err := stats.ParseRange(annotateFor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		end := startsAt.Add(duration)
+		endsAt = &end
+	}
+
+	if endsAt != nil && !endsAt.After(startsAt) {
+		fmt.Fprintln(os.Stderr, "Error: The note has to end after it starts")
+		os.Exit(1)
+	}
+
+	annotation := models.Annotation{
+		DeviceID: device.ID,
+		StartsAt: startsAt.UTC(),
+		Text:     text,
+	}
+	if endsAt != nil {
+		end := endsAt.UTC()
+		annotation.EndsAt = &end
+	}
+
+	err = database.Write(func(tx *gorm.DB) error {
+		return tx.Create(&annotation).Error
+	})
+	if err != nil {
+		globals.Logger.Error("Failed to create annotation", "device_id", device.ID, "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to add the note: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Added note %d to %s: %s\n", annotation.ID, device.Name, annotation.Label())
+}
+
+// parseAnnotationTime parses when a note starts or ends
+func parseAnnotationTime(text string, now time.Time) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.EqualFold(text, "now") {
+		return now, nil
+	}
+
+	if ago, err := stats.ParseRange(text); err == nil {
+		return now.Add(-ago), nil
+	}
+
+	if clock, err := time.ParseInLocation("15:04", text, time.Local); err == nil {
+		local := now.In(time.Local)
+		return time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local), nil
+	}
+
+	for _, layout := range annotationTimeLayouts {
+		if at, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return at, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected now, a time like 14:30, a date and time like \"2025-06-10 14:30\" or how long ago like 30m", text)
+}
+
+func runAnnotationList(cmd *cobra.Command, args []string) {
+	period, err := stats.ParseRange(annotationRange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var devices []models.Device
+	if len(args) == 1 {
+		device, err := findDevice(args[0])
+		if err != nil {
+			globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+			os.Exit(1)
+		}
+		devices = []models.Device{*device}
+	} else {
+		devices, err = findDevices(filterRoom, filterTag)
+		if err != nil {
+			globals.Logger.Error("Failed to fetch devices", "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	deviceIDs := make([]uint, 0, len(devices))
+	names := make(map[uint]string, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.ID)
+		names[device.ID] = device.Name
+	}
+
+	to := time.Now()
+	annotations, err := models.AnnotationsBetween(database.DB, deviceIDs, to.Add(-period), to)
+	if err != nil {
+		globals.Logger.Error("Failed to fetch annotations", "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to fetch notes: %v\n", err)
+		os.Exit(1)
+	}
+
+	response := make([]AnnotationInfo, 0, len(annotations))
+	for _, annotation := range annotations {
+		response = append(response, newAnnotationInfo(annotation, names[annotation.DeviceID]))
+	}
+
+	if outputFormat != "table" {
+		printJSON(response)
+		return
+	}
+
+	if len(response) == 0 {
+		fmt.Println("No notes found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDEVICE\tFROM\tTO\tTEXT")
+	fmt.Fprintln(w, "--\t------\t----\t--\t----")
+	for _, annotation := range annotations {
+		to := "-"
+		if annotation.IsSpan() {
+			to = annotation.EndsAt.Local().Format("Mon Jan 2 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			annotation.ID,
+			names[annotation.DeviceID],
+			annotation.StartsAt.Local().Format("Mon Jan 2 15:04"),
+			to,
+			annotation.Text,
+		)
+	}
+	w.Flush()
+}
+
+func runAnnotationDelete(cmd *cobra.Command, args []string) {
+	ids := make([]uint, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid note ID %q\n", arg)
+			os.Exit(1)
+		}
+		ids = append(ids, uint(id))
+	}
+
+	var deleted int64
+	err := database.Write(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Annotation{}, ids)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		globals.Logger.Error("Failed to delete annotations", "ids", ids, "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to delete notes: %v\n", err)
+		os.Exit(1)
+	}
+
+	if deleted < int64(len(ids)) {
+		fmt.Fprintf(os.Stderr, "Error: Deleted %d of %d notes, the others don't exist\n", deleted, len(ids))
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted %d notes\n", deleted)
+}
+
+// AnnotationInfo represents a note on the timeline of a device for JSON output
+type AnnotationInfo struct {
+	ID       uint    `json:"id"`
+	DeviceID uint    `json:"device_id"`
+	Device   string  `json:"device"`
+	From     string  `json:"from"`
+	To       *string `json:"to"`
+	Text     string  `json:"text"`
+}
+
+func newAnnotationInfo(annotation models.Annotation, deviceName string) AnnotationInfo {
+	info := AnnotationInfo{
+		ID:       annotation.ID,
+		DeviceID: annotation.DeviceID,
+		Device:   deviceName,
+		From:     annotation.StartsAt.Format("2006-01-02T15:04:05Z07:00"),
+		Text:     annotation.Text,
+	}
+	if annotation.IsSpan() {
+		to := annotation.EndsAt.Format("2006-01-02T15:04:05Z07:00")
+		info.To = &to
+	}
+	return info
+}
+
+func init() {
+	rootCmd.AddCommand(annotateCmd)
+	annotateCmd.Flags().StringVar(&annotateAt, "at", "now", "When the note starts, like now, 14:30, \"2025-06-10 14:30\" or 30m for 30 minutes ago")
+	annotateCmd.Flags().StringVar(&annotateUntil, "until", "", "When the note ends, for a span of time")
+	annotateCmd.Flags().StringVar(&annotateFor, "for", "", "How long the note lasts, like 30m or 2h, for a span of time")
+	annotateCmd.Flags().StringVarP(&annotateText, "text", "t", "", "What happened, like \"Window opened\"")
+
+	rootCmd.AddCommand(annotationCmd)
+	annotationCmd.AddCommand(annotationListCmd)
+	annotationListCmd.Flags().StringVarP(&annotationRange, "range", "r", "7d", "Period to list notes of, like 24h, 7d or 2w")
+	annotationListCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format (json or table)")
+	addDeviceFilterFlags(annotationListCmd)
+
+	annotationCmd.AddCommand(annotationDeleteCmd)
+}
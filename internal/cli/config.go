@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/app"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/spf13/cobra"
+)
+
+var configFormat string
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:     "config",
+	Aliases: []string{"settings"},
+	Short:   "Read and change settings",
+	Long: `Commands for reading and changing the settings.
+
+Changes are sent to the running app when there is one, so the app and the
+shell extension update immediately. Otherwise they are saved directly.
+
+Settings:
+  status_bar_device_serial_number  Device shown in the status bar, by serial number or ID (empty for the first device)
+  data_retention_period            Days to keep measurements (1-365)
+  show_shell_extension             Show the shell extension indicator (true or false)
+  temperature_unit                 celsius, fahrenheit or kelvin
+  voc_unit                         ppb or ugm3
+  backup_interval                  Days between automatic database backups (0 turns them off, up to 30)
+  backup_count                     Automatic backups to keep (1-100)
+  weekly_report                    Notify with a report of the previous week on Monday mornings (true or false)
+  exclude_anomalies                Leave measurements that look like sensor faults out of statistics (true or false)
+  co2_threshold                    CO₂ level in ppm at which to ventilate, forecasts warn before it's reached (600-5000)`,
+}
+
+// configListCmd represents the config list command
+var configListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all settings",
+	Long: `List all settings and their values.
+
+Examples:
+  gnome-desktop-air-monitor config list
+  gnome-desktop-air-monitor config list --format json`,
+	Args: cobra.NoArgs,
+	Run:  runConfigList,
+}
+
+// configGetCmd represents the config get command
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Long: `Print the value of a setting.
+
+Examples:
+  gnome-desktop-air-monitor config get data_retention_period
+  gnome-desktop-air-monitor config get temperature-unit`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: config.Keys,
+	Run:       runConfigGet,
+}
+
+// configSetCmd represents the config set command
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting",
+	Long: `Change a setting. The value is checked before it is saved.
+
+Examples:
+  gnome-desktop-air-monitor config set data_retention_period 30
+  gnome-desktop-air-monitor config set show_shell_extension false
+  gnome-desktop-air-monitor config set status_bar_device_serial_number 1
+  gnome-desktop-air-monitor config set status_bar_device_serial_number ""`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return config.Keys, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: runConfigSet,
+}
+
+// configResetCmd represents the config reset command
+var configResetCmd = &cobra.Command{
+	Use:   "reset [key...]",
+	Short: "Reset settings to their defaults",
+	Long: `Reset the given settings, or all settings when no key is given, to their defaults.
+
+Examples:
+  gnome-desktop-air-monitor config reset data_retention_period
+  gnome-desktop-air-monitor config reset`,
+	ValidArgs: config.Keys,
+	Run:       runConfigReset,
+}
+
+// configPathCmd represents the config path command
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print where the settings are stored",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(globals.Settings.Store().Location())
+	},
+}
+
+func runConfigList(cmd *cobra.Command, args []string) {
+	switch configFormat {
+	case "json":
+		output, err := json.MarshalIndent(globals.Settings.Values(), "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to format settings: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(output))
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+
+		fmt.Fprintln(w, "KEY\tVALUE")
+		fmt.Fprintln(w, "---\t-----")
+
+		for _, key := range config.Keys {
+			value, _ := globals.Settings.Get(key)
+			fmt.Fprintf(w, "%s\t%s\n", key, valueOrDash(fmt.Sprint(value)))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, expected json or table\n", configFormat)
+		os.Exit(1)
+	}
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) {
+	value, err := globals.Settings.Get(settingKey(args[0]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(value)
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) {
+	key := settingKey(args[0])
+
+	value, err := config.ParseValue(key, args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Accept device IDs too, but always store the serial number
+	if serial, ok := value.(string); ok && key == config.KeyStatusBarDeviceSerialNumber && serial != "" {
+		device, err := findDevice(serial)
+		if err != nil {
+			globals.Logger.Error("Device not found", "identifier", serial, "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", serial)
+			os.Exit(1)
+		}
+		value = device.SerialNumber
+	}
+
+	if err := applySetting(key, value); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	current, _ := globals.Settings.Get(key)
+	fmt.Printf("%s = %v\n", key, current)
+}
+
+func runConfigReset(cmd *cobra.Command, args []string) {
+	keys := config.Keys
+	if len(args) > 0 {
+		keys = make([]string, len(args))
+		for i, arg := range args {
+			keys[i] = settingKey(arg)
+		}
+	}
+
+	defaults := config.DefaultSettings()
+	for _, key := range keys {
+		value, err := defaults.Get(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := applySetting(key, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to reset %s: %v\n", key, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s = %v\n", key, value)
+	}
+}
+
+// applySetting changes a setting through the running app when there is
+// one, so the change shows up immediately, and saves it directly otherwise
+func applySetting(key string, value interface{}) error {
+	err := app.SetSettingOnRunningInstance(key, value)
+	if err == nil {
+		globals.Logger.Debug("Changed setting in the running app", "key", key, "value", value)
+		return globals.Settings.Set(key, value)
+	}
+	if !errors.Is(err, app.ErrNotRunning) {
+		return err
+	}
+
+	globals.Logger.Debug("Saving setting directly", "key", key, "value", value, "reason", err)
+
+	if err := globals.Settings.Set(key, value); err != nil {
+		return err
+	}
+	return globals.Settings.Save()
+}
+
+// settingKey accepts setting keys written with dashes as well as underscores
+func settingKey(arg string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(arg)), "-", "_")
+}
+
+func init() {
+	// Add config command to root
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.AddCommand(configListCmd)
+	configListCmd.Flags().StringVarP(&configFormat, "format", "f", "table", "Output format (json or table)")
+
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configResetCmd)
+	configCmd.AddCommand(configPathCmd)
+}
@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/app"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateTo     uint64
+	rollbackSteps int
+	dbDryRun      bool
+)
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:     "db",
+	Aliases: []string{"database"},
+	Short:   "Inspect and maintain the database",
+	Long: `Commands for inspecting and maintaining the database.
+
+Unlike other commands, these don't apply pending migrations when the database is opened.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		globals.InitializeWithoutMigrations(verbose)
+	},
+}
+
+// dbStatusCmd represents the db status command
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema version and which migrations are applied",
+	Args:  cobra.NoArgs,
+	Run:   runDBStatus,
+}
+
+// dbMigrateCmd represents the db migrate command
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending migrations",
+	Long: `Apply pending migrations, all of them or up to and including the given version.
+
+Examples:
+  gnome-desktop-air-monitor db migrate
+  gnome-desktop-air-monitor db migrate --to 2
+  gnome-desktop-air-monitor db migrate --dry-run`,
+	Args: cobra.NoArgs,
+	Run:  runDBMigrate,
+}
+
+// dbRollbackCmd represents the db rollback command
+var dbRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back the last applied migrations",
+	Long: `Roll back the last applied migrations. Rolling back a migration can delete data,
+the database is backed up to the backups directory first.
+
+Examples:
+  gnome-desktop-air-monitor db rollback
+  gnome-desktop-air-monitor db rollback --steps 2 --dry-run`,
+	Args: cobra.NoArgs,
+	Run:  runDBRollback,
+}
+
+// dbBackupCmd represents the db backup command
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup [path]",
+	Short: "Write a copy of the database",
+	Long: `Write a consistent copy of the database, which is safe while the app is running.
+Without a path the copy is written to the backups directory next to the database.
+
+Examples:
+  gnome-desktop-air-monitor db backup
+  gnome-desktop-air-monitor db backup ~/air-monitor.sqlite`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runDBBackup,
+}
+
+// dbRestoreCmd represents the db restore command
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Replace the database with a backup",
+	Long: `Replace all data in the database with a backup made by "db backup" or the app.
+The backup is checked first and the current database is backed up before it is replaced.
+Backups made by older versions of the app are migrated after they are restored.
+When the app is running, it restores the backup itself so it picks up the restored data.
+
+Examples:
+  gnome-desktop-air-monitor db restore ~/air-monitor.sqlite`,
+	Args: cobra.ExactArgs(1),
+	Run:  runDBRestore,
+}
+
+// dbCheckCmd represents the db check command
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the database for corruption and broken references",
+	Args:  cobra.NoArgs,
+	Run:   runDBCheck,
+}
+
+// dbDedupeCmd represents the db dedupe command
+var dbDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Delete measurements that were stored more than once",
+	Long: `Delete measurements that repeat the device and timestamp of another measurement.
+Older versions of the app could store the same measurement several times. Migrating
+the database removes these too, this command can be used to clean up before that.
+
+Examples:
+  gnome-desktop-air-monitor db dedupe --dry-run
+  gnome-desktop-air-monitor db dedupe`,
+	Args: cobra.NoArgs,
+	Run:  runDBDedupe,
+}
+
+func runDBStatus(cmd *cobra.Command, args []string) {
+	statuses, err := database.Status(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to read migrations: %v\n", err)
+		os.Exit(1)
+	}
+
+	latest, err := database.LatestSchemaVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to read migrations: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Database: %s\n", database.GetDatabasePath())
+	fmt.Printf("Schema version: %d (latest %d)\n\n", database.CurrentSchemaVersion(database.DB), latest)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	fmt.Fprintln(w, "-------\t----\t------\t----------")
+
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+		if !status.Known {
+			state = "unknown"
+		}
+
+		appliedAt := "-"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format("2006-01-02T15:04:05Z07:00")
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, valueOrDash(status.Name), state, appliedAt)
+	}
+}
+
+func runDBMigrate(cmd *cobra.Command, args []string) {
+	migrations, err := database.PendingMigrations(database.DB, database.SchemaVersion(migrateTo))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(migrations) == 0 {
+		fmt.Println("No pending migrations.")
+		return
+	}
+
+	if dbDryRun {
+		printMigrationSQL(migrations, (*database.Migration).UpSQL, "up")
+		return
+	}
+
+	snapshot, err := database.MigrateTo(database.DB, database.SchemaVersion(migrateTo))
+	printSnapshot(snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, migration := range migrations {
+		fmt.Printf("Applied %s\n", migration.DirName())
+	}
+}
+
+func runDBRollback(cmd *cobra.Command, args []string) {
+	migrations, err := database.MigrationsToRollBack(database.DB, rollbackSteps)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(migrations) == 0 {
+		fmt.Println("No applied migrations.")
+		return
+	}
+
+	if dbDryRun {
+		printMigrationSQL(migrations, (*database.Migration).DownSQL, "down")
+		return
+	}
+
+	snapshot, err := database.RollBack(database.DB, rollbackSteps)
+	printSnapshot(snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, migration := range migrations {
+		fmt.Printf("Rolled back %s\n", migration.DirName())
+	}
+}
+
+// printSnapshot tells where the database was backed up before changing its schema
+func printSnapshot(path string) {
+	if path != "" {
+		fmt.Printf("Backed up the database to %s\n", path)
+	}
+}
+
+// printMigrationSQL prints the SQL that would be run for each migration
+func printMigrationSQL(migrations []database.Migration, sql func(*database.Migration) (string, error), direction string) {
+	for _, migration := range migrations {
+		statements, err := sql(&migration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("-- %s (%s)\n%s\n", migration.DirName(), direction, statements)
+	}
+}
+
+func runDBBackup(cmd *cobra.Command, args []string) {
+	path := database.DefaultBackupPath(time.Now())
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if err := database.Backup(database.DB, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Backed up the database to %s\n", path)
+}
+
+func runDBRestore(cmd *cobra.Command, args []string) {
+	snapshot, err := restoreDatabase(args[0])
+	printSnapshot(snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored the database from %s\n", args[0])
+}
+
+// restoreDatabase restores the database through the running app when there
+// is one, so it stops writing measurements for the replaced database, and
+// directly otherwise
+func restoreDatabase(path string) (string, error) {
+	snapshot, err := app.RestoreDatabaseOnRunningInstance(path)
+	if err == nil {
+		globals.Logger.Debug("Restored the database in the running app", "path", path)
+		return snapshot, nil
+	}
+	if !errors.Is(err, app.ErrNotRunning) {
+		return snapshot, err
+	}
+
+	globals.Logger.Debug("Restoring the database directly", "path", path, "reason", err)
+	return database.Restore(database.DB, path)
+}
+
+func runDBCheck(cmd *cobra.Command, args []string) {
+	result, err := database.Check(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if result.OK() {
+		fmt.Println("No problems found.")
+		return
+	}
+
+	for _, message := range result.IntegrityErrors {
+		fmt.Printf("Integrity: %s\n", message)
+	}
+	for _, violation := range result.ForeignKeyViolations {
+		fmt.Printf("Foreign key: %s row %d references a missing row in %s\n",
+			violation.Table, violation.RowID, violation.Parent)
+	}
+
+	os.Exit(1)
+}
+
+func runDBDedupe(cmd *cobra.Command, args []string) {
+	if dbDryRun {
+		count, err := database.CountDuplicateMeasurements(database.DB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to count duplicate measurements: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Found %d duplicate measurements.\n", count)
+		return
+	}
+
+	count, err := database.DeleteDuplicateMeasurements(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to delete duplicate measurements: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted %d duplicate measurements.\n", count)
+}
+
+func init() {
+	// Add db command to root
+	rootCmd.AddCommand(dbCmd)
+
+	dbCmd.AddCommand(dbStatusCmd)
+
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbMigrateCmd.Flags().Uint64Var(&migrateTo, "to", 0, "Migrate up to and including this version (default: latest)")
+	dbMigrateCmd.Flags().BoolVar(&dbDryRun, "dry-run", false, "Print the SQL instead of running it")
+
+	dbCmd.AddCommand(dbRollbackCmd)
+	dbRollbackCmd.Flags().IntVar(&rollbackSteps, "steps", 1, "Number of migrations to roll back")
+	dbRollbackCmd.Flags().BoolVar(&dbDryRun, "dry-run", false, "Print the SQL instead of running it")
+
+	dbCmd.AddCommand(dbBackupCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	dbCmd.AddCommand(dbCheckCmd)
+
+	dbCmd.AddCommand(dbDedupeCmd)
+	dbDedupeCmd.Flags().BoolVar(&dbDryRun, "dry-run", false, "Count the duplicates instead of deleting them")
+}
@@ -3,12 +3,25 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var (
+	filterRoom     string
+	filterTag      string
+	editRoom       string
+	editVolume     float64
+	editSurface    string
+	editAddTags    []string
+	editRemoveTags []string
 )
 
 // deviceCmd represents the device command
@@ -24,15 +37,39 @@ var deviceListCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
 	Short:   "List all known devices",
-	Long:    `List all discovered devices with their ID, name, serial number, IP address, and last seen timestamp.`,
-	Run:     runDeviceList,
+	Long: `List all discovered devices with their ID, name, serial number, IP address, room, tags and last seen timestamp.
+
+Examples:
+  gnome-desktop-air-monitor device list
+  gnome-desktop-air-monitor device list --room kitchen
+  gnome-desktop-air-monitor device list --tag office`,
+	Annotations: readOnlyAnnotation,
+	Run:         runDeviceList,
+}
+
+// deviceEditCmd represents the device edit command
+var deviceEditCmd = &cobra.Command{
+	Use:   "edit <device_id_or_serial>",
+	Short: "Change the room, room volume, surface temperature and tags of a device",
+	Long: `Change the room, room volume, surface temperature and tags of a device specified by either device ID or serial number.
+The room volume, in m³, is used to estimate the ventilation and occupancy of the room.
+The temperature of the room's coldest surface, like a wall or window, in the temperature unit
+from the settings or --temperature-unit, is used to check for condensation and mould.
+
+Examples:
+  gnome-desktop-air-monitor device edit 1 --room kitchen
+  gnome-desktop-air-monitor device edit 1 --volume 45
+  gnome-desktop-air-monitor device edit 1 --surface-temperature 12.5
+  gnome-desktop-air-monitor device edit 1 --add-tag office --remove-tag home
+  gnome-desktop-air-monitor device edit 1 --room ""`,
+	Args: cobra.ExactArgs(1),
+	Run:  runDeviceEdit,
 }
 
 func runDeviceList(cmd *cobra.Command, args []string) {
 	globals.Logger.Debug("Fetching devices from database")
 
-	var devices []models.Device
-	err := database.DB.Find(&devices).Error
+	devices, err := findDevices(filterRoom, filterTag)
 	if err != nil {
 		globals.Logger.Error("Failed to fetch devices", "error", err)
 		fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
@@ -49,16 +86,18 @@ func runDeviceList(cmd *cobra.Command, args []string) {
 	defer w.Flush()
 
 	// Print header
-	fmt.Fprintln(w, "ID\tNAME\tSERIAL\tIP ADDRESS\tLAST SEEN")
-	fmt.Fprintln(w, "--\t----\t------\t----------\t---------")
+	fmt.Fprintln(w, "ID\tNAME\tSERIAL\tIP ADDRESS\tROOM\tTAGS\tLAST SEEN")
+	fmt.Fprintln(w, "--\t----\t------\t----------\t----\t----\t---------")
 
 	// Print devices
 	for _, device := range devices {
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			device.ID,
 			device.Name,
 			device.SerialNumber,
 			device.IPAddress,
+			valueOrDash(device.Room),
+			valueOrDash(strings.Join(device.TagNames(), ",")),
 			device.LastSeen.Format("2006-01-02T15:04:05Z07:00"),
 		)
 	}
@@ -66,11 +105,151 @@ func runDeviceList(cmd *cobra.Command, args []string) {
 	globals.Logger.Debug("Device list completed", "count", len(devices))
 }
 
+func runDeviceEdit(cmd *cobra.Command, args []string) {
+	device, err := findDevice(args[0])
+	if err != nil {
+		globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	if editVolume < 0 {
+		fmt.Fprintf(os.Stderr, "Error: The room volume can't be negative\n")
+		os.Exit(1)
+	}
+
+	var surfaceTemperature *float64
+	if strings.TrimSpace(editSurface) != "" {
+		preferences, err := unitOverrides()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(editSurface), 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid surface temperature %q\n", editSurface)
+			os.Exit(1)
+		}
+		celsius := preferences.Temperature.ToCelsius(value)
+		surfaceTemperature = &celsius
+	}
+
+	// Change everything together, so a failure leaves all unchanged
+	err = database.Write(func(tx *gorm.DB) error {
+		if cmd.Flags().Changed("room") {
+			room := models.NormalizeRoom(editRoom)
+			if err := tx.Model(device).Update("room", room).Error; err != nil {
+				return fmt.Errorf("failed to update room: %w", err)
+			}
+			globals.Logger.Debug("Updated device room", "device_id", device.ID, "room", room)
+		}
+
+		if cmd.Flags().Changed("volume") {
+			if err := tx.Model(device).Update("room_volume", editVolume).Error; err != nil {
+				return fmt.Errorf("failed to update room volume: %w", err)
+			}
+			globals.Logger.Debug("Updated device room volume", "device_id", device.ID, "volume", editVolume)
+		}
+
+		if cmd.Flags().Changed("surface-temperature") {
+			if err := tx.Model(device).Update("surface_temperature", surfaceTemperature).Error; err != nil {
+				return fmt.Errorf("failed to update surface temperature: %w", err)
+			}
+			globals.Logger.Debug("Updated device surface temperature", "device_id", device.ID, "temperature", surfaceTemperature)
+		}
+
+		if len(editAddTags) > 0 || len(editRemoveTags) > 0 {
+			removed := make(map[string]bool)
+			for _, tag := range editRemoveTags {
+				removed[models.NormalizeTag(tag)] = true
+			}
+
+			var tags []string
+			for _, tag := range append(device.TagNames(), editAddTags...) {
+				if !removed[models.NormalizeTag(tag)] {
+					tags = append(tags, tag)
+				}
+			}
+
+			if err := device.ReplaceTags(tx, tags); err != nil {
+				return fmt.Errorf("failed to update tags: %w", err)
+			}
+			globals.Logger.Debug("Updated device tags", "device_id", device.ID, "tags", device.TagNames())
+		}
+
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Updated %s\n", device.Name)
+}
+
+// findDevice finds a device by its ID or serial number
+func findDevice(identifier string) (*models.Device, error) {
+	var device models.Device
+	query := database.DB.Preload("Tags")
+
+	var err error
+	// Try parsing as ID first
+	if deviceID, parseErr := strconv.ParseUint(identifier, 10, 32); parseErr == nil {
+		err = query.First(&device, uint(deviceID)).Error
+	} else {
+		// Try finding by serial number
+		err = query.Where("serial_number = ?", identifier).First(&device).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+// findDevices returns all devices, optionally filtered by room and tag
+func findDevices(room string, tag string) ([]models.Device, error) {
+	query := database.DB.Preload("Tags")
+	if room != "" {
+		query = query.Scopes(models.InRoom(room))
+	}
+	if tag != "" {
+		query = query.Scopes(models.WithTag(tag))
+	}
+
+	var devices []models.Device
+	err := query.Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// addDeviceFilterFlags adds the --room and --tag flags to a command
+func addDeviceFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&filterRoom, "room", "", "Only include devices in this room")
+	cmd.Flags().StringVar(&filterTag, "tag", "", "Only include devices with this tag")
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
 func init() {
 	// Add device command to root
 	rootCmd.AddCommand(deviceCmd)
 
 	// Add list subcommand to device
 	deviceCmd.AddCommand(deviceListCmd)
-}
+	addDeviceFilterFlags(deviceListCmd)
 
+	// Add edit subcommand to device
+	deviceCmd.AddCommand(deviceEditCmd)
+	deviceEditCmd.Flags().StringVar(&editRoom, "room", "", "Room the device is located in (empty to clear)")
+	deviceEditCmd.Flags().Float64Var(&editVolume, "volume", 0, "Volume of the room in m³ (0 to clear)")
+	deviceEditCmd.Flags().StringVar(&editSurface, "surface-temperature", "", "Temperature of the room's coldest surface (empty to clear)")
+	deviceEditCmd.Flags().StringVar(&temperatureUnitFlag, "temperature-unit", "", "Unit of --surface-temperature (celsius, fahrenheit or kelvin)")
+	deviceEditCmd.Flags().StringSliceVar(&editAddTags, "add-tag", nil, "Tag to add (repeatable or comma separated)")
+	deviceEditCmd.Flags().StringSliceVar(&editRemoveTags, "remove-tag", nil, "Tag to remove (repeatable or comma separated)")
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/anomaly"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// flagAnomaliesWindow is how much of a device's history is flagged per transaction
+const flagAnomaliesWindow = 7 * 24 * time.Hour
+
+var healthRange string
+
+// deviceHealthCmd represents the device health command
+var deviceHealthCmd = &cobra.Command{
+	Use:   "health [device_id_or_serial]",
+	Short: "Check the measurements of devices for sensor faults",
+	Long: `Check the recent measurements of devices for signs of a faulty sensor: values
+outside of what is physically possible, impossible jumps between readings, readings
+that stay exactly the same, CO₂ stuck at 400 ppm, and gaps without measurements.
+
+Without a device every device is checked, narrowed down with --room and --tag.
+
+Examples:
+  gnome-desktop-air-monitor device health
+  gnome-desktop-air-monitor device health awair-element_12345 --range 7d`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: readOnlyAnnotation,
+	Run:         runDeviceHealth,
+}
+
+// dbFlagAnomaliesCmd represents the db flag-anomalies command
+var dbFlagAnomaliesCmd = &cobra.Command{
+	Use:   "flag-anomalies",
+	Short: "Flag measurements that look like sensor faults",
+	Long: `Flag every stored measurement that looks like a sensor fault or glitch, and clear
+flags that no longer apply. The app flags new measurements as they come in, this
+command flags measurements stored before that, or while the app wasn't running.
+
+Flagged measurements are marked on the graph and can be left out of statistics,
+heatmaps and reports with the exclude_anomalies setting.
+
+Examples:
+  gnome-desktop-air-monitor db flag-anomalies --dry-run
+  gnome-desktop-air-monitor db flag-anomalies`,
+	Args: cobra.NoArgs,
+	Run:  runDBFlagAnomalies,
+}
+
+func runDeviceHealth(cmd *cobra.Command, args []string) {
+	period, err := stats.ParseRange(healthRange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var devices []models.Device
+	if len(args) == 1 {
+		device, err := findDevice(args[0])
+		if err != nil {
+			globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+			os.Exit(1)
+		}
+		devices = []models.Device{*device}
+	} else {
+		devices, err = findDevices(filterRoom, filterTag)
+		if err != nil {
+			globals.Logger.Error("Failed to fetch devices", "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(devices) == 0 {
+		fmt.Println("No devices found.")
+		return
+	}
+
+	to := time.Now()
+	for i, device := range devices {
+		health, err := anomaly.CheckDevice(database.DB, device.ID, to.Add(-period), to)
+		if err != nil {
+			globals.Logger.Error("Failed to check device health", "device_id", device.ID, "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to check %s: %v\n", device.Name, err)
+			os.Exit(1)
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s), %d measurements in the last %s\n", device.Name, device.SerialNumber, health.Count, healthRange)
+
+		warnings := health.Warnings()
+		if len(warnings) == 0 {
+			fmt.Println("  No problems found")
+		}
+		for _, warning := range warnings {
+			fmt.Printf("  ! %s\n", warning)
+		}
+	}
+}
+
+func runDBFlagAnomalies(cmd *cobra.Command, args []string) {
+	var devices []models.Device
+	if err := database.DB.Order("id").Find(&devices).Error; err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
+		os.Exit(1)
+	}
+
+	to := time.Now()
+	total := 0
+
+	for _, device := range devices {
+		var first models.Measurement
+		err := database.DB.Select("timestamp").Where("device_id = ?", device.ID).Order("timestamp ASC").Limit(1).Find(&first).Error
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to read measurements of %s: %v\n", device.Name, err)
+			os.Exit(1)
+		}
+		if first.Timestamp.IsZero() {
+			continue
+		}
+
+		count := 0
+		for from := first.Timestamp; from.Before(to); from = from.Add(flagAnomaliesWindow) {
+			until := from.Add(flagAnomaliesWindow)
+			if until.After(to) {
+				until = to
+			}
+
+			if dbDryRun {
+				health, err := anomaly.CheckDevice(database.DB, device.ID, from, until)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: Failed to check measurements of %s: %v\n", device.Name, err)
+					os.Exit(1)
+				}
+				count += health.Anomalous
+				continue
+			}
+
+			err := database.Write(func(tx *gorm.DB) error {
+				changed, err := anomaly.FlagDevice(tx, device.ID, from, until)
+				count += changed
+				return err
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to flag measurements of %s: %v\n", device.Name, err)
+				os.Exit(1)
+			}
+		}
+
+		if dbDryRun {
+			fmt.Printf("%s: %d measurements look like sensor faults\n", device.Name, count)
+		} else {
+			fmt.Printf("%s: %d measurements changed\n", device.Name, count)
+		}
+		total += count
+	}
+
+	if dbDryRun {
+		fmt.Printf("Found %d measurements that look like sensor faults.\n", total)
+	} else {
+		fmt.Printf("Updated the flags of %d measurements.\n", total)
+	}
+}
+
+func init() {
+	deviceCmd.AddCommand(deviceHealthCmd)
+	deviceHealthCmd.Flags().StringVarP(&healthRange, "range", "r", "24h", "Period to check, like 24h, 7d or 2w")
+	addDeviceFilterFlags(deviceHealthCmd)
+
+	dbCmd.AddCommand(dbFlagAnomaliesCmd)
+	dbFlagAnomaliesCmd.Flags().BoolVar(&dbDryRun, "dry-run", false, "Count the measurements that look like sensor faults instead of flagging them")
+}
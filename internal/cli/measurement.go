@@ -4,14 +4,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strconv"
+	"text/tabwriter"
+	"time"
 
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/comfort"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
 	"github.com/spf13/cobra"
 )
 
+var statsRange string
+
 // measurementCmd represents the measurement command
 var measurementCmd = &cobra.Command{
 	Use:     "measurement",
@@ -22,33 +29,64 @@ var measurementCmd = &cobra.Command{
 
 // measurementGetCmd represents the measurement get command
 var measurementGetCmd = &cobra.Command{
-	Use:   "get <device_id_or_serial>",
+	Use:   "get [device_id_or_serial]",
 	Short: "Get the latest measurement for a device",
 	Long: `Get the latest measurement for a device specified by either device ID or serial number.
 
+When no device is given, the latest measurements of all devices matching
+--room and --tag are averaged.
+
 Examples:
   gnome-desktop-air-monitor measurement get 1
-  gnome-desktop-air-monitor measurement get awair-element_12345`,
-	Args: cobra.ExactArgs(1),
-	Run:  runMeasurementGet,
+  gnome-desktop-air-monitor measurement get awair-element_12345
+  gnome-desktop-air-monitor measurement get --room kitchen
+  gnome-desktop-air-monitor measurement get 1 --format table --temperature-unit fahrenheit`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: readOnlyAnnotation,
+	Run:         runMeasurementGet,
+}
+
+// measurementStatsCmd represents the measurement stats command
+var measurementStatsCmd = &cobra.Command{
+	Use:   "stats <device_id_or_serial>",
+	Short: "Summarize the measurements of a device over a period of time",
+	Long: `Summarize the measurements of a device over a period of time: the minimum, maximum,
+mean, median and 95th percentile of each metric, the time spent in each quality band
+and the hours of the day with the worst air.
+
+Quality bands follow the score: good from 75, moderate from 30 and poor below that.
+
+Examples:
+  gnome-desktop-air-monitor measurement stats 1
+  gnome-desktop-air-monitor measurement stats 1 --range 24h --format table
+  gnome-desktop-air-monitor measurement stats awair-element_12345 --range 2w`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: readOnlyAnnotation,
+	Run:         runMeasurementStats,
 }
 
 func runMeasurementGet(cmd *cobra.Command, args []string) {
-	deviceIdentifier := args[0]
-	globals.Logger.Debug("Getting measurement for device", "identifier", deviceIdentifier)
+	preferences, err := unitPreferences()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	// First try to find device by ID, then by serial number
-	var device models.Device
-	var err error
+	if len(args) == 0 {
+		if filterRoom == "" && filterTag == "" {
+			fmt.Fprintln(os.Stderr, "Error: Specify a device or filter devices with --room or --tag")
+			os.Exit(1)
+		}
 
-	// Try parsing as ID first
-	if deviceID, parseErr := strconv.ParseUint(deviceIdentifier, 10, 32); parseErr == nil {
-		err = database.DB.First(&device, uint(deviceID)).Error
-	} else {
-		// Try finding by serial number
-		err = database.DB.Where("serial_number = ?", deviceIdentifier).First(&device).Error
+		runMeasurementGetAggregate(preferences)
+		return
 	}
 
+	deviceIdentifier := args[0]
+	globals.Logger.Debug("Getting measurement for device", "identifier", deviceIdentifier)
+
+	// First try to find device by ID, then by serial number
+	device, err := findDevice(deviceIdentifier)
 	if err != nil {
 		globals.Logger.Error("Device not found", "identifier", deviceIdentifier, "error", err)
 		fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", deviceIdentifier)
@@ -58,42 +96,164 @@ func runMeasurementGet(cmd *cobra.Command, args []string) {
 	globals.Logger.Debug("Found device", "id", device.ID, "name", device.Name, "serial", device.SerialNumber)
 
 	// Get the latest measurement for this device
-	var measurement models.Measurement
-	err = database.DB.Where("device_id = ?", device.ID).
-		Order("timestamp DESC").
-		First(&measurement).Error
+	measurement, err := latestMeasurement(device.ID)
 	if err != nil {
 		globals.Logger.Error("No measurements found for device", "device_id", device.ID, "error", err)
 		fmt.Fprintf(os.Stderr, "Error: No measurements found for device %s\n", deviceIdentifier)
 		os.Exit(1)
 	}
 
+	indices, err := airquality.ForDevice(globals.StatisticsDB(), device.ID, time.Now())
+	if err != nil {
+		globals.Logger.Error("Failed to compute air quality indices", "device_id", device.ID, "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to compute air quality indices: %v\n", err)
+		os.Exit(1)
+	}
+
+	mouldRisk, err := comfort.MouldRiskForDevice(globals.StatisticsDB(), *device, time.Now())
+	if err != nil {
+		globals.Logger.Error("Failed to judge mould risk", "device_id", device.ID, "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to judge mould risk: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create response structure
 	response := struct {
 		Device      DeviceInfo      `json:"device"`
 		Measurement MeasurementInfo `json:"measurement"`
+		Indices     *IndicesInfo    `json:"indices"` // null without measurements in the last day
+		MouldRisk   MouldRiskInfo   `json:"mould_risk"`
 	}{
-		Device: DeviceInfo{
-			ID:           device.ID,
-			Name:         device.Name,
-			SerialNumber: device.SerialNumber,
-			IPAddress:    device.IPAddress,
-			DeviceType:   device.DeviceType,
-			LastSeen:     device.LastSeen.Format("2006-01-02T15:04:05Z07:00"),
-		},
-		Measurement: MeasurementInfo{
-			Timestamp:   measurement.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
-			Temperature: measurement.Temperature,
-			Humidity:    measurement.Humidity,
-			CO2:         measurement.CO2,
-			VOC:         measurement.VOC,
-			PM25:        measurement.PM25,
-			Score:       measurement.Score,
-		},
+		Device:      newDeviceInfo(*device),
+		Measurement: newMeasurementInfo(*measurement, preferences),
+		Indices:     newIndicesInfo(indices),
+		MouldRisk:   newMouldRiskInfo(mouldRisk),
 	}
 
-	// Output as JSON
-	output, err := json.MarshalIndent(response, "", "  ")
+	if outputFormat == "table" {
+		indicesInfo := response.Indices
+		if indicesInfo == nil {
+			indicesInfo = &IndicesInfo{} // Printed as unknown
+		}
+		printMeasurementTable(device.Name, response.Measurement, indicesInfo, &response.MouldRisk, preferences)
+	} else {
+		printJSON(response)
+	}
+
+	globals.Logger.Debug("Measurement get completed", "device_id", device.ID)
+}
+
+// runMeasurementGetAggregate averages the latest measurements of all devices matching the room and tag filters
+func runMeasurementGetAggregate(preferences units.Preferences) {
+	devices, err := findDevices(filterRoom, filterTag)
+	if err != nil {
+		globals.Logger.Error("Failed to fetch devices", "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
+		os.Exit(1)
+	}
+
+	deviceInfos := []DeviceInfo{}
+	measurements := []models.Measurement{}
+	for _, device := range devices {
+		measurement, err := latestMeasurement(device.ID)
+		if err != nil {
+			globals.Logger.Debug("Skipping device without measurements", "device_id", device.ID)
+			continue
+		}
+
+		deviceInfos = append(deviceInfos, newDeviceInfo(device))
+		measurements = append(measurements, *measurement)
+	}
+
+	if len(measurements) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: No measurements found for matching devices")
+		os.Exit(1)
+	}
+
+	// The room as it was entered on the devices, not as it was typed
+	room := models.NormalizeRoom(filterRoom)
+	if room != "" {
+		room = devices[0].Room
+	}
+
+	response := struct {
+		Room        string          `json:"room,omitempty"`
+		Tag         string          `json:"tag,omitempty"`
+		Devices     []DeviceInfo    `json:"devices"`
+		Measurement MeasurementInfo `json:"measurement"`
+	}{
+		Room:        room,
+		Tag:         models.NormalizeTag(filterTag),
+		Devices:     deviceInfos,
+		Measurement: newMeasurementInfo(models.AverageMeasurements(measurements), preferences),
+	}
+
+	if outputFormat == "table" {
+		title := fmt.Sprintf("Average of %d devices", len(deviceInfos))
+		printMeasurementTable(title, response.Measurement, nil, nil, preferences)
+	} else {
+		printJSON(response)
+	}
+}
+
+func runMeasurementStats(cmd *cobra.Command, args []string) {
+	preferences, err := unitPreferences()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	period, err := stats.ParseRange(statsRange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	device, err := findDevice(args[0])
+	if err != nil {
+		globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	to := time.Now()
+	report, err := stats.ForDevice(globals.StatisticsDB(), device.ID, to.Add(-period), to)
+	if err != nil {
+		globals.Logger.Error("Failed to compute statistics", "device_id", device.ID, "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to compute statistics: %v\n", err)
+		os.Exit(1)
+	}
+
+	if report.Count == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No measurements found for device %s in the last %s\n", args[0], statsRange)
+		os.Exit(1)
+	}
+
+	response := newStatsInfo(*device, report, preferences)
+
+	if outputFormat == "table" {
+		printStatsTable(device.Name, response, preferences)
+	} else {
+		printJSON(response)
+	}
+}
+
+// latestMeasurement returns the most recent measurement of a device
+func latestMeasurement(deviceID uint) (*models.Measurement, error) {
+	var measurement models.Measurement
+	err := database.DB.Where("device_id = ?", deviceID).
+		Order("timestamp DESC").
+		First(&measurement).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &measurement, nil
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(value any) {
+	output, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
 		globals.Logger.Error("Failed to marshal response", "error", err)
 		fmt.Fprintf(os.Stderr, "Error: Failed to format response: %v\n", err)
@@ -101,29 +261,268 @@ func runMeasurementGet(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Println(string(output))
+}
 
-	globals.Logger.Debug("Measurement get completed", "device_id", device.ID)
+func newDeviceInfo(device models.Device) DeviceInfo {
+	return DeviceInfo{
+		ID:           device.ID,
+		Name:         device.Name,
+		SerialNumber: device.SerialNumber,
+		IPAddress:    device.IPAddress,
+		DeviceType:   device.DeviceType,
+		Room:         device.Room,
+		RoomVolume:   device.RoomVolume,
+		Tags:         device.TagNames(),
+		LastSeen:     device.LastSeen.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+func newMeasurementInfo(measurement models.Measurement, preferences units.Preferences) MeasurementInfo {
+	indices := comfort.Compute(measurement)
+
+	return MeasurementInfo{
+		Timestamp:        measurement.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+		Temperature:      preferences.Temperature.FromCelsius(measurement.Temperature),
+		Humidity:         measurement.Humidity,
+		CO2:              measurement.CO2,
+		VOC:              preferences.VOC.FromPPB(measurement.VOC),
+		PM25:             measurement.PM25,
+		Score:            measurement.Score,
+		DewPoint:         preferences.Temperature.FromCelsius(indices.DewPoint),
+		HeatIndex:        preferences.Temperature.FromCelsius(indices.HeatIndex),
+		Humidex:          indices.Humidex,
+		AbsoluteHumidity: indices.AbsoluteHumidity,
+		Units: UnitsInfo{
+			Temperature: preferences.Temperature.Symbol(),
+			VOC:         preferences.VOC.Symbol(),
+		},
+	}
 }
 
 // DeviceInfo represents device information for JSON output
 type DeviceInfo struct {
-	ID           uint   `json:"id"`
-	Name         string `json:"name"`
-	SerialNumber string `json:"serial_number"`
-	IPAddress    string `json:"ip_address"`
-	DeviceType   string `json:"device_type"`
-	LastSeen     string `json:"last_seen"`
+	ID           uint     `json:"id"`
+	Name         string   `json:"name"`
+	SerialNumber string   `json:"serial_number"`
+	IPAddress    string   `json:"ip_address"`
+	DeviceType   string   `json:"device_type"`
+	Room         string   `json:"room"`
+	RoomVolume   float64  `json:"room_volume"`
+	Tags         []string `json:"tags"`
+	LastSeen     string   `json:"last_seen"`
 }
 
 // MeasurementInfo represents measurement information for JSON output
 type MeasurementInfo struct {
-	Timestamp   string  `json:"timestamp"`
-	Temperature float64 `json:"temperature"`
-	Humidity    float64 `json:"humidity"`
-	CO2         float64 `json:"co2"`
-	VOC         float64 `json:"voc"`
-	PM25        float64 `json:"pm25"`
-	Score       float64 `json:"score"`
+	Timestamp        string    `json:"timestamp"`
+	Temperature      float64   `json:"temperature"`
+	Humidity         float64   `json:"humidity"`
+	CO2              float64   `json:"co2"`
+	VOC              float64   `json:"voc"`
+	PM25             float64   `json:"pm25"`
+	Score            float64   `json:"score"`
+	DewPoint         float64   `json:"dew_point"`
+	HeatIndex        float64   `json:"heat_index"`
+	Humidex          float64   `json:"humidex"`
+	AbsoluteHumidity float64   `json:"absolute_humidity"`
+	Units            UnitsInfo `json:"units"`
+}
+
+// UnitsInfo describes the units of converted measurement values for JSON output
+type UnitsInfo struct {
+	Temperature string `json:"temperature"`
+	VOC         string `json:"voc"`
+}
+
+// IndexInfo represents a single air quality index for JSON output
+type IndexInfo struct {
+	Value    *int   `json:"value,omitempty"`
+	Category string `json:"category"`
+}
+
+// IndicesInfo represents locally computed air quality indices for JSON output
+type IndicesInfo struct {
+	USAQI             IndexInfo `json:"us_aqi"`
+	EUCAQI            IndexInfo `json:"eu_caqi"`
+	CO2               IndexInfo `json:"co2"`
+	VOC               IndexInfo `json:"voc"`
+	DominantPollutant string    `json:"dominant_pollutant"`
+}
+
+// MouldRiskInfo represents the risk of mould in a room for JSON output
+type MouldRiskInfo struct {
+	Level           string   `json:"level"`
+	HumidSeconds    int64    `json:"humid_seconds"`
+	SurfaceHumidity *float64 `json:"surface_humidity"`
+	Condensation    bool     `json:"condensation"`
+	Reasons         []string `json:"reasons"`
+}
+
+func newMouldRiskInfo(risk comfort.MouldRisk) MouldRiskInfo {
+	reasons := risk.Reasons()
+	if reasons == nil {
+		reasons = []string{}
+	}
+
+	return MouldRiskInfo{
+		Level:           risk.Level.Name,
+		HumidSeconds:    int64(risk.HumidFor.Seconds()),
+		SurfaceHumidity: risk.SurfaceHumidity,
+		Condensation:    risk.Condensation,
+		Reasons:         reasons,
+	}
+}
+
+// newIndicesInfo converts indices for JSON output, nil if there were no
+// measurements to compute them from
+func newIndicesInfo(indices airquality.Indices) *IndicesInfo {
+	if !indices.Valid() {
+		return nil
+	}
+
+	aqi := indices.AQI.Value
+	caqi := indices.CAQI.Value
+
+	return &IndicesInfo{
+		USAQI:             IndexInfo{Value: &aqi, Category: indices.AQI.Name},
+		EUCAQI:            IndexInfo{Value: &caqi, Category: indices.CAQI.Name},
+		CO2:               IndexInfo{Category: indices.CO2.Name},
+		VOC:               IndexInfo{Category: indices.VOC.Name},
+		DominantPollutant: string(indices.DominantPollutant),
+	}
+}
+
+// StatsInfo represents a statistics report for JSON output
+type StatsInfo struct {
+	Device     DeviceInfo             `json:"device"`
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	Count      int                    `json:"count"`
+	Metrics    map[string]SummaryInfo `json:"metrics"`
+	Units      UnitsInfo              `json:"units"`
+	TimeInBand []BandTimeInfo         `json:"time_in_band"`
+	WorstHours []HourScoreInfo        `json:"worst_hours"`
+}
+
+// SummaryInfo represents the distribution of a metric for JSON output
+type SummaryInfo struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+}
+
+// BandTimeInfo represents the time spent in a quality band for JSON output
+type BandTimeInfo struct {
+	Band    string  `json:"band"`
+	Seconds int64   `json:"seconds"`
+	Percent float64 `json:"percent"`
+}
+
+// HourScoreInfo represents the average score of an hour of the day for JSON output
+type HourScoreInfo struct {
+	Hour  int     `json:"hour"`
+	Score float64 `json:"score"`
+}
+
+func newStatsInfo(device models.Device, report stats.Report, preferences units.Preferences) StatsInfo {
+	info := StatsInfo{
+		Device:  newDeviceInfo(device),
+		From:    report.From.Format("2006-01-02T15:04:05Z07:00"),
+		To:      report.To.Format("2006-01-02T15:04:05Z07:00"),
+		Count:   report.Count,
+		Metrics: make(map[string]SummaryInfo, len(stats.Metrics)),
+		Units: UnitsInfo{
+			Temperature: preferences.Temperature.Symbol(),
+			VOC:         preferences.VOC.Symbol(),
+		},
+		TimeInBand: []BandTimeInfo{},
+		WorstHours: []HourScoreInfo{},
+	}
+
+	for _, metric := range stats.Metrics {
+		summary := report.Metrics[metric]
+		switch metric {
+		case stats.MetricTemperature:
+			summary = summary.Map(preferences.Temperature.FromCelsius)
+		case stats.MetricVOC:
+			summary = summary.Map(preferences.VOC.FromPPB)
+		}
+		info.Metrics[string(metric)] = SummaryInfo(summary)
+	}
+
+	for _, band := range stats.Bands {
+		info.TimeInBand = append(info.TimeInBand, BandTimeInfo{
+			Band:    string(band),
+			Seconds: int64(report.TimeInBand[band].Seconds()),
+			Percent: report.BandShare(band) * 100,
+		})
+	}
+
+	for _, hour := range report.WorstHours {
+		info.WorstHours = append(info.WorstHours, HourScoreInfo{Hour: hour.Hour, Score: hour.Score})
+	}
+
+	return info
+}
+
+// printStatsTable prints a statistics report as aligned tables
+func printStatsTable(title string, info StatsInfo, preferences units.Preferences) {
+	fmt.Printf("%s (%s to %s, %d measurements)\n\n", title, info.From, info.To, info.Count)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "METRIC\tMIN\tMAX\tMEAN\tMEDIAN\tP95\tUNIT")
+	fmt.Fprintln(w, "------\t---\t---\t----\t------\t---\t----")
+
+	rows := []struct {
+		metric   stats.Metric
+		decimals int
+		unit     string
+	}{
+		{stats.MetricScore, 0, ""},
+		{stats.MetricTemperature, 1, info.Units.Temperature},
+		{stats.MetricHumidity, 1, "%"},
+		{stats.MetricCO2, 0, "ppm"},
+		{stats.MetricVOC, 0, info.Units.VOC},
+		{stats.MetricPM25, 1, "μg/m³"},
+	}
+
+	for _, row := range rows {
+		summary := info.Metrics[string(row.metric)]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.metric.DisplayName(),
+			preferences.FormatNumber(summary.Min, row.decimals),
+			preferences.FormatNumber(summary.Max, row.decimals),
+			preferences.FormatNumber(summary.Mean, row.decimals),
+			preferences.FormatNumber(summary.Median, row.decimals),
+			preferences.FormatNumber(summary.P95, row.decimals),
+			row.unit,
+		)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "BAND\tTIME\tSHARE")
+	fmt.Fprintln(w, "----\t----\t-----")
+
+	for _, bandTime := range info.TimeInBand {
+		fmt.Fprintf(w, "%s\t%s\t%s%%\n",
+			stats.Band(bandTime.Band).DisplayName(),
+			stats.FormatDuration(time.Duration(bandTime.Seconds)*time.Second),
+			preferences.FormatNumber(bandTime.Percent, 0),
+		)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "WORST HOURS\tAVG. SCORE")
+	fmt.Fprintln(w, "-----------\t----------")
+
+	for _, hour := range info.WorstHours {
+		fmt.Fprintf(w, "%02d:00-%02d:00\t%s\n", hour.Hour, (hour.Hour+1)%24, preferences.FormatNumber(hour.Score, 0))
+	}
+
+	w.Flush()
 }
 
 func init() {
@@ -132,5 +531,12 @@ func init() {
 
 	// Add get subcommand to measurement
 	measurementCmd.AddCommand(measurementGetCmd)
+	addDeviceFilterFlags(measurementGetCmd)
+	addOutputFlags(measurementGetCmd)
+
+	// Add stats subcommand to measurement
+	measurementCmd.AddCommand(measurementStatsCmd)
+	measurementStatsCmd.Flags().StringVarP(&statsRange, "range", "r", "7d", "Period to summarize, like 24h, 7d or 2w")
+	addOutputFlags(measurementStatsCmd)
 }
 
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputFormat        string
+	temperatureUnitFlag string
+	vocUnitFlag         string
+)
+
+// addOutputFlags adds the output format and unit override flags to a command
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format (json or table)")
+	addUnitFlags(cmd)
+}
+
+// addUnitFlags adds the unit override flags to a command
+func addUnitFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&temperatureUnitFlag, "temperature-unit", "", "Override the temperature unit (celsius, fahrenheit or kelvin)")
+	cmd.Flags().StringVar(&vocUnitFlag, "voc-unit", "", "Override the VOC unit (ppb or ugm3)")
+}
+
+// unitPreferences returns the unit preferences from the settings with any
+// overrides given on the command line applied
+func unitPreferences() (units.Preferences, error) {
+	if outputFormat != "json" && outputFormat != "table" {
+		return globals.Settings.UnitPreferences(), fmt.Errorf("unknown output format %q, expected json or table", outputFormat)
+	}
+
+	return unitOverrides()
+}
+
+// unitOverrides returns the unit preferences from the settings with the
+// unit flags applied
+func unitOverrides() (units.Preferences, error) {
+	preferences := globals.Settings.UnitPreferences()
+
+	if temperatureUnitFlag != "" {
+		unit, err := units.ParseTemperatureUnit(temperatureUnitFlag)
+		if err != nil {
+			return preferences, err
+		}
+		preferences.Temperature = unit
+	}
+
+	if vocUnitFlag != "" {
+		unit, err := units.ParseVOCUnit(vocUnitFlag)
+		if err != nil {
+			return preferences, err
+		}
+		preferences.VOC = unit
+	}
+
+	return preferences, nil
+}
+
+// printMeasurementTable prints a measurement, and optionally its indices and mould risk, as an aligned table
+func printMeasurementTable(title string, measurement MeasurementInfo, indices *IndicesInfo, mouldRisk *MouldRiskInfo, preferences units.Preferences) {
+	fmt.Printf("%s (%s)\n\n", title, measurement.Timestamp)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "METRIC\tVALUE\tUNIT")
+	fmt.Fprintln(w, "------\t-----\t----")
+
+	rows := []struct {
+		name     string
+		value    float64
+		decimals int
+		unit     string
+	}{
+		{"Score", measurement.Score, 0, ""},
+		{"Temperature", measurement.Temperature, 1, measurement.Units.Temperature},
+		{"Humidity", measurement.Humidity, 1, "%"},
+		{"CO₂", measurement.CO2, 0, "ppm"},
+		{"VOC", measurement.VOC, 0, measurement.Units.VOC},
+		{"PM2.5", measurement.PM25, 1, "μg/m³"},
+		{"Dew point", measurement.DewPoint, 1, measurement.Units.Temperature},
+		{"Heat index", measurement.HeatIndex, 1, measurement.Units.Temperature},
+		{"Humidex", measurement.Humidex, 1, ""},
+		{"Absolute humidity", measurement.AbsoluteHumidity, 1, "g/m³"},
+	}
+
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", row.name, preferences.FormatNumber(row.value, row.decimals), row.unit)
+	}
+
+	if indices == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "US AQI\t%s\t%s\n", formatIndexValue(indices.USAQI.Value), valueOrDash(indices.USAQI.Category))
+	fmt.Fprintf(w, "EU CAQI\t%s\t%s\n", formatIndexValue(indices.EUCAQI.Value), valueOrDash(indices.EUCAQI.Category))
+	fmt.Fprintf(w, "CO₂ ventilation\t%s\t\n", valueOrDash(indices.CO2.Category))
+	fmt.Fprintf(w, "VOC category\t%s\t\n", valueOrDash(indices.VOC.Category))
+	fmt.Fprintf(w, "Dominant pollutant\t%s\t\n", valueOrDash(indices.DominantPollutant))
+
+	if mouldRisk != nil {
+		fmt.Fprintf(w, "Mould risk\t%s\t%s\n", mouldRisk.Level, strings.Join(mouldRisk.Reasons, ", "))
+	}
+}
+
+// formatIndexValue formats the value of an index, a dash if it's unknown
+func formatIndexValue(value *int) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprint(*value)
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/report"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/report/pdf"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportPeriod string
+	reportFormat string
+	reportOutput string
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report [device_id_or_serial]",
+	Short: "Generate a daily or weekly air quality report",
+	Long: `Generate a report covering yesterday, or Monday to Sunday of last week, with
+averages, peaks, the time spent above thresholds, changes compared to the period
+before and graphs of every metric.
+
+Without a device the report has a section per room, devices without a room get
+a section of their own. Rooms and devices can be narrowed down with --room and --tag.
+
+Reports can be written as Markdown, HTML or PDF. PDF reports are best written
+to a file with --output.
+
+Examples:
+  gnome-desktop-air-monitor report
+  gnome-desktop-air-monitor report --period week --format html --output report.html
+  gnome-desktop-air-monitor report awair-element_12345 --period day
+  gnome-desktop-air-monitor report --room office --format pdf --output office.pdf`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: readOnlyAnnotation,
+	Run:         runReport,
+}
+
+func runReport(cmd *cobra.Command, args []string) {
+	preferences, err := unitOverrides()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	period, err := report.ParsePeriod(reportPeriod)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if reportFormat != "markdown" && reportFormat != "html" && reportFormat != "pdf" {
+		fmt.Fprintf(os.Stderr, "Error: unknown report format %q, expected markdown, html or pdf\n", reportFormat)
+		os.Exit(1)
+	}
+
+	var subjects []report.Subject
+	if len(args) == 1 {
+		device, err := findDevice(args[0])
+		if err != nil {
+			globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+			os.Exit(1)
+		}
+		subjects = []report.Subject{report.DeviceSubject(*device)}
+	} else {
+		devices, err := findDevices(filterRoom, filterTag)
+		if err != nil {
+			globals.Logger.Error("Failed to fetch devices", "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
+			os.Exit(1)
+		}
+		subjects = report.RoomSubjects(devices)
+	}
+
+	if len(subjects) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: No devices to report on")
+		os.Exit(1)
+	}
+
+	document, err := report.Build(globals.StatisticsDB(), period, subjects, time.Now())
+	if err != nil {
+		globals.Logger.Error("Failed to build report", "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to build report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeReport(document, preferences); err != nil {
+		globals.Logger.Error("Failed to write report", "error", err)
+		fmt.Fprintf(os.Stderr, "Error: Failed to write report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if reportOutput != "" {
+		fmt.Printf("Wrote %s\n", reportOutput)
+	}
+}
+
+// writeReport writes a report in the chosen format to the output file, or
+// to stdout when no file is given
+func writeReport(document report.Document, preferences units.Preferences) error {
+	if reportFormat == "pdf" {
+		if reportOutput != "" {
+			return pdf.Write(reportOutput, document, preferences)
+		}
+		return writePDFToStdout(document, preferences)
+	}
+
+	out := os.Stdout
+	if reportOutput != "" {
+		file, err := os.Create(reportOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if reportFormat == "html" {
+		return report.WriteHTML(out, document, preferences)
+	}
+	return report.WriteMarkdown(out, document, preferences)
+}
+
+// writePDFToStdout renders a PDF to a temporary file, cairo only writes
+// PDFs to files, and copies it to stdout
+func writePDFToStdout(document report.Document, preferences units.Preferences) error {
+	dir, err := os.MkdirTemp("", "air-monitor-report-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.pdf")
+	if err := pdf.Write(path, document, preferences); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(os.Stdout, file)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVarP(&reportPeriod, "period", "p", "week", "Period to cover (day or week)")
+	reportCmd.Flags().StringVarP(&reportFormat, "format", "f", "markdown", "Report format (markdown, html or pdf)")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "File to write the report to instead of stdout")
+	addDeviceFilterFlags(reportCmd)
+	addUnitFlags(reportCmd)
+}
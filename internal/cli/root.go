@@ -10,6 +10,10 @@ import (
 
 var verbose bool
 
+// readOnlyAnnotation marks commands that only read from the database, these
+// open it read-only so they don't contend with the app writing measurements
+var readOnlyAnnotation = map[string]string{"database": "read-only"}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "gnome-desktop-air-monitor",
@@ -21,6 +25,10 @@ and displays them in a user-friendly interface. It also provides a GNOME shell e
 indicator for quick access to air quality information.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		// Initialize globals before any command runs
+		if cmd.Annotations["database"] == "read-only" {
+			globals.InitializeReadOnly(verbose)
+			return
+		}
 		globals.Initialize(verbose)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
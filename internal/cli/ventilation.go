@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/globals"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/ventilation"
+	"github.com/spf13/cobra"
+)
+
+var ventilationRange string
+
+// deviceVentilationCmd represents the device ventilation command
+var deviceVentilationCmd = &cobra.Command{
+	Use:   "ventilation [device_id_or_serial]",
+	Short: "Estimate the ventilation and occupancy of rooms from CO₂",
+	Long: `Estimate how well the rooms of devices are ventilated, and when and by how many
+people they were occupied, from the CO₂ they measured.
+
+Air changes per hour are estimated from how fast CO₂ falls once a room empties.
+With the volume of the room set, with 'device edit --volume', the outdoor airflow
+and the headcount are estimated too, and the airflow per person is rated by its
+EN 16798-1 category.
+
+Without a device every device is estimated, narrowed down with --room and --tag.
+
+Examples:
+  gnome-desktop-air-monitor device ventilation
+  gnome-desktop-air-monitor device ventilation awair-element_12345 --range 14d --format table`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: readOnlyAnnotation,
+	Run:         runDeviceVentilation,
+}
+
+func runDeviceVentilation(cmd *cobra.Command, args []string) {
+	preferences, err := unitPreferences()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	period, err := stats.ParseRange(ventilationRange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var devices []models.Device
+	if len(args) == 1 {
+		device, err := findDevice(args[0])
+		if err != nil {
+			globals.Logger.Error("Device not found", "identifier", args[0], "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Device not found: %s\n", args[0])
+			os.Exit(1)
+		}
+		devices = []models.Device{*device}
+	} else {
+		devices, err = findDevices(filterRoom, filterTag)
+		if err != nil {
+			globals.Logger.Error("Failed to fetch devices", "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to fetch devices: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	to := time.Now()
+	response := make([]VentilationInfo, 0, len(devices))
+	for _, device := range devices {
+		estimate, err := ventilation.ForDevice(globals.StatisticsDB(), device, to.Add(-period), to)
+		if err != nil {
+			globals.Logger.Error("Failed to estimate ventilation", "device_id", device.ID, "error", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to estimate the ventilation of %s: %v\n", device.Name, err)
+			os.Exit(1)
+		}
+		response = append(response, newVentilationInfo(device, estimate))
+	}
+
+	if outputFormat != "table" {
+		printJSON(response)
+		return
+	}
+
+	if len(response) == 0 {
+		fmt.Println("No devices found.")
+		return
+	}
+	for i, info := range response {
+		if i > 0 {
+			fmt.Println()
+		}
+		printVentilationTable(info, preferences)
+	}
+}
+
+// VentilationInfo represents the ventilation and occupancy of a room for JSON output
+type VentilationInfo struct {
+	Device           DeviceInfo      `json:"device"`
+	From             string          `json:"from"`
+	To               string          `json:"to"`
+	AirChanges       *float64        `json:"air_changes_per_hour"`
+	Airflow          *float64        `json:"airflow_m3_per_hour"`
+	AirflowPerPerson *float64        `json:"airflow_l_per_second_per_person"`
+	Category         string          `json:"category,omitempty"`
+	OccupiedSeconds  int64           `json:"occupied_seconds"`
+	Decays           []DecayInfo     `json:"decays"`
+	Occupancies      []OccupancyInfo `json:"occupancies"`
+}
+
+// DecayInfo represents a fall of CO₂ for JSON output
+type DecayInfo struct {
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	StartCO2   float64 `json:"start_co2"`
+	EndCO2     float64 `json:"end_co2"`
+	AirChanges float64 `json:"air_changes_per_hour"`
+}
+
+// OccupancyInfo represents a period the room was occupied for JSON output
+type OccupancyInfo struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	PeakCO2 float64  `json:"peak_co2"`
+	People  *float64 `json:"people"`
+}
+
+func newVentilationInfo(device models.Device, estimate ventilation.Estimate) VentilationInfo {
+	info := VentilationInfo{
+		Device:          newDeviceInfo(device),
+		From:            estimate.From.Format("2006-01-02T15:04:05Z07:00"),
+		To:              estimate.To.Format("2006-01-02T15:04:05Z07:00"),
+		OccupiedSeconds: int64(estimate.OccupiedTime().Seconds()),
+		Decays:          []DecayInfo{},
+		Occupancies:     []OccupancyInfo{},
+	}
+
+	if airChanges, ok := estimate.AirChanges(); ok {
+		info.AirChanges = &airChanges
+	}
+	if airflow, ok := estimate.Airflow(); ok {
+		info.Airflow = &airflow
+	}
+	if perPerson, ok := estimate.AirflowPerPerson(); ok {
+		info.AirflowPerPerson = &perPerson
+		info.Category = ventilation.AirflowCategory(perPerson).Name
+	}
+
+	for _, decay := range estimate.Decays {
+		info.Decays = append(info.Decays, DecayInfo{
+			From:       decay.From.Format("2006-01-02T15:04:05Z07:00"),
+			To:         decay.To.Format("2006-01-02T15:04:05Z07:00"),
+			StartCO2:   decay.StartCO2,
+			EndCO2:     decay.EndCO2,
+			AirChanges: decay.AirChanges,
+		})
+	}
+
+	for _, occupancy := range estimate.Occupancies {
+		occupancyInfo := OccupancyInfo{
+			From:    occupancy.From.Format("2006-01-02T15:04:05Z07:00"),
+			To:      occupancy.To.Format("2006-01-02T15:04:05Z07:00"),
+			PeakCO2: occupancy.PeakCO2,
+		}
+		if estimate.Volume > 0 {
+			people := occupancy.People
+			occupancyInfo.People = &people
+		}
+		info.Occupancies = append(info.Occupancies, occupancyInfo)
+	}
+
+	return info
+}
+
+// printVentilationTable prints the ventilation and occupancy of a room as aligned tables
+func printVentilationTable(info VentilationInfo, preferences units.Preferences) {
+	fmt.Printf("%s (%s to %s)\n\n", info.Device.Name, info.From, info.To)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	optional := func(value *float64, decimals int, unit string) string {
+		if value == nil {
+			return "-"
+		}
+		return preferences.FormatNumber(*value, decimals) + " " + unit
+	}
+
+	volume := "-"
+	if info.Device.RoomVolume > 0 {
+		volume = preferences.FormatNumber(info.Device.RoomVolume, 0) + " m³"
+	}
+
+	fmt.Fprintf(w, "Room volume\t%s\n", volume)
+	fmt.Fprintf(w, "Air changes\t%s\n", optional(info.AirChanges, 1, "per hour"))
+	fmt.Fprintf(w, "Outdoor airflow\t%s\n", optional(info.Airflow, 0, "m³/h"))
+	fmt.Fprintf(w, "Airflow per person\t%s\n", optional(info.AirflowPerPerson, 1, "l/s"))
+	fmt.Fprintf(w, "Category\t%s\n", valueOrDash(info.Category))
+	fmt.Fprintf(w, "Occupied\t%s\n", stats.FormatDuration(time.Duration(info.OccupiedSeconds)*time.Second))
+
+	if len(info.Occupancies) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "OCCUPIED FROM\tTO\tPEOPLE\tPEAK CO₂")
+		fmt.Fprintln(w, "-------------\t--\t------\t--------")
+
+		for _, occupancy := range info.Occupancies {
+			from, _ := time.Parse(time.RFC3339, occupancy.From)
+			to, _ := time.Parse(time.RFC3339, occupancy.To)
+			people := "-"
+			if occupancy.People != nil {
+				people = "~" + preferences.FormatNumber(math.Max(1, math.Round(*occupancy.People)), 0)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s ppm\n",
+				from.Local().Format("Mon Jan 2 15:04"),
+				to.Local().Format("15:04"),
+				people,
+				preferences.FormatNumber(occupancy.PeakCO2, 0),
+			)
+		}
+	}
+
+	w.Flush()
+
+	if info.Device.RoomVolume <= 0 {
+		fmt.Println("\nSet the room volume with 'device edit --volume' to estimate the airflow and headcount.")
+	}
+	if info.AirChanges == nil {
+		fmt.Println("\nCO₂ never fell for long enough to estimate the air changes.")
+	}
+}
+
+func init() {
+	deviceCmd.AddCommand(deviceVentilationCmd)
+	deviceVentilationCmd.Flags().StringVarP(&ventilationRange, "range", "r", "7d", "Period to estimate from, like 24h, 7d or 2w")
+	deviceVentilationCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format (json or table)")
+	addDeviceFilterFlags(deviceVentilationCmd)
+}
@@ -0,0 +1,102 @@
+// Package comfort computes how warm and humid air feels, and the risk of
+// mould growing, from the temperature and humidity of measurements.
+package comfort
+
+import (
+	"math"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+// Magnus formula coefficients for water above 0 °C
+const (
+	magnusB = 17.62
+	magnusC = 243.12
+)
+
+// Indices holds the comfort indices of a measurement
+type Indices struct {
+	DewPoint         float64 // °C
+	HeatIndex        float64 // °C, how hot the air feels by the US NWS formula
+	Humidex          float64 // How hot the air feels by the Canadian formula, in degrees Celsius
+	AbsoluteHumidity float64 // g/m³
+}
+
+// Compute returns the comfort indices of a measurement
+func Compute(measurement models.Measurement) Indices {
+	dewPoint := DewPointOf(measurement)
+
+	return Indices{
+		DewPoint:         dewPoint,
+		HeatIndex:        HeatIndex(measurement.Temperature, measurement.Humidity),
+		Humidex:          Humidex(measurement.Temperature, dewPoint),
+		AbsoluteHumidity: AbsoluteHumidity(measurement.Temperature, measurement.Humidity),
+	}
+}
+
+// DewPointOf returns the dew point reported by the device, or computes it
+// for measurements stored before the dew point was
+func DewPointOf(measurement models.Measurement) float64 {
+	if measurement.DewPoint != nil {
+		return *measurement.DewPoint
+	}
+	return DewPoint(measurement.Temperature, measurement.Humidity)
+}
+
+// saturationPressure returns the saturation vapour pressure of water in hPa at a temperature in °C
+func saturationPressure(temperature float64) float64 {
+	return 6.112 * math.Exp(magnusB*temperature/(magnusC+temperature))
+}
+
+// DewPoint returns the temperature in °C to which air has to cool for its
+// water vapour to condense, from its temperature in °C and relative humidity
+func DewPoint(temperature, humidity float64) float64 {
+	// Perfectly dry air has no dew point, keep it finite
+	humidity = math.Max(humidity, 0.1)
+
+	gamma := math.Log(humidity/100) + magnusB*temperature/(magnusC+temperature)
+	return magnusC * gamma / (magnusB - gamma)
+}
+
+// RelativeHumidity returns the relative humidity that air with a dew point
+// has at a temperature, both in °C, capped at 100%
+func RelativeHumidity(temperature, dewPoint float64) float64 {
+	return math.Min(100, 100*saturationPressure(dewPoint)/saturationPressure(temperature))
+}
+
+// HeatIndex returns how hot the air feels in °C, by the regression of the
+// US National Weather Service, from its temperature in °C and relative humidity
+func HeatIndex(temperature, humidity float64) float64 {
+	t := temperature*9/5 + 32
+
+	// The simple formula is good enough below 80 °F
+	index := 0.5 * (t + 61 + (t-68)*1.2 + humidity*0.094)
+	if (index+t)/2 >= 80 {
+		index = -42.379 + 2.04901523*t + 10.14333127*humidity -
+			0.22475541*t*humidity - 0.00683783*t*t - 0.05481717*humidity*humidity +
+			0.00122874*t*t*humidity + 0.00085282*t*humidity*humidity -
+			0.00000199*t*t*humidity*humidity
+
+		switch {
+		case humidity < 13 && t >= 80 && t <= 112:
+			index -= (13 - humidity) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		case humidity > 85 && t >= 80 && t <= 87:
+			index += (humidity - 85) / 10 * (87 - t) / 5
+		}
+	}
+
+	return (index - 32) * 5 / 9
+}
+
+// Humidex returns how hot the air feels by the formula of Environment
+// Canada, from its temperature and dew point in °C
+func Humidex(temperature, dewPoint float64) float64 {
+	vapourPressure := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPoint)))
+	return temperature + 0.5555*(vapourPressure-10)
+}
+
+// AbsoluteHumidity returns the mass of water vapour in air in g/m³, from
+// its temperature in °C and relative humidity
+func AbsoluteHumidity(temperature, humidity float64) float64 {
+	return saturationPressure(temperature) * humidity * 2.1674 / (273.15 + temperature)
+}
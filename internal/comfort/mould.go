@@ -0,0 +1,117 @@
+package comfort
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"gorm.io/gorm"
+)
+
+const (
+	// MouldHumidity is the relative humidity above which mould starts to
+	// grow, if the air stays that humid for a while
+	MouldHumidity = 70
+
+	// MouldSurfaceHumidity is the relative humidity at a surface above which
+	// mould grows on it, the criterion of IEA Annex 14
+	MouldSurfaceHumidity = 80
+
+	// MouldWatchAfter is how long humidity has to stay above MouldHumidity
+	// for a moderate risk
+	MouldWatchAfter = time.Hour
+
+	// MouldRiskAfter is how long humidity has to stay above MouldHumidity
+	// for a high risk
+	MouldRiskAfter = 6 * time.Hour
+
+	// MouldRiskPeriod is how far back the mould risk is judged from
+	MouldRiskPeriod = 24 * time.Hour
+)
+
+// Names of the mould risk levels, from the lowest
+var mouldRiskNames = []string{"Low", "Moderate", "High"}
+
+// MouldRisk describes the risk of mould growing in a room
+type MouldRisk struct {
+	Level           airquality.Level
+	HumidFor        time.Duration // Longest time humidity stayed above MouldHumidity
+	SurfaceHumidity *float64      // Relative humidity at the cold surface, nil if its temperature is unknown
+	Condensation    bool          // Whether water condenses on the cold surface
+}
+
+// Reasons describes what raises the risk, one per line
+func (risk MouldRisk) Reasons() []string {
+	var reasons []string
+
+	if risk.HumidFor >= MouldWatchAfter {
+		reasons = append(reasons, fmt.Sprintf("Humidity above %d%% for %s", MouldHumidity, stats.FormatDuration(risk.HumidFor)))
+	}
+
+	switch {
+	case risk.Condensation:
+		reasons = append(reasons, "Water condenses on the cold surface")
+	case risk.SurfaceHumidity != nil && *risk.SurfaceHumidity >= MouldSurfaceHumidity:
+		reasons = append(reasons, fmt.Sprintf("%.0f%% humidity at the cold surface", *risk.SurfaceHumidity))
+	}
+
+	return reasons
+}
+
+// AssessMouldRisk judges the risk of mould from measurements ordered by
+// timestamp, and the temperature in °C of the coldest surface of the room,
+// like a wall or window, nil if unknown
+func AssessMouldRisk(measurements []models.Measurement, surfaceTemperature *float64) MouldRisk {
+	var risk MouldRisk
+
+	start := -1
+	for i := 0; i <= len(measurements); i++ {
+		humid := i < len(measurements) && measurements[i].Humidity > MouldHumidity
+		if humid && start >= 0 && measurements[i].Timestamp.Sub(measurements[i-1].Timestamp) > stats.MaxGap {
+			risk.HumidFor = max(risk.HumidFor, measurements[i-1].Timestamp.Sub(measurements[start].Timestamp))
+			start = i
+		}
+		if humid && start < 0 {
+			start = i
+		}
+		if !humid && start >= 0 {
+			risk.HumidFor = max(risk.HumidFor, measurements[i-1].Timestamp.Sub(measurements[start].Timestamp))
+			start = -1
+		}
+	}
+
+	if surfaceTemperature != nil && len(measurements) > 0 {
+		dewPoint := DewPointOf(measurements[len(measurements)-1])
+		humidity := RelativeHumidity(*surfaceTemperature, dewPoint)
+		risk.SurfaceHumidity = &humidity
+		risk.Condensation = dewPoint >= *surfaceTemperature
+	}
+
+	rank := 0
+	switch {
+	case risk.HumidFor >= MouldRiskAfter || risk.Condensation:
+		rank = 2
+	case risk.HumidFor >= MouldWatchAfter || (risk.SurfaceHumidity != nil && *risk.SurfaceHumidity >= MouldSurfaceHumidity):
+		rank = 1
+	}
+	risk.Level = airquality.Level{Rank: rank, Max: len(mouldRiskNames) - 1, Name: mouldRiskNames[rank]}
+
+	return risk
+}
+
+// MouldRiskForDevice judges the risk of mould in the room of a device from
+// its stored measurements of the last MouldRiskPeriod
+func MouldRiskForDevice(db *gorm.DB, device models.Device, now time.Time) (MouldRisk, error) {
+	var measurements []models.Measurement
+	err := db.Select("timestamp, temperature, humidity, dew_point").
+		Where("device_id = ? AND timestamp BETWEEN ? AND ?", device.ID, now.Add(-MouldRiskPeriod).UTC(), now.UTC()).
+		Order("timestamp ASC").
+		Find(&measurements).Error
+	if err != nil {
+		return MouldRisk{}, err
+	}
+
+	return AssessMouldRisk(measurements, device.SurfaceTemperature), nil
+}
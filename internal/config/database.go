@@ -16,3 +16,8 @@ func DBPath() string {
 
 	return filepath.Join(DataDir(), DB_NAME)
 }
+
+// BackupDir returns the directory database backups are written to by default
+func BackupDir() string {
+	return filepath.Join(DataDir(), "backups")
+}
@@ -0,0 +1,173 @@
+// Package gsettings stores the app settings in GSettings.
+//
+// The schema has to be compiled and installed, see the install-schemas
+// target in the Makefile. For development, point GSETTINGS_SCHEMA_DIR at a
+// directory with the compiled schema, and set GSETTINGS_BACKEND=memory to
+// run without touching the user's dconf database.
+package gsettings
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	gio "github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+)
+
+const (
+	// SchemaID is the id of the app's GSettings schema
+	SchemaID = "io.stanko.gnome-desktop-air-monitor"
+
+	// importedKey records that the settings file was imported
+	importedKey = "imported-settings-file"
+)
+
+// ErrSchemaNotInstalled is returned when the schema can't be found
+var ErrSchemaNotInstalled = errors.New("GSettings schema " + SchemaID + " is not installed")
+
+// Store stores settings in GSettings
+type Store struct {
+	settings *gio.Settings
+	handler  coreglib.SignalHandle
+	watching bool
+}
+
+// New opens the app's GSettings. GSettings aborts the process when a
+// schema is missing, so the schema is looked up first.
+func New() (*Store, error) {
+	source := gio.SettingsSchemaSourceGetDefault()
+	if source == nil || source.Lookup(SchemaID, true) == nil {
+		return nil, ErrSchemaNotInstalled
+	}
+
+	return &Store{settings: gio.NewSettings(SchemaID)}, nil
+}
+
+// gsettingsKey converts a settings key to the name of its GSettings key
+func gsettingsKey(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+func (store *Store) Load() (*config.Settings, error) {
+	settings := config.DefaultSettings()
+
+	for _, key := range config.Keys {
+		name := gsettingsKey(key)
+
+		current, err := settings.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		switch current.(type) {
+		case string:
+			value = store.settings.String(name)
+		case int:
+			value = store.settings.Int(name)
+		case bool:
+			value = store.settings.Boolean(name)
+		default:
+			return nil, fmt.Errorf("unsupported type %T of setting %s", current, key)
+		}
+
+		if err := settings.Set(key, value); err != nil {
+			return nil, fmt.Errorf("invalid value in GSettings: %w", err)
+		}
+	}
+
+	return settings, nil
+}
+
+func (store *Store) Save(settings *config.Settings) error {
+	for _, key := range config.Keys {
+		name := gsettingsKey(key)
+
+		value, err := settings.Get(key)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		switch v := value.(type) {
+		case string:
+			ok = store.settings.SetString(name, v)
+		case int:
+			ok = store.settings.SetInt(name, v)
+		case bool:
+			ok = store.settings.SetBoolean(name, v)
+		}
+
+		if !ok {
+			return fmt.Errorf("GSettings rejected %v for %s", value, key)
+		}
+	}
+
+	// Short-lived processes like the CLI would otherwise exit before the write lands
+	gio.SettingsSync()
+	return nil
+}
+
+// Watch calls onChange whenever a setting changes. GSettings doesn't tell
+// apart changes made by this process, so onChange also sees its own writes.
+// The callback runs on the GLib main loop.
+func (store *Store) Watch(onChange func(*config.Settings), onError func(error)) error {
+	if store.watching {
+		store.settings.HandlerDisconnect(store.handler)
+	}
+
+	store.handler = store.settings.ConnectChanged(func(key string) {
+		if key == importedKey {
+			return
+		}
+
+		settings, err := store.Load()
+		if err != nil {
+			onError(err)
+			return
+		}
+
+		onChange(settings)
+	})
+	store.watching = true
+
+	return nil
+}
+
+func (store *Store) Close() error {
+	if store.watching {
+		store.settings.HandlerDisconnect(store.handler)
+		store.watching = false
+	}
+
+	gio.SettingsSync()
+	return nil
+}
+
+func (store *Store) Location() string {
+	return "GSettings " + SchemaID
+}
+
+// ImportFrom copies the settings from another store the first time GSettings
+// is used, so settings made before the switch to GSettings are kept
+func (store *Store) ImportFrom(source config.Store) (bool, error) {
+	if store.settings.Boolean(importedKey) {
+		return false, nil
+	}
+
+	settings, err := source.Load()
+	if err != nil {
+		// Nothing to import, don't try again
+		store.settings.SetBoolean(importedKey, true)
+		return false, nil
+	}
+
+	if err := store.Save(settings); err != nil {
+		return false, err
+	}
+
+	store.settings.SetBoolean(importedKey, true)
+	return true, nil
+}
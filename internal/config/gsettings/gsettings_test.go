@@ -0,0 +1,225 @@
+package gsettings
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gio "github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// schemaFile is the app's schema, relative to this package
+const schemaFile = "../../../data/" + SchemaID + ".gschema.xml"
+
+// TestMain compiles the app's schema into a temporary directory and keeps
+// settings in memory, so the tests neither need the schema installed nor
+// touch the user's settings
+func TestMain(m *testing.M) {
+	os.Exit(runWithSchema(m))
+}
+
+func runWithSchema(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "gsettings-test-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	schema, err := os.ReadFile(schemaFile)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, filepath.Base(schemaFile)), schema, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	output, err := exec.Command("glib-compile-schemas", "--strict", dir).CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to compile %s: %v\n%s", schemaFile, err, output)
+		return 1
+	}
+
+	// Both are read when GSettings is first used
+	os.Setenv("GSETTINGS_SCHEMA_DIR", dir)
+	os.Setenv("GSETTINGS_BACKEND", "memory")
+
+	return m.Run()
+}
+
+// newTestStore opens the store with every key at its default
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := New()
+	if err != nil {
+		t.Fatalf("failed to open GSettings: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	for _, key := range config.Keys {
+		store.settings.Reset(gsettingsKey(key))
+	}
+	store.settings.Reset(importedKey)
+	return store
+}
+
+// customSettings returns settings with every key changed from its default
+func customSettings(t *testing.T) *config.Settings {
+	t.Helper()
+
+	values := map[string]interface{}{
+		config.KeyStatusBarDeviceSerialNumber: "awair-element_1",
+		config.KeyDataRetentionPeriod:         30,
+		config.KeyShowShellExtension:          false,
+		config.KeyTemperatureUnit:             string(units.Fahrenheit),
+		config.KeyVOCUnit:                     string(units.MicrogramsPerCubicMeter),
+		config.KeyBackupInterval:              3,
+		config.KeyBackupCount:                 12,
+		config.KeyWeeklyReport:                true,
+		config.KeyExcludeAnomalies:            true,
+		config.KeyCO2Threshold:                1400,
+	}
+
+	defaults := config.DefaultSettings().Values()
+	settings := config.DefaultSettings()
+	for _, key := range config.Keys {
+		value, ok := values[key]
+		if !ok {
+			t.Fatalf("no test value for %s", key)
+		}
+		if value == defaults[key] {
+			t.Fatalf("test value of %s is its default", key)
+		}
+		if err := settings.Set(key, value); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+	}
+	return settings
+}
+
+func TestSchemaHasEveryKey(t *testing.T) {
+	schema := gio.SettingsSchemaSourceGetDefault().Lookup(SchemaID, true)
+	if schema == nil {
+		t.Fatalf("schema %s not found", SchemaID)
+	}
+
+	for _, key := range config.Keys {
+		if !schema.HasKey(gsettingsKey(key)) {
+			t.Errorf("schema has no key %s for %s", gsettingsKey(key), key)
+		}
+	}
+}
+
+func TestDefaultsMatchSettings(t *testing.T) {
+	store := newTestStore(t)
+
+	settings, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	if got, want := settings.Values(), config.DefaultSettings().Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema defaults are %v, want %v", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	store := newTestStore(t)
+	saved := customSettings(t)
+
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	want := saved.Values()
+	got := loaded.Values()
+	for _, key := range config.Keys {
+		if got[key] != want[key] {
+			t.Errorf("%s = %v after a round trip, want %v", key, got[key], want[key])
+		}
+	}
+}
+
+func TestImportFrom(t *testing.T) {
+	store := newTestStore(t)
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+	fileStore := config.NewJSONStore(path)
+	saved := customSettings(t)
+	if err := fileStore.Save(saved); err != nil {
+		t.Fatalf("failed to write settings file: %v", err)
+	}
+
+	imported, err := store.ImportFrom(fileStore)
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if !imported {
+		t.Fatal("settings file wasn't imported")
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if got, want := loaded.Values(), saved.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported settings are %v, want %v", got, want)
+	}
+
+	// Later changes to the file are left alone
+	if err := fileStore.Save(config.DefaultSettings()); err != nil {
+		t.Fatalf("failed to write settings file: %v", err)
+	}
+
+	imported, err = store.ImportFrom(fileStore)
+	if err != nil {
+		t.Fatalf("failed to import again: %v", err)
+	}
+	if imported {
+		t.Error("settings file was imported twice")
+	}
+
+	loaded, err = store.Load()
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if got, want := loaded.Values(), saved.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("settings are %v after importing again, want %v", got, want)
+	}
+}
+
+func TestImportFromMissingFile(t *testing.T) {
+	store := newTestStore(t)
+	fileStore := config.NewJSONStore(filepath.Join(t.TempDir(), "settings.json"))
+
+	imported, err := store.ImportFrom(fileStore)
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if imported {
+		t.Error("missing settings file was imported")
+	}
+	if !store.settings.Boolean(importedKey) {
+		t.Error("import will be tried again")
+	}
+
+	// A settings file written afterwards isn't imported either
+	if err := fileStore.Save(customSettings(t)); err != nil {
+		t.Fatalf("failed to write settings file: %v", err)
+	}
+	if imported, _ := store.ImportFrom(fileStore); imported {
+		t.Error("settings file was imported after GSettings was used")
+	}
+}
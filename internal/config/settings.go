@@ -2,68 +2,206 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
 )
 
 type Settings struct {
-	StatusBarDeviceSerialNumber *string `json:"status_bar_device_serial_number"`
-	DataRetentionPeriod         int     `json:"data_retention_period,omitempty"` // in days, optional
-	ShowShellExtension          bool    `json:"show_shell_extension"`
+	Version                     int                   `json:"version"`
+	StatusBarDeviceSerialNumber *string               `json:"status_bar_device_serial_number"`
+	DataRetentionPeriod         int                   `json:"data_retention_period,omitempty"` // in days, optional
+	ShowShellExtension          bool                  `json:"show_shell_extension"`
+	TemperatureUnit             units.TemperatureUnit `json:"temperature_unit,omitempty"`
+	VOCUnit                     units.VOCUnit         `json:"voc_unit,omitempty"`
+	BackupInterval              int                   `json:"backup_interval"`   // in days, 0 disables automatic backups
+	BackupCount                 int                   `json:"backup_count"`      // automatic backups to keep
+	WeeklyReport                bool                  `json:"weekly_report"`     // notify with a report every Monday morning
+	ExcludeAnomalies            bool                  `json:"exclude_anomalies"` // leave measurements that look like sensor faults out of statistics
+	CO2Threshold                int                   `json:"co2_threshold"`     // ppm at which a room should be ventilated
+
+	store Store // Where the settings are saved, the default settings file if nil
 }
 
 func DefaultSettingsPath() string {
 	return filepath.Join(ConfigDir(), "settings.json")
 }
 
-func LoadOrInitializeSettingsFromDefaultLocation() (bool, *Settings) {
+func LoadOrInitializeSettingsFromDefaultLocation() (bool, *Settings, error) {
 	return LoadOrInitializeSettings(DefaultSettingsPath())
 }
 
-func LoadOrInitializeSettings(path string) (bool, *Settings) {
-	if settings, err := LoadSettings(path); err == nil {
-		return false, settings
+func LoadOrInitializeSettings(path string) (bool, *Settings, error) {
+	return LoadOrInitializeSettingsFromStore(NewJSONStore(path))
+}
+
+// LoadOrInitializeSettingsFromStore loads settings from a store, falling
+// back to the defaults if nothing is stored yet. The settings are saved
+// back to the same store.
+//
+// Settings that can't be loaded are replaced by the defaults too. Stores
+// that support it back up the unreadable settings first, and the returned
+// error explains what was wrong and where the backup is.
+func LoadOrInitializeSettingsFromStore(store Store) (bool, *Settings, error) {
+	settings, err := store.Load()
+	if err == nil {
+		settings.store = store
+		return false, settings, nil
+	}
+
+	defaults := DefaultSettings()
+	defaults.store = store
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, defaults, nil
 	}
 
-	return true, &Settings{
+	if backupStore, ok := store.(interface{ Backup() (string, error) }); ok {
+		backupPath, backupErr := backupStore.Backup()
+		if backupErr != nil {
+			return true, defaults, fmt.Errorf("%w (backup failed: %v)", err, backupErr)
+		}
+		return true, defaults, fmt.Errorf("%w (the file was moved to %s)", err, backupPath)
+	}
+
+	return true, defaults, err
+}
+
+// DefaultSettings returns the settings used before anything was configured
+func DefaultSettings() *Settings {
+	return &Settings{
+		Version:                     CurrentSettingsVersion,
 		StatusBarDeviceSerialNumber: nil,
 		DataRetentionPeriod:         7,
 		ShowShellExtension:          true,
+		TemperatureUnit:             units.Celsius,
+		VOCUnit:                     units.PartsPerBillion,
+		BackupInterval:              0,
+		BackupCount:                 DefaultBackupCount,
+		WeeklyReport:                false,
+		ExcludeAnomalies:            false,
+		CO2Threshold:                DefaultCO2Threshold,
 	}
 }
 
-func LoadSettings(path string) (*Settings, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, err
+// Store returns the store the settings are saved to
+func (s *Settings) Store() Store {
+	if s.store == nil {
+		return NewJSONStore(DefaultSettingsPath())
 	}
+	return s.store
+}
+
+// ChangedKeys returns the keys of the settings whose values differ between s and other
+func (s *Settings) ChangedKeys(other *Settings) []string {
+	current := s.Values()
+	updated := other.Values()
+
+	var keys []string
+	for _, key := range Keys {
+		if current[key] != updated[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Update replaces the values of s with the values of other, keeping the store of s
+func (s *Settings) Update(other *Settings) {
+	store := s.store
+	*s = *other
+	s.store = store
+}
 
+// UnitPreferences returns the preferred units for displaying measurements
+func (s *Settings) UnitPreferences() units.Preferences {
+	return units.NewPreferences(s.TemperatureUnit, s.VOCUnit)
+}
+
+// LoadSettings reads, migrates and validates a settings file. Settings the
+// file doesn't have keep their defaults.
+func LoadSettings(path string) (*Settings, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("settings file %s is not valid JSON: %w", path, err)
+	}
+
+	if err := migrateSettings(values); err != nil {
+		return nil, fmt.Errorf("settings file %s: %w", path, err)
+	}
+
+	data, err = json.Marshal(values)
+	if err != nil {
 		return nil, err
 	}
 
-	return &settings, nil
+	settings := DefaultSettings()
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("settings file %s has a value of the wrong type: %w", path, err)
+	}
+
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("settings file %s: %w", path, err)
+	}
+
+	return settings, nil
 }
 
 func (s *Settings) Save() error {
-	return s.SaveTo(DefaultSettingsPath())
+	return s.Store().Save(s)
 }
 
+// SaveTo writes the settings to a temporary file next to path and renames
+// it over path, so a crash never leaves a half written settings file
 func (s *Settings) SaveTo(path string) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
+	s.Version = CurrentSettingsVersion
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath) // No-op after a successful rename
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tempPath, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
 }
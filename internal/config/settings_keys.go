@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// Setting keys, matching the keys of the settings file
+const (
+	KeyStatusBarDeviceSerialNumber = "status_bar_device_serial_number"
+	KeyDataRetentionPeriod         = "data_retention_period"
+	KeyShowShellExtension          = "show_shell_extension"
+	KeyTemperatureUnit             = "temperature_unit"
+	KeyVOCUnit                     = "voc_unit"
+	KeyBackupInterval              = "backup_interval"
+	KeyBackupCount                 = "backup_count"
+	KeyWeeklyReport                = "weekly_report"
+	KeyExcludeAnomalies            = "exclude_anomalies"
+	KeyCO2Threshold                = "co2_threshold"
+)
+
+// Keys lists all setting keys in display order
+var Keys = []string{
+	KeyStatusBarDeviceSerialNumber,
+	KeyDataRetentionPeriod,
+	KeyShowShellExtension,
+	KeyTemperatureUnit,
+	KeyVOCUnit,
+	KeyBackupInterval,
+	KeyBackupCount,
+	KeyWeeklyReport,
+	KeyExcludeAnomalies,
+	KeyCO2Threshold,
+}
+
+// Bounds of the data retention period in days
+const (
+	MinDataRetentionPeriod = 1
+	MaxDataRetentionPeriod = 365
+)
+
+// Bounds of the automatic backup interval in days and of the number of automatic backups kept
+const (
+	MinBackupInterval  = 0
+	MaxBackupInterval  = 30
+	MinBackupCount     = 1
+	MaxBackupCount     = 100
+	DefaultBackupCount = 7
+)
+
+// Bounds of the CO₂ level in ppm at which a room should be ventilated
+const (
+	MinCO2Threshold     = 600
+	MaxCO2Threshold     = 5000
+	DefaultCO2Threshold = 1000
+)
+
+// Get returns the value of a setting. The status bar device is an empty
+// string when none is selected, the retention period and CO₂ threshold are ints, the
+// shell extension visibility, weekly report and anomaly exclusion bools and
+// units their names.
+func (s *Settings) Get(key string) (interface{}, error) {
+	switch key {
+	case KeyStatusBarDeviceSerialNumber:
+		if s.StatusBarDeviceSerialNumber == nil {
+			return "", nil
+		}
+		return *s.StatusBarDeviceSerialNumber, nil
+	case KeyDataRetentionPeriod:
+		return s.DataRetentionPeriod, nil
+	case KeyShowShellExtension:
+		return s.ShowShellExtension, nil
+	case KeyTemperatureUnit:
+		return string(s.UnitPreferences().Temperature), nil
+	case KeyVOCUnit:
+		return string(s.UnitPreferences().VOC), nil
+	case KeyBackupInterval:
+		return s.BackupInterval, nil
+	case KeyBackupCount:
+		return s.BackupCount, nil
+	case KeyWeeklyReport:
+		return s.WeeklyReport, nil
+	case KeyExcludeAnomalies:
+		return s.ExcludeAnomalies, nil
+	case KeyCO2Threshold:
+		return s.CO2Threshold, nil
+	default:
+		return nil, unknownKeyError(key)
+	}
+}
+
+// Values returns the values of all settings by key
+func (s *Settings) Values() map[string]interface{} {
+	values := make(map[string]interface{}, len(Keys))
+	for _, key := range Keys {
+		values[key], _ = s.Get(key)
+	}
+	return values
+}
+
+// Set validates a value and assigns it to a setting. Numbers of any
+// integer type are accepted for the retention period and CO₂ threshold.
+func (s *Settings) Set(key string, value interface{}) error {
+	switch key {
+	case KeyStatusBarDeviceSerialNumber:
+		serial, ok := value.(string)
+		if !ok {
+			return typeError(key, "a string", value)
+		}
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			s.StatusBarDeviceSerialNumber = nil
+		} else {
+			s.StatusBarDeviceSerialNumber = &serial
+		}
+	case KeyDataRetentionPeriod:
+		days, err := intInRange(key, value, MinDataRetentionPeriod, MaxDataRetentionPeriod)
+		if err != nil {
+			return err
+		}
+		s.DataRetentionPeriod = days
+	case KeyShowShellExtension:
+		visible, ok := value.(bool)
+		if !ok {
+			return typeError(key, "a boolean", value)
+		}
+		s.ShowShellExtension = visible
+	case KeyTemperatureUnit:
+		name, ok := value.(string)
+		if !ok {
+			return typeError(key, "a string", value)
+		}
+		unit, err := units.ParseTemperatureUnit(name)
+		if err != nil {
+			return err
+		}
+		s.TemperatureUnit = unit
+	case KeyVOCUnit:
+		name, ok := value.(string)
+		if !ok {
+			return typeError(key, "a string", value)
+		}
+		unit, err := units.ParseVOCUnit(name)
+		if err != nil {
+			return err
+		}
+		s.VOCUnit = unit
+	case KeyBackupInterval:
+		days, err := intInRange(key, value, MinBackupInterval, MaxBackupInterval)
+		if err != nil {
+			return err
+		}
+		s.BackupInterval = days
+	case KeyBackupCount:
+		count, err := intInRange(key, value, MinBackupCount, MaxBackupCount)
+		if err != nil {
+			return err
+		}
+		s.BackupCount = count
+	case KeyWeeklyReport:
+		enabled, ok := value.(bool)
+		if !ok {
+			return typeError(key, "a boolean", value)
+		}
+		s.WeeklyReport = enabled
+	case KeyExcludeAnomalies:
+		exclude, ok := value.(bool)
+		if !ok {
+			return typeError(key, "a boolean", value)
+		}
+		s.ExcludeAnomalies = exclude
+	case KeyCO2Threshold:
+		ppm, err := intInRange(key, value, MinCO2Threshold, MaxCO2Threshold)
+		if err != nil {
+			return err
+		}
+		s.CO2Threshold = ppm
+	default:
+		return unknownKeyError(key)
+	}
+
+	return nil
+}
+
+// ParseValue converts the text form of a setting, as typed on the command
+// line, to the type expected by Set. The value isn't validated beyond its type.
+func ParseValue(key string, text string) (interface{}, error) {
+	switch key {
+	case KeyStatusBarDeviceSerialNumber, KeyTemperatureUnit, KeyVOCUnit:
+		return strings.TrimSpace(text), nil
+	case KeyDataRetentionPeriod, KeyBackupInterval, KeyBackupCount:
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(text), "d"))
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number, got %q", key, text)
+		}
+		return number, nil
+	case KeyCO2Threshold:
+		number, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "ppm")))
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number of ppm, got %q", key, text)
+		}
+		return number, nil
+	case KeyShowShellExtension, KeyWeeklyReport, KeyExcludeAnomalies:
+		switch strings.ToLower(strings.TrimSpace(text)) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		default:
+			return nil, fmt.Errorf("%s must be true or false, got %q", key, text)
+		}
+	default:
+		return nil, unknownKeyError(key)
+	}
+}
+
+// intInRange converts a value to an int and checks that it's within bounds
+func intInRange(key string, value interface{}, min int, max int) (int, error) {
+	number, ok := toInt(value)
+	if !ok {
+		return 0, typeError(key, "an integer", value)
+	}
+	if number < min || number > max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %d", key, min, max, number)
+	}
+	return number, nil
+}
+
+// toInt converts any integer type to an int
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		if v > math.MaxInt32 {
+			return 0, false
+		}
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+func unknownKeyError(key string) error {
+	return fmt.Errorf("unknown setting %q, expected one of %s", key, strings.Join(Keys, ", "))
+}
+
+func typeError(key string, expected string, value interface{}) error {
+	return fmt.Errorf("%s must be %s, got %T", key, expected, value)
+}
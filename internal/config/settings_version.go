@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// CurrentSettingsVersion is the version of the settings file written by this build
+const CurrentSettingsVersion = 6
+
+// settingsMigrations upgrade the raw values of a settings file from the
+// version they are keyed by to the next version. Only renamed settings and
+// values whose meaning or type changed need one, settings missing from a
+// file get their defaults when it's loaded.
+var settingsMigrations = map[int]func(values map[string]interface{}){
+	// Version 1 files were written before the version field existed, a
+	// retention period of 0 meant it wasn't set
+	1: func(values map[string]interface{}) {
+		if period, ok := values[KeyDataRetentionPeriod].(float64); ok && period == 0 {
+			delete(values, KeyDataRetentionPeriod)
+		}
+	},
+}
+
+// migrateSettings upgrades raw settings values to the current version
+func migrateSettings(values map[string]interface{}) error {
+	version := 1
+	if raw, ok := values["version"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) || number < 1 {
+			return fmt.Errorf("version must be a positive integer, got %v", raw)
+		}
+		version = int(number)
+	}
+
+	if version > CurrentSettingsVersion {
+		return fmt.Errorf("settings version %d is newer than version %d supported by this build, please update the app",
+			version, CurrentSettingsVersion)
+	}
+
+	for ; version < CurrentSettingsVersion; version++ {
+		if migration, ok := settingsMigrations[version]; ok {
+			migration(values)
+		}
+	}
+
+	values["version"] = CurrentSettingsVersion
+	return nil
+}
+
+// ValidationError lists everything that is wrong with a set of settings
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid settings: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that all settings have allowed values
+func (s *Settings) Validate() error {
+	var problems []string
+
+	if s.StatusBarDeviceSerialNumber != nil && strings.TrimSpace(*s.StatusBarDeviceSerialNumber) == "" {
+		problems = append(problems, KeyStatusBarDeviceSerialNumber+" must be null or a serial number, got an empty string")
+	}
+
+	if s.DataRetentionPeriod < MinDataRetentionPeriod || s.DataRetentionPeriod > MaxDataRetentionPeriod {
+		problems = append(problems, fmt.Sprintf("%s must be between %d and %d days, got %d",
+			KeyDataRetentionPeriod, MinDataRetentionPeriod, MaxDataRetentionPeriod, s.DataRetentionPeriod))
+	}
+
+	if s.BackupInterval < MinBackupInterval || s.BackupInterval > MaxBackupInterval {
+		problems = append(problems, fmt.Sprintf("%s must be between %d and %d days, got %d",
+			KeyBackupInterval, MinBackupInterval, MaxBackupInterval, s.BackupInterval))
+	}
+
+	if s.BackupCount < MinBackupCount || s.BackupCount > MaxBackupCount {
+		problems = append(problems, fmt.Sprintf("%s must be between %d and %d, got %d",
+			KeyBackupCount, MinBackupCount, MaxBackupCount, s.BackupCount))
+	}
+
+	if s.CO2Threshold < MinCO2Threshold || s.CO2Threshold > MaxCO2Threshold {
+		problems = append(problems, fmt.Sprintf("%s must be between %d and %d ppm, got %d",
+			KeyCO2Threshold, MinCO2Threshold, MaxCO2Threshold, s.CO2Threshold))
+	}
+
+	if s.TemperatureUnit != "" {
+		if _, err := units.ParseTemperatureUnit(string(s.TemperatureUnit)); err != nil {
+			problems = append(problems, KeyTemperatureUnit+": "+err.Error())
+		}
+	}
+
+	if s.VOCUnit != "" {
+		if _, err := units.ParseVOCUnit(string(s.VOCUnit)); err != nil {
+			problems = append(problems, KeyVOCUnit+": "+err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -61,3 +61,8 @@ func ConfigDir() string {
 
 	return baseDir
 }
+
+// ReportDir returns the directory the weekly reports are saved to
+func ReportDir() string {
+	return filepath.Join(DataDir(), "reports")
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Store loads and saves settings and reports changes made by other processes
+type Store interface {
+	// Load reads the stored settings
+	Load() (*Settings, error)
+	// Save writes the settings
+	Save(settings *Settings) error
+	// Watch calls onChange with the new settings whenever they are changed,
+	// in particular by other processes, and onError when changed settings
+	// can't be loaded. Stores that can't detect changes ignore it. The
+	// callbacks may run on any goroutine.
+	Watch(onChange func(*Settings), onError func(error)) error
+	// Close stops watching for changes
+	Close() error
+	// Location describes where the settings are stored
+	Location() string
+}
+
+// JSONStore stores settings in a JSON file
+type JSONStore struct {
+	path    string
+	watcher *fileWatcher
+}
+
+// NewJSONStore creates a store for the settings file at the given path
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+func (store *JSONStore) Load() (*Settings, error) {
+	settings, err := LoadSettings(store.path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.store = store
+	return settings, nil
+}
+
+func (store *JSONStore) Save(settings *Settings) error {
+	return settings.SaveTo(store.path)
+}
+
+// Watch reloads the settings file whenever it is written or replaced
+func (store *JSONStore) Watch(onChange func(*Settings), onError func(error)) error {
+	if store.watcher != nil {
+		store.watcher.close()
+	}
+
+	watcher, err := watchFile(store.path, func() {
+		settings, err := store.Load()
+		if err != nil {
+			onError(err)
+			return
+		}
+		onChange(settings)
+	})
+	if err != nil {
+		return err
+	}
+
+	store.watcher = watcher
+	return nil
+}
+
+func (store *JSONStore) Close() error {
+	if store.watcher != nil {
+		store.watcher.close()
+		store.watcher = nil
+	}
+	return nil
+}
+
+// Backup moves an unreadable settings file aside so it isn't overwritten
+// by the defaults, and returns where it was moved to
+func (store *JSONStore) Backup() (string, error) {
+	backupPath := fmt.Sprintf("%s.corrupt-%s", store.path, time.Now().Format("20060102-150405"))
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backupPath); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		backupPath = fmt.Sprintf("%s.corrupt-%s-%d", store.path, time.Now().Format("20060102-150405"), i)
+	}
+
+	if err := os.Rename(store.path, backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+func (store *JSONStore) Location() string {
+	return store.path
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// watchDebounce groups the events of a single save, editors often write a file in several steps
+const watchDebounce = 200 * time.Millisecond
+
+// fileWatcher watches a file for changes with inotify
+type fileWatcher struct {
+	file *os.File
+}
+
+// watchFile calls onChange whenever the file at path is written or replaced.
+// The directory is watched rather than the file so that files replaced by
+// a rename, as done by SaveTo and most editors, keep being watched.
+func watchFile(path string, onChange func()) (*fileWatcher, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	// A non-blocking descriptor is handled by the runtime poller, so close unblocks the read loop
+	watcher := &fileWatcher{file: os.NewFile(uintptr(fd), "inotify")}
+	go watcher.run(filepath.Base(path), onChange)
+
+	return watcher, nil
+}
+
+func (watcher *fileWatcher) run(name string, onChange func()) {
+	var timer *time.Timer
+	buffer := make([]byte, 4096)
+
+	for {
+		n, err := watcher.file.Read(buffer)
+		if err != nil {
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			if offset > n {
+				break
+			}
+
+			eventName := strings.TrimRight(string(buffer[start:offset]), "\x00")
+			if eventName != name {
+				continue
+			}
+
+			if timer == nil {
+				timer = time.AfterFunc(watchDebounce, onChange)
+			} else {
+				timer.Reset(watchDebounce)
+			}
+		}
+	}
+}
+
+func (watcher *fileWatcher) close() {
+	watcher.file.Close()
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package config
+
+// fileWatcher is a no-op on platforms without inotify
+type fileWatcher struct{}
+
+func watchFile(path string, onChange func()) (*fileWatcher, error) {
+	return &fileWatcher{}, nil
+}
+
+func (watcher *fileWatcher) close() {}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+
+	config "github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+)
+
+// automaticBackupPrefix is the file name prefix of scheduled backups, only
+// these are rotated
+const automaticBackupPrefix = "automatic-"
+
+// backupTimeFormat is the timestamp format used in backup file names
+const backupTimeFormat = "20060102-150405"
+
+// DefaultBackupPath returns a timestamped backup path in the backup directory
+func DefaultBackupPath(now time.Time) string {
+	return filepath.Join(config.BackupDir(), fmt.Sprintf("database-%s.sqlite", now.Format(backupTimeFormat)))
+}
+
+// uniquePath appends a counter to path when a file with that name already exists
+func uniquePath(path string) string {
+	extension := filepath.Ext(path)
+	base := path[:len(path)-len(extension)]
+
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, extension)
+	}
+}
+
+// Backup writes a consistent copy of the database to path. VACUUM INTO
+// works while the app is using the database and compacts the copy.
+func Backup(db *gorm.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	return nil
+}
+
+// Restore replaces the contents of the database with a backup using
+// SQLite's online backup API, so other connections see the restored data
+// without reopening the database. The backup is checked first, and the
+// current database is backed up before it is overwritten. Backups made
+// by older versions of the app are migrated after they are restored.
+// The path of the backup of the current database is returned.
+func Restore(db *gorm.DB, path string) (string, error) {
+	if err := checkBackup(path); err != nil {
+		return "", err
+	}
+
+	snapshot := uniquePath(filepath.Join(config.BackupDir(),
+		fmt.Sprintf("pre-restore-%s.sqlite", time.Now().Format(backupTimeFormat))))
+	if err := Backup(db, snapshot); err != nil {
+		return "", fmt.Errorf("refusing to restore without a backup of the current database: %w", err)
+	}
+
+	// Keep writes made through Write out until the restore is done
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+
+	source, err := sql.Open("sqlite3", dataSourceName(path, true))
+	if err != nil {
+		return snapshot, err
+	}
+	defer source.Close()
+
+	destination, err := db.DB()
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = withSQLiteConn(destination, func(destinationConn *sqlite3.SQLiteConn) error {
+		return withSQLiteConn(source, func(sourceConn *sqlite3.SQLiteConn) error {
+			backup, err := destinationConn.Backup("main", sourceConn, "main")
+			if err != nil {
+				return err
+			}
+
+			// Retry while the collector holds a lock on the database
+			for attempt := 0; ; attempt++ {
+				_, err := backup.Step(-1)
+				if err == nil {
+					break
+				}
+
+				var sqliteErr sqlite3.Error
+				busy := errors.As(err, &sqliteErr) &&
+					(sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+				if !busy || attempt >= 50 {
+					backup.Finish()
+					return err
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+
+	if _, err := MigrateTo(db, 0); err != nil {
+		return snapshot, fmt.Errorf("restored %s but failed to migrate it: %w", path, err)
+	}
+
+	return snapshot, nil
+}
+
+// checkBackup verifies that a file is an intact database of this app that
+// this version of the app can use
+func checkBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	source, err := sql.Open("sqlite3", dataSourceName(path, true))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	var integrity string
+	if err := source.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return fmt.Errorf("%s is not a readable database: %w", path, err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("%s is corrupt: %s", path, integrity)
+	}
+
+	rows, err := source.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("%s is not a backup of this app's database: %w", path, err)
+	}
+	defer rows.Close()
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[SchemaVersion]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+
+	for rows.Next() {
+		var version SchemaVersion
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		if !known[version] {
+			return fmt.Errorf("%w (the backup has migration %d applied)", ErrSchemaTooNew, version)
+		}
+	}
+
+	return rows.Err()
+}
+
+// withSQLiteConn calls fn with a driver level connection of a database
+func withSQLiteConn(db *sql.DB, fn func(*sqlite3.SQLiteConn) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected database driver %T", driverConn)
+		}
+		return fn(sqliteConn)
+	})
+}
+
+// BackupIfDue writes a backup to dir when the newest automatic backup there
+// is older than interval, then deletes the oldest automatic backups so at
+// most keep remain. The path of the new backup is returned, or an empty
+// string if none was due.
+func BackupIfDue(db *gorm.DB, dir string, interval time.Duration, keep int, now time.Time) (string, error) {
+	backups, err := automaticBackups(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(backups) > 0 {
+		info, err := os.Stat(backups[len(backups)-1])
+		if err == nil && now.Sub(info.ModTime()) < interval {
+			return "", nil
+		}
+	}
+
+	path := uniquePath(filepath.Join(dir, fmt.Sprintf("%s%s.sqlite", automaticBackupPrefix, now.Format(backupTimeFormat))))
+	if err := Backup(db, path); err != nil {
+		return "", err
+	}
+
+	return path, RotateBackups(dir, keep)
+}
+
+// RotateBackups deletes the oldest automatic backups in dir so at most keep remain
+func RotateBackups(dir string, keep int) error {
+	backups, err := automaticBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("failed to delete old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// automaticBackups returns the paths of the automatic backups in dir, oldest first
+func automaticBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, automaticBackupPrefix) && strings.HasSuffix(name, ".sqlite") {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+
+	// The timestamp in the name sorts chronologically
+	sort.Strings(backups)
+	return backups, nil
+}
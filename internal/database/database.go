@@ -1,10 +1,13 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -16,16 +19,58 @@ var (
 	DB      *gorm.DB
 	once    sync.Once
 	initErr error
+
+	// writeMutex serializes writes made through Write within this process
+	writeMutex sync.Mutex
 )
 
+// busyTimeout is how long a connection waits for another connection, in
+// this or another process, to finish writing before giving up
+const busyTimeout = 5 * time.Second
+
 func Init() error {
+	return initialize(true)
+}
+
+// InitWithoutMigrations opens the database without applying pending
+// migrations, so the schema can be inspected and managed
+func InitWithoutMigrations() error {
+	return initialize(false)
+}
+
+// InitReadOnly opens the database for reading only, so queries never block
+// or get blocked by the app writing measurements. A database that doesn't
+// exist yet or has pending migrations is opened for writing and migrated
+// instead, like Init does.
+func InitReadOnly() error {
+	once.Do(func() {
+		DB, initErr = openReadOnlyDatabase()
+	})
+	return initErr
+}
+
+func initialize(migrate bool) error {
 	once.Do(func() {
-		DB, initErr = SetupDatabase()
+		DB, initErr = openDatabase(migrate)
 	})
 	return initErr
 }
 
+// Write runs fn in a transaction that holds the database's write lock from
+// its start. Writes made through Write never run concurrently within this
+// process, and other processes wait up to the busy timeout for them.
+func Write(fn func(tx *gorm.DB) error) error {
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+
+	return DB.Transaction(fn)
+}
+
 func SetupDatabase() (*gorm.DB, error) {
+	return openDatabase(true)
+}
+
+func openDatabase(migrate bool) (*gorm.DB, error) {
 	dbPath := config.DBPath()
 
 	dir := filepath.Dir(dbPath)
@@ -33,12 +78,17 @@ func SetupDatabase() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(dataSourceName(dbPath, false)), &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	db.Exec("PRAGMA foreign_keys = ON")
+	if !migrate {
+		if err := ensureSchemaMigrationsTable(db); err != nil {
+			return nil, fmt.Errorf("failed to read schema migrations: %w", err)
+		}
+		return db, nil
+	}
 
 	err = Migrate(db)
 	if err != nil {
@@ -48,17 +98,82 @@ func SetupDatabase() (*gorm.DB, error) {
 	return db, nil
 }
 
+func openReadOnlyDatabase() (*gorm.DB, error) {
+	dbPath := config.DBPath()
+
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return openDatabase(true)
+	}
+
+	db, err := gorm.Open(sqlite.Open(dataSourceName(dbPath, true)), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	statuses, err := Status(db)
+	if err != nil {
+		closeDatabase(db)
+		return openDatabase(true)
+	}
+
+	for _, status := range statuses {
+		if !status.Known {
+			closeDatabase(db)
+			return nil, fmt.Errorf("%w (migration %d is applied)", ErrSchemaTooNew, status.Version)
+		}
+		if !status.Applied {
+			closeDatabase(db)
+			return openDatabase(true)
+		}
+	}
+
+	return db, nil
+}
+
+// dataSourceName returns the data source name used to open the database.
+// WAL journaling lets readers work while the app writes, transactions take
+// the write lock when they begin so two writers can't deadlock, and
+// foreign keys are enforced on every pooled connection.
+func dataSourceName(path string, readOnly bool) string {
+	query := url.Values{}
+	query.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	query.Set("_foreign_keys", "on")
+
+	if readOnly {
+		query.Set("mode", "ro")
+	} else {
+		query.Set("_journal_mode", "WAL")
+		query.Set("_synchronous", "NORMAL")
+		query.Set("_txlock", "immediate")
+	}
+
+	return (&url.URL{Scheme: "file", Path: path, RawQuery: query.Encode()}).String()
+}
+
+// closeDatabase closes the connections of a database that won't be used
+func closeDatabase(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
 // GetDatabasePath returns the path to the database file
 func GetDatabasePath() string {
 	return config.DBPath()
 }
 
-// GetSize returns the size of the database file in bytes
+// GetSize returns the size of the database file in bytes, including
+// changes in the write-ahead log that haven't been moved into it yet
 func GetSize() (int64, error) {
 	dbPath := GetDatabasePath()
 	fileInfo, err := os.Stat(dbPath)
 	if err != nil {
 		return 0, err
 	}
-	return fileInfo.Size(), nil
+
+	size := fileInfo.Size()
+	if walInfo, err := os.Stat(dbPath + "-wal"); err == nil {
+		size += walInfo.Size()
+	}
+	return size, nil
 }
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+// openTestDatabase migrates a database in a temporary directory and makes
+// DB refer to it for the rest of the test
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	t.Setenv("GNOME_DESKTOP_AIR_MONITOR_DB_PATH", filepath.Join(t.TempDir(), "test.sqlite"))
+	db, err := openDatabase(true)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+		closeDatabase(db)
+	})
+
+	return db
+}
+
+// createTestDevice stores a device for measurements to belong to
+func createTestDevice(t *testing.T, db *gorm.DB, serialNumber string) models.Device {
+	t.Helper()
+
+	device := models.Device{Name: serialNumber, SerialNumber: serialNumber}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	return device
+}
+
+// countMeasurements returns how many measurements of a device are stored
+func countMeasurements(t *testing.T, db *gorm.DB, deviceID uint) int64 {
+	t.Helper()
+
+	var count int64
+	if err := db.Model(&models.Measurement{}).Where("device_id = ?", deviceID).Count(&count).Error; err != nil {
+		t.Fatalf("failed to count measurements: %v", err)
+	}
+	return count
+}
+
+// TestConcurrentConnections writes and reads through connections opened
+// the way the app and the CLI open them, as if they were separate processes
+func TestConcurrentConnections(t *testing.T) {
+	app := openTestDatabase(t)
+	device := createTestDevice(t, app, "awair-element_1")
+
+	cli, err := openDatabase(false)
+	if err != nil {
+		t.Fatalf("failed to open second connection: %v", err)
+	}
+	defer closeDatabase(cli)
+
+	reader, err := gorm.Open(sqlite.Open(dataSourceName(config.DBPath(), true)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open read-only connection: %v", err)
+	}
+	defer closeDatabase(reader)
+
+	const writers, writes = 4, 100
+	start := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*writers*writes)
+
+	// Several writers on each connection, so transactions overlap within a
+	// connection pool as well as across processes
+	for i := 0; i < 2*writers; i++ {
+		db := []*gorm.DB{app, cli}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				// Read before writing in the same transaction, which
+				// deadlocks if the write lock is only taken on the insert
+				err := db.Transaction(func(tx *gorm.DB) error {
+					var count int64
+					if err := tx.Model(&models.Measurement{}).Count(&count).Error; err != nil {
+						return err
+					}
+					return tx.Create(&models.Measurement{
+						DeviceID:  device.ID,
+						Timestamp: start.Add(time.Duration(i*writes+j) * time.Second),
+						CO2:       float64(count),
+					}).Error
+				})
+				if err != nil {
+					errs <- fmt.Errorf("writer %d: %w", i, err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 2*writers; i++ {
+		db := []*gorm.DB{reader, cli}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				var measurements []models.Measurement
+				if err := db.Where("device_id = ?", device.ID).Order("timestamp DESC").Limit(10).Find(&measurements).Error; err != nil {
+					errs <- fmt.Errorf("reader %d: %w", i, err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if count := countMeasurements(t, app, device.ID); count != 2*writers*writes {
+		t.Errorf("stored %d measurements, want %d", count, 2*writers*writes)
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ForeignKeyViolation is a row that references a missing parent row
+type ForeignKeyViolation struct {
+	Table        string
+	RowID        int64
+	Parent       string
+	ForeignKeyID int
+}
+
+// CheckResult lists the problems found by Check
+type CheckResult struct {
+	IntegrityErrors      []string
+	ForeignKeyViolations []ForeignKeyViolation
+}
+
+// OK reports whether no problems were found
+func (result *CheckResult) OK() bool {
+	return len(result.IntegrityErrors) == 0 && len(result.ForeignKeyViolations) == 0
+}
+
+// Check runs SQLite's integrity and foreign key checks
+func Check(db *gorm.DB) (*CheckResult, error) {
+	result := &CheckResult{}
+
+	var messages []string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to check integrity: %w", err)
+	}
+	for _, message := range messages {
+		if message != "ok" {
+			result.IntegrityErrors = append(result.IntegrityErrors, message)
+		}
+	}
+
+	rows, err := db.Raw("PRAGMA foreign_key_check").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var violation ForeignKeyViolation
+		var rowID sql.NullInt64 // NULL for tables without a rowid
+		if err := rows.Scan(&violation.Table, &rowID, &violation.Parent, &violation.ForeignKeyID); err != nil {
+			return nil, fmt.Errorf("failed to check foreign keys: %w", err)
+		}
+		violation.RowID = rowID.Int64
+		result.ForeignKeyViolations = append(result.ForeignKeyViolations, violation)
+	}
+
+	return result, rows.Err()
+}
+
+// duplicateMeasurementIDs selects the IDs of measurements that repeat the
+// device and timestamp of another one. The first live measurement is kept.
+const duplicateMeasurementIDs = `
+SELECT id FROM (
+    SELECT id, ROW_NUMBER() OVER (
+        PARTITION BY device_id, timestamp
+        ORDER BY deleted_at IS NOT NULL, id
+    ) AS position
+    FROM measurements
+) WHERE position > 1`
+
+// CountDuplicateMeasurements returns how many measurements repeat the device
+// and timestamp of another measurement
+func CountDuplicateMeasurements(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM (" + duplicateMeasurementIDs + ")").Scan(&count).Error
+	return count, err
+}
+
+// DeleteDuplicateMeasurements deletes measurements that repeat the device
+// and timestamp of another measurement, and returns how many were deleted
+func DeleteDuplicateMeasurements(db *gorm.DB) (int64, error) {
+	result := db.Exec("DELETE FROM measurements WHERE id IN (" + duplicateMeasurementIDs + ")")
+	return result.RowsAffected, result.Error
+}
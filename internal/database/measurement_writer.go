@@ -0,0 +1,231 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+// maxPendingMeasurements caps how many measurements are kept while writing
+// fails, the oldest ones are dropped beyond it
+const maxPendingMeasurements = 10000
+
+// MeasurementWriter buffers measurements and writes them in a single
+// transaction per interval, together with when each device was last seen
+type MeasurementWriter struct {
+	interval time.Duration
+	onWrite  func([]models.Measurement)
+	onError  func(error)
+
+	mutex    sync.Mutex
+	pending  []models.Measurement
+	lastSeen map[uint]time.Time
+
+	// Held while a batch is written, so the database isn't replaced under it
+	flushMutex sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMeasurementWriter creates a writer that flushes every interval. onWrite
+// is called with the measurements stored by every successful write, and onError
+// with the error of every failed one, both from the writer's goroutine.
+func NewMeasurementWriter(interval time.Duration, onWrite func([]models.Measurement), onError func(error)) *MeasurementWriter {
+	return &MeasurementWriter{
+		interval: interval,
+		onWrite:  onWrite,
+		onError:  onError,
+		lastSeen: make(map[uint]time.Time),
+	}
+}
+
+// Add buffers a measurement until the next write. Its timestamp is stored in
+// UTC, as the same time in another zone would be stored as another timestamp.
+func (w *MeasurementWriter) Add(measurement models.Measurement) {
+	measurement.Timestamp = measurement.Timestamp.UTC()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = append(w.pending, measurement)
+	if len(w.pending) > maxPendingMeasurements {
+		w.pending = w.pending[len(w.pending)-maxPendingMeasurements:]
+	}
+	w.lastSeen[measurement.DeviceID] = time.Now()
+}
+
+// Start writes the buffered measurements every interval until Close is called
+func (w *MeasurementWriter) Start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.flushAndReport()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic writes and writes what is still buffered
+func (w *MeasurementWriter) Close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+		w.stop = nil
+	}
+
+	_, err := w.Flush()
+	return err
+}
+
+// Flush writes the buffered measurements now and returns the ones that were
+// stored, leaving out those that already were. Measurements that fail to be
+// written stay buffered for the next attempt.
+func (w *MeasurementWriter) Flush() ([]models.Measurement, error) {
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+
+	w.mutex.Lock()
+	measurements := w.pending
+	lastSeen := w.lastSeen
+	w.pending = nil
+	w.lastSeen = make(map[uint]time.Time)
+	w.mutex.Unlock()
+
+	if len(measurements) == 0 {
+		return nil, nil
+	}
+
+	var written []models.Measurement
+	err := Write(func(tx *gorm.DB) error {
+		// Drop measurements of devices that no longer exist, for example
+		// after a restore, instead of failing every following write
+		deviceIDs := make([]uint, 0, len(lastSeen))
+		for deviceID := range lastSeen {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+
+		var existing []uint
+		if err := tx.Model(&models.Device{}).Where("id IN ?", deviceIDs).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+
+		exists := make(map[uint]bool, len(existing))
+		for _, deviceID := range existing {
+			exists[deviceID] = true
+		}
+
+		kept := measurements[:0]
+		for _, measurement := range measurements {
+			if exists[measurement.DeviceID] {
+				kept = append(kept, measurement)
+			}
+		}
+		measurements = kept
+
+		if len(measurements) == 0 {
+			return nil
+		}
+
+		// Devices report the same measurement until they take a new one,
+		// so measurements that are already stored are skipped, and only
+		// the ones that were inserted are reported as written
+		inserted := make([]models.Measurement, 0, len(measurements))
+		for i := range measurements {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&measurements[i])
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				inserted = append(inserted, measurements[i])
+			}
+		}
+		written = inserted
+
+		for _, deviceID := range existing {
+			err := tx.Model(&models.Device{}).Where("id = ?", deviceID).Update("last_seen", lastSeen[deviceID]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		w.requeue(measurements, lastSeen)
+		return nil, err
+	}
+
+	return written, nil
+}
+
+// ReplaceDatabase runs replace, which replaces the contents of the database,
+// while no batch is being written. If replace reports that it replaced them,
+// the buffered measurements are dropped, as the devices they belong to can
+// have other IDs in the new database.
+func (w *MeasurementWriter) ReplaceDatabase(replace func() bool) {
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+
+	if !replace() {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = nil
+	w.lastSeen = make(map[uint]time.Time)
+}
+
+// flushAndReport flushes and passes the outcome to the callbacks
+func (w *MeasurementWriter) flushAndReport() {
+	measurements, err := w.Flush()
+	if err != nil {
+		if w.onError != nil {
+			w.onError(err)
+		}
+		return
+	}
+
+	if len(measurements) > 0 && w.onWrite != nil {
+		w.onWrite(measurements)
+	}
+}
+
+// requeue puts measurements that failed to be written back in front of the
+// ones added in the meantime
+func (w *MeasurementWriter) requeue(measurements []models.Measurement, lastSeen map[uint]time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// A failed insert can leave IDs assigned to the measurements
+	for i := range measurements {
+		measurements[i].ID = 0
+	}
+
+	w.pending = append(measurements, w.pending...)
+	if len(w.pending) > maxPendingMeasurements {
+		w.pending = w.pending[len(w.pending)-maxPendingMeasurements:]
+	}
+
+	for deviceID, seen := range lastSeen {
+		if newer, ok := w.lastSeen[deviceID]; !ok || newer.Before(seen) {
+			w.lastSeen[deviceID] = seen
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+func TestFlushSkipsStoredMeasurements(t *testing.T) {
+	db := openTestDatabase(t)
+	device := createTestDevice(t, db, "awair-element_1")
+
+	start := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+	batch := func() []models.Measurement {
+		measurements := make([]models.Measurement, 3)
+		for i := range measurements {
+			measurements[i] = models.Measurement{
+				DeviceID:  device.ID,
+				Timestamp: start.Add(time.Duration(i) * 10 * time.Second),
+				CO2:       float64(600 + i),
+			}
+		}
+		return measurements
+	}
+
+	writer := NewMeasurementWriter(time.Hour, nil, nil)
+	for _, measurement := range batch() {
+		writer.Add(measurement)
+	}
+	written, err := writer.Flush()
+	if err != nil {
+		t.Fatalf("first flush failed: %v", err)
+	}
+	if len(written) != 3 {
+		t.Errorf("first flush returned %d measurements, want 3", len(written))
+	}
+
+	// The same batch again, and one new measurement
+	for _, measurement := range batch() {
+		writer.Add(measurement)
+	}
+	writer.Add(models.Measurement{DeviceID: device.ID, Timestamp: start.Add(time.Minute), CO2: 700})
+	written, err = writer.Flush()
+	if err != nil {
+		t.Fatalf("second flush failed: %v", err)
+	}
+	if len(written) != 1 || written[0].CO2 != 700 {
+		t.Errorf("second flush returned %+v, want only the new measurement", written)
+	}
+
+	// Only duplicates
+	for _, measurement := range batch() {
+		writer.Add(measurement)
+	}
+	written, err = writer.Flush()
+	if err != nil {
+		t.Fatalf("third flush failed: %v", err)
+	}
+	if len(written) != 0 {
+		t.Errorf("third flush returned %d measurements, want none", len(written))
+	}
+
+	if count := countMeasurements(t, db, device.ID); count != 4 {
+		t.Errorf("stored %d measurements, want 4", count)
+	}
+}
+
+func TestFlushStoresTimesOfAnyZoneOnce(t *testing.T) {
+	db := openTestDatabase(t)
+	device := createTestDevice(t, db, "awair-element_2")
+
+	instant := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+	zones := []*time.Location{
+		time.FixedZone("CEST", 2*60*60),
+		time.FixedZone("EDT", -4*60*60),
+	}
+
+	writer := NewMeasurementWriter(time.Hour, nil, nil)
+	total := 0
+	for _, zone := range zones {
+		writer.Add(models.Measurement{DeviceID: device.ID, Timestamp: instant.In(zone), CO2: 800})
+		written, err := writer.Flush()
+		if err != nil {
+			t.Fatalf("flush in %s failed: %v", zone, err)
+		}
+		total += len(written)
+	}
+
+	if total != 1 {
+		t.Errorf("flushes returned %d measurements, want 1", total)
+	}
+	if count := countMeasurements(t, db, device.ID); count != 1 {
+		t.Errorf("stored %d measurements, want 1", count)
+	}
+
+	var stored models.Measurement
+	if err := db.Where("device_id = ? AND timestamp = ?", device.ID, instant).First(&stored).Error; err != nil {
+		t.Errorf("measurement isn't found by its time in UTC: %v", err)
+	}
+}
+
+func TestReplaceDatabaseDropsBufferedMeasurements(t *testing.T) {
+	db := openTestDatabase(t)
+	device := createTestDevice(t, db, "awair-element_3")
+	at := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+
+	writer := NewMeasurementWriter(time.Hour, nil, nil)
+
+	// Nothing is dropped when the database wasn't replaced
+	writer.Add(models.Measurement{DeviceID: device.ID, Timestamp: at, CO2: 600})
+	writer.ReplaceDatabase(func() bool { return false })
+	if written, err := writer.Flush(); err != nil || len(written) != 1 {
+		t.Fatalf("flush after a failed replace returned %d measurements, %v, want 1", len(written), err)
+	}
+
+	writer.Add(models.Measurement{DeviceID: device.ID, Timestamp: at.Add(time.Minute), CO2: 700})
+	writer.ReplaceDatabase(func() bool { return true })
+	if written, err := writer.Flush(); err != nil || len(written) != 0 {
+		t.Errorf("flush after a replace returned %d measurements, %v, want none", len(written), err)
+	}
+
+	if count := countMeasurements(t, db, device.ID); count != 1 {
+		t.Errorf("stored %d measurements, want 1", count)
+	}
+}
@@ -1,13 +1,22 @@
 package database
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+
+	config "github.com/monorkin/gnome-desktop-air-monitor/internal/config"
 )
 
 //go:embed migrations/*/up.sql migrations/*/down.sql
@@ -16,9 +25,16 @@ var migrationsFS embed.FS
 type SchemaVersion uint64
 
 type SchemaMigration struct {
-	Version SchemaVersion `gorm:"primaryKey"`
+	Version   SchemaVersion `gorm:"primaryKey"`
+	Name      string
+	Checksum  string     // SHA-256 of up.sql, empty for migrations applied before it was recorded
+	AppliedAt *time.Time // nil for migrations applied before it was recorded
 }
 
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of the app than this one
+var ErrSchemaTooNew = errors.New("the database schema is newer than this version of the app supports, please update the app")
+
 func CurrentSchemaVersion(db *gorm.DB) SchemaVersion {
 	return CurrentSchemaMigration(db).Version
 }
@@ -41,14 +57,10 @@ type Migration struct {
 	Dir     fs.DirEntry
 }
 
+// exec runs the SQL of a migration. The caller owns the transaction and
+// rolls it back when an error is returned.
 func (migration *Migration) exec(db *gorm.DB, sql string) error {
-	result := db.Exec(sql)
-	if result.Error != nil {
-		db.Rollback()
-		return result.Error
-	}
-
-	return nil
+	return db.Exec(sql).Error
 }
 
 func (migration *Migration) Up(db *gorm.DB) error {
@@ -97,24 +109,178 @@ func (migration *Migration) DirName() string {
 	return migration.Dir.Name()
 }
 
+// Checksum returns the SHA-256 of the migration's up.sql
+func (migration *Migration) Checksum() (string, error) {
+	sql, err := migration.UpSQL()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Name returns the name of the migration without its version prefix
+func (migration *Migration) Name() string {
+	name := strings.TrimLeft(migration.DirName(), "0123456789")
+	return strings.TrimPrefix(name, "_")
+}
+
+// MigrationStatus describes a migration that is either embedded in this
+// build, recorded as applied in the database, or both
+type MigrationStatus struct {
+	Version   SchemaVersion
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Known     bool // false for applied migrations this build doesn't include
+}
+
+func ensureSchemaMigrationsTable(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
 func Migrate(db *gorm.DB) error {
-	db.AutoMigrate(&SchemaMigration{})
+	_, err := MigrateTo(db, 0)
+	return err
+}
+
+// MigrateTo verifies the applied migrations and applies the pending ones up
+// to and including the target version, or all of them if target is 0.
+// Databases with applied migrations are snapshotted first, the path of the
+// snapshot is returned.
+func MigrateTo(db *gorm.DB, target SchemaVersion) (string, error) {
+	if err := ensureSchemaMigrationsTable(db); err != nil {
+		return "", err
+	}
 
-	currentVersion := CurrentSchemaVersion(db)
-	migrations, err := MigrationsNewerThan(currentVersion)
+	if err := VerifyMigrations(db); err != nil {
+		return "", err
+	}
+
+	migrations, err := PendingMigrations(db, target)
+	if err != nil || len(migrations) == 0 {
+		return "", err
+	}
+
+	snapshot, err := snapshotBeforeMigrating(db)
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot, ApplyMigrations(db, migrations)
+}
+
+// RollBack verifies the applied migrations, snapshots the database and
+// reverts the last steps migrations. The path of the snapshot is returned.
+func RollBack(db *gorm.DB, steps int) (string, error) {
+	if err := VerifyMigrations(db); err != nil {
+		return "", err
+	}
+
+	migrations, err := MigrationsToRollBack(db, steps)
+	if err != nil || len(migrations) == 0 {
+		return "", err
+	}
+
+	snapshot, err := snapshotBeforeMigrating(db)
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot, RevertMigrations(db, migrations)
+}
+
+// VerifyMigrations checks that no applied migration is unknown to this
+// build and that the applied migrations weren't changed since. Checksums
+// are recorded for migrations applied before checksums were.
+func VerifyMigrations(db *gorm.DB) error {
+	migrations, err := Migrations()
 	if err != nil {
 		return err
 	}
 
+	byVersion := make(map[SchemaVersion]Migration, len(migrations))
 	for _, migration := range migrations {
-		err := db.Transaction(func(tx *gorm.DB) error {
-			schemaMigration := SchemaMigration{
-				Version: migration.Version,
+		byVersion[migration.Version] = migration
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, schemaMigration := range applied {
+		migration, ok := byVersion[schemaMigration.Version]
+		if !ok {
+			return fmt.Errorf("%w (migration %d is applied)", ErrSchemaTooNew, schemaMigration.Version)
+		}
+
+		checksum, err := migration.Checksum()
+		if err != nil {
+			return err
+		}
+
+		if schemaMigration.Checksum == "" {
+			err := db.Model(&SchemaMigration{}).
+				Where("version = ?", schemaMigration.Version).
+				Updates(map[string]interface{}{"checksum": checksum, "name": migration.Name()}).
+				Error
+			if err != nil {
+				return fmt.Errorf("failed to record checksum of migration %d: %w", migration.Version, err)
 			}
+			continue
+		}
+
+		if schemaMigration.Checksum != checksum {
+			return fmt.Errorf("migration %s was changed after it was applied (checksum %s, applied %s)",
+				migration.DirName(), checksum, schemaMigration.Checksum)
+		}
+	}
 
-			tx.Create(&schemaMigration)
+	return nil
+}
+
+// snapshotBeforeMigrating backs up a database that has migrations applied,
+// so data can be recovered when a migration goes wrong
+func snapshotBeforeMigrating(db *gorm.DB) (string, error) {
+	current := CurrentSchemaVersion(db)
+	if current == 0 {
+		return "", nil // Nothing to lose yet
+	}
 
-			return migration.Up(tx)
+	path := uniquePath(filepath.Join(config.BackupDir(),
+		fmt.Sprintf("pre-migration-%d-%s.sqlite", current, time.Now().Format("20060102-150405"))))
+	if err := Backup(db, path); err != nil {
+		return "", fmt.Errorf("refusing to migrate without a snapshot: %w", err)
+	}
+
+	return path, nil
+}
+
+// ApplyMigrations applies migrations in the given order, each in its own
+// transaction together with its schema_migrations record
+func ApplyMigrations(db *gorm.DB, migrations []Migration) error {
+	for _, migration := range migrations {
+		checksum, err := migration.Checksum()
+		if err != nil {
+			return err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			now := time.Now().UTC()
+			schemaMigration := SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name(),
+				Checksum:  checksum,
+				AppliedAt: &now,
+			}
+
+			return tx.Create(&schemaMigration).Error
 		})
 		if err != nil {
 			return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
@@ -124,6 +290,174 @@ func Migrate(db *gorm.DB) error {
 	return nil
 }
 
+// RevertMigrations reverts migrations in the given order, each in its own
+// transaction together with the removal of its schema_migrations record
+func RevertMigrations(db *gorm.DB, migrations []Migration) error {
+	for _, migration := range migrations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to roll back migration %d: %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// AppliedMigrations returns the migrations recorded in the database ordered by version
+func AppliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	var applied []SchemaMigration
+	err := db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// Migrations returns all migrations included in this build ordered by version
+func Migrations() ([]Migration, error) {
+	return MigrationsNewerThan(0)
+}
+
+// LatestSchemaVersion returns the version of the newest migration included in this build
+func LatestSchemaVersion() (SchemaVersion, error) {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Status lists all migrations included in this build and all migrations
+// recorded in the database ordered by version
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[SchemaVersion]*MigrationStatus)
+	for _, migration := range migrations {
+		statuses[migration.Version] = &MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name(),
+			Known:   true,
+		}
+	}
+
+	for _, schemaMigration := range applied {
+		status, ok := statuses[schemaMigration.Version]
+		if !ok {
+			status = &MigrationStatus{Version: schemaMigration.Version, Name: schemaMigration.Name}
+			statuses[schemaMigration.Version] = status
+		}
+		status.Applied = true
+		status.AppliedAt = schemaMigration.AppliedAt
+	}
+
+	result := make([]MigrationStatus, 0, len(statuses))
+	for _, status := range statuses {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// PendingMigrations returns the migrations that aren't applied yet up to
+// and including the target version, or all of them if target is 0
+func PendingMigrations(db *gorm.DB, target SchemaVersion) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if target != 0 {
+		found := false
+		for _, migration := range migrations {
+			found = found || migration.Version == target
+		}
+		if !found {
+			return nil, fmt.Errorf("there is no migration with version %d", target)
+		}
+
+		if current := CurrentSchemaVersion(db); target < current {
+			return nil, fmt.Errorf("the database is already at version %d, roll back to go to version %d", current, target)
+		}
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if target != 0 && migration.Version > target {
+			break
+		}
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// MigrationsToRollBack returns the last applied migrations, newest first,
+// in the order they have to be reverted
+func MigrationsToRollBack(db *gorm.DB, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[SchemaVersion]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var rollback []Migration
+	for i := len(applied) - 1; i >= 0 && len(rollback) < steps; i-- {
+		migration, ok := byVersion[applied[i].Version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d was applied by a newer version of the app and can't be rolled back", applied[i].Version)
+		}
+		rollback = append(rollback, migration)
+	}
+
+	return rollback, nil
+}
+
+func appliedVersions(db *gorm.DB) (map[SchemaVersion]bool, error) {
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[SchemaVersion]bool, len(applied))
+	for _, schemaMigration := range applied {
+		versions[schemaMigration.Version] = true
+	}
+	return versions, nil
+}
+
 func MigrationsNewerThan(minVersion SchemaVersion) ([]Migration, error) {
 	migrationVersionRegex := regexp.MustCompile(`^(\d+)`)
 
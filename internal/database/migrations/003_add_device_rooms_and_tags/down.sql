@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS device_tags;
+DROP INDEX IF EXISTS idx_devices_room;
+ALTER TABLE devices DROP COLUMN room;
@@ -0,0 +1,13 @@
+ALTER TABLE devices ADD COLUMN room TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_devices_room ON devices(room);
+CREATE TABLE IF NOT EXISTS device_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    deleted_at DATETIME,
+    device_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_device_tags_deleted_at ON device_tags(deleted_at);
+CREATE UNIQUE INDEX idx_device_tags_device_id_name ON device_tags(device_id, name);
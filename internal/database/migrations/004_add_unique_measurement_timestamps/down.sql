@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_measurements_device_id_timestamp;
@@ -0,0 +1,16 @@
+-- Timestamps are stored as text with their zone offset, so the same time
+-- stored with different offsets only compares equal once it's in UTC.
+-- Fractions of a second are kept between the seconds and the offset.
+UPDATE measurements
+SET timestamp = datetime(timestamp) || substr(timestamp, 20, length(timestamp) - 25) || '+00:00'
+WHERE timestamp NOT LIKE '%+00:00' AND datetime(timestamp) IS NOT NULL;
+DELETE FROM measurements WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (
+            PARTITION BY device_id, timestamp
+            ORDER BY deleted_at IS NOT NULL, id
+        ) AS position
+        FROM measurements
+    ) WHERE position > 1
+);
+CREATE UNIQUE INDEX idx_measurements_device_id_timestamp ON measurements(device_id, timestamp);
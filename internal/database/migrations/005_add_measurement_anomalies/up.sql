@@ -0,0 +1 @@
+ALTER TABLE measurements ADD COLUMN anomalies INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE devices ADD COLUMN room_volume REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE devices DROP COLUMN surface_temperature;
+ALTER TABLE measurements DROP COLUMN dew_point;
@@ -0,0 +1,2 @@
+ALTER TABLE measurements ADD COLUMN dew_point REAL;
+ALTER TABLE devices ADD COLUMN surface_temperature REAL;
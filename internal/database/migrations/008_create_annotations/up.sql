@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS annotations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    deleted_at DATETIME,
+    device_id INTEGER NOT NULL,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME,
+    text TEXT NOT NULL,
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_annotations_deleted_at ON annotations(deleted_at);
+CREATE INDEX idx_annotations_device_id_starts_at ON annotations(device_id, starts_at);
@@ -0,0 +1,34 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// Metrics lists the metrics that can be forecast with the bounds of their values
+var Metrics = map[string]struct{ Min, Max float64 }{
+	"co2":   {airquality.OutdoorCO2, math.Inf(1)}, // Ventilation can't bring CO₂ below outdoor air
+	"score": {0, 100},
+}
+
+// ForDevice forecasts a metric of a device from its measurements stored in
+// the Window before a point in time. The forecast isn't valid if the device
+// stopped reporting, as the room may have changed since.
+func ForDevice(db *gorm.DB, deviceID uint, metric string, now time.Time) (Forecast, error) {
+	bounds, ok := Metrics[metric]
+	if !ok {
+		return Forecast{}, fmt.Errorf("metric %q can't be forecast", metric)
+	}
+
+	points, err := models.MeasurementHistory(db, deviceID, metric, now.Add(-Window), now, Resolution)
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	return Compute(points, bounds.Min, bounds.Max).Current(now), nil
+}
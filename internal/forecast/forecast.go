@@ -0,0 +1,144 @@
+// Package forecast projects where a metric is heading from its most recent
+// values, like when CO₂ will reach the level at which a room should be
+// ventilated.
+//
+// The values are smoothed with Holt's linear exponential smoothing, which
+// follows both the level and the trend of a metric, so a single noisy
+// reading doesn't throw the projection off while a steady rise is picked
+// up within minutes. The trend is extrapolated linearly, which holds for
+// the short horizon projected but not for hours ahead.
+package forecast
+
+import (
+	"math"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+const (
+	// Window is how far back values are used to forecast
+	Window = 30 * time.Minute
+
+	// Resolution is the size of the buckets values are averaged into
+	// before smoothing
+	Resolution = time.Minute
+
+	// Horizon is how far ahead values are projected
+	Horizon = time.Hour
+
+	// Step is the time between projected values
+	Step = 5 * time.Minute
+
+	// MinPoints is how many buckets in a row are needed to forecast
+	MinPoints = 5
+
+	// levelSmoothing weighs the latest value against the projected level,
+	// higher follows changes faster but lets through more noise
+	levelSmoothing = 0.5
+
+	// trendSmoothing weighs the latest change against the trend so far
+	trendSmoothing = 0.3
+
+	// maxBucketGap is the longest time between buckets that still counts as continuous
+	maxBucketGap = 3 * Resolution
+)
+
+// Forecast is the projection of a metric from its recent values
+type Forecast struct {
+	From   time.Time             // Time of the last value the projection starts from
+	Level  float64               // Smoothed value at From
+	Trend  float64               // Change per hour
+	Min    float64               // Lowest value the metric can take
+	Max    float64               // Highest value the metric can take
+	Points []models.HistoryPoint // Projected values from From to From+Horizon, every Step
+}
+
+// Valid reports whether there were enough recent values to forecast
+func (forecast Forecast) Valid() bool {
+	return len(forecast.Points) > 0
+}
+
+// Current returns the forecast, or an invalid one if the values it starts
+// from are too old to still describe the room at a point in time
+func (forecast Forecast) Current(now time.Time) Forecast {
+	if forecast.Valid() && now.Sub(forecast.From) > maxBucketGap {
+		return Forecast{Min: forecast.Min, Max: forecast.Max}
+	}
+	return forecast
+}
+
+// At returns the projected value at a point in time
+func (forecast Forecast) At(at time.Time) float64 {
+	value := forecast.Level + forecast.Trend*at.Sub(forecast.From).Hours()
+	return math.Min(math.Max(value, forecast.Min), forecast.Max)
+}
+
+// Crossing returns when the projection reaches a threshold from below
+// within the horizon, From if the level already reached it, and false if
+// it's not expected to
+func (forecast Forecast) Crossing(threshold float64) (time.Time, bool) {
+	if !forecast.Valid() {
+		return time.Time{}, false
+	}
+	if forecast.Level >= threshold {
+		return forecast.From, true
+	}
+	if forecast.Trend <= 0 {
+		return time.Time{}, false
+	}
+
+	after := time.Duration((threshold - forecast.Level) / forecast.Trend * float64(time.Hour))
+	if after > Horizon {
+		return time.Time{}, false
+	}
+	return forecast.From.Add(after), true
+}
+
+// Until returns how long from now until the projection reaches a threshold,
+// zero if it already did, and false if it's not expected to within the horizon
+func (forecast Forecast) Until(threshold float64, now time.Time) (time.Duration, bool) {
+	crossing, ok := forecast.Crossing(threshold)
+	if !ok {
+		return 0, false
+	}
+	return max(crossing.Sub(now), 0), true
+}
+
+// Compute forecasts a metric bound between lower and upper from its values
+// averaged into buckets of Resolution, oldest first. Only the buckets after
+// the last gap are used, and the forecast isn't valid if there are fewer
+// than MinPoints of them.
+func Compute(points []models.HistoryPoint, lower, upper float64) Forecast {
+	forecast := Forecast{Min: lower, Max: upper}
+
+	start := len(points) - 1
+	for start > 0 && points[start].Timestamp.Sub(points[start-1].Timestamp) <= maxBucketGap {
+		start--
+	}
+	if start < 0 || len(points)-start < MinPoints {
+		return forecast
+	}
+	points = points[start:]
+
+	level := points[0].Value
+	trend := (points[1].Value - points[0].Value) / points[1].Timestamp.Sub(points[0].Timestamp).Hours()
+
+	for i := 1; i < len(points); i++ {
+		elapsed := points[i].Timestamp.Sub(points[i-1].Timestamp).Hours()
+		previous := level
+		level = levelSmoothing*points[i].Value + (1-levelSmoothing)*(level+trend*elapsed)
+		trend = trendSmoothing*(level-previous)/elapsed + (1-trendSmoothing)*trend
+	}
+
+	forecast.From = points[len(points)-1].Timestamp
+	forecast.Level = level
+	forecast.Trend = trend
+
+	for offset := time.Duration(0); offset <= Horizon; offset += Step {
+		at := forecast.From.Add(offset)
+		forecast.Points = append(forecast.Points, models.HistoryPoint{Timestamp: at, Value: forecast.At(at)})
+	}
+
+	return forecast
+}
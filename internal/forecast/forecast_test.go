@@ -0,0 +1,240 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+var traceStart = time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+
+// trace turns values recorded once a minute into history points. A gap
+// of the given length is left before the value at index gapAt, if any.
+func trace(values []float64, gapAt int, gap time.Duration) []models.HistoryPoint {
+	points := make([]models.HistoryPoint, len(values))
+	at := traceStart
+	for i, value := range values {
+		if i > 0 {
+			at = at.Add(Resolution)
+			if i == gapAt {
+				at = at.Add(gap)
+			}
+		}
+		points[i] = models.HistoryPoint{Timestamp: at, Value: value}
+	}
+	return points
+}
+
+// linear returns count values starting at start and changing by step each minute
+func linear(start, step float64, count int) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = start + step*float64(i)
+	}
+	return values
+}
+
+func TestComputeCO2Crossing(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []float64
+		gapAt     int
+		gap       time.Duration
+		threshold float64
+		valid     bool
+		crosses   bool
+		after     time.Duration // Time from the last value to the crossing
+		tolerance time.Duration
+	}{
+		{
+			name:      "steady rise crosses the threshold",
+			values:    linear(800, 10, 30), // 1090 ppm at the last value
+			threshold: 1200,
+			valid:     true,
+			crosses:   true,
+			after:     11 * time.Minute,
+			tolerance: time.Second,
+		},
+		{
+			name: "noisy rise crosses the threshold",
+			values: []float64{
+				802, 815, 818, 834, 841, 855, 861, 878,
+				884, 897, 905, 919, 926, 938, 947, 960,
+			},
+			threshold: 1000,
+			valid:     true,
+			crosses:   true,
+			after:     4 * time.Minute,
+			tolerance: 2 * time.Minute,
+		},
+		{
+			name:      "rise too slow to cross within the horizon",
+			values:    linear(600, 1, 30),
+			threshold: 1000,
+			valid:     true,
+			crosses:   false,
+		},
+		{
+			name: "flat trace doesn't warn",
+			values: []float64{
+				652, 649, 651, 650, 648, 652, 650, 651, 649, 650,
+				651, 648, 650, 652, 649, 650, 651, 650, 649, 650,
+			},
+			threshold: 1000,
+			valid:     true,
+			crosses:   false,
+		},
+		{
+			name:      "falling trace doesn't warn",
+			values:    linear(1100, -15, 20), // Below the threshold after a window was opened
+			threshold: 1000,
+			valid:     true,
+			crosses:   false,
+		},
+		{
+			name:      "already above the threshold",
+			values:    linear(1100, 5, 10),
+			threshold: 1000,
+			valid:     true,
+			crosses:   true,
+			after:     0, // Right away
+		},
+		{
+			name:      "too few points",
+			values:    linear(800, 50, MinPoints-1),
+			threshold: 1000,
+			valid:     false,
+		},
+		{
+			name:      "too few points after a gap",
+			values:    linear(800, 20, 20),
+			gapAt:     17,
+			gap:       10 * time.Minute,
+			threshold: 1000,
+			valid:     false,
+		},
+		{
+			name: "only the points after a gap are used",
+			// Falling fast before the device went offline, rising after
+			values:    append(linear(1500, -40, 10), linear(700, 10, 10)...),
+			gapAt:     10,
+			gap:       15 * time.Minute,
+			threshold: 1000,
+			valid:     true,
+			crosses:   true,
+			after:     21 * time.Minute,
+			tolerance: time.Second,
+		},
+		{
+			name: "short gap counts as continuous",
+			// Still rising 10 ppm a minute over the missing minutes
+			values:    append(linear(800, 10, 15), linear(970, 10, 15)...), // 1110 ppm at the last value
+			gapAt:     15,
+			gap:       maxBucketGap - Resolution,
+			threshold: 1200,
+			valid:     true,
+			crosses:   true,
+			after:     9 * time.Minute,
+			tolerance: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := trace(tt.values, tt.gapAt, tt.gap)
+			forecast := Compute(points, Metrics["co2"].Min, Metrics["co2"].Max)
+
+			if forecast.Valid() != tt.valid {
+				t.Fatalf("Valid() = %v, want %v", forecast.Valid(), tt.valid)
+			}
+			if !tt.valid {
+				return
+			}
+
+			if last := points[len(points)-1].Timestamp; !forecast.From.Equal(last) {
+				t.Errorf("From = %v, want the last value at %v", forecast.From, last)
+			}
+
+			crossing, crosses := forecast.Crossing(tt.threshold)
+			if crosses != tt.crosses {
+				t.Fatalf("Crossing(%v) crosses = %v, want %v (level %.1f, trend %.1f/h)",
+					tt.threshold, crosses, tt.crosses, forecast.Level, forecast.Trend)
+			}
+			if !crosses {
+				return
+			}
+
+			after := crossing.Sub(forecast.From)
+			if diff := after - tt.after; diff < -tt.tolerance || diff > tt.tolerance {
+				t.Errorf("crosses %v after the last value, want %v ± %v", after, tt.after, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestComputeScoreStaysInBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []float64
+		rising  bool
+		boundAt float64 // Value the projection ends at, clamped to the bounds of the score
+	}{
+		{
+			name:    "falling score stops at 0",
+			values:  linear(80, -3, 20), // 23 at the last value
+			rising:  false,
+			boundAt: 0,
+		},
+		{
+			name:    "rising score stops at 100",
+			values:  linear(40, 3, 20), // 97 at the last value
+			rising:  true,
+			boundAt: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecast := Compute(trace(tt.values, 0, 0), Metrics["score"].Min, Metrics["score"].Max)
+			if !forecast.Valid() {
+				t.Fatal("forecast isn't valid")
+			}
+
+			if rising := forecast.Trend > 0; rising != tt.rising {
+				t.Errorf("Trend = %.1f/h, want rising %v", forecast.Trend, tt.rising)
+			}
+
+			for _, point := range forecast.Points {
+				if point.Value < 0 || point.Value > 100 {
+					t.Errorf("projected %.1f at %v, outside the bounds of the score", point.Value, point.Timestamp)
+				}
+			}
+
+			last := forecast.Points[len(forecast.Points)-1]
+			if !last.Timestamp.Equal(forecast.From.Add(Horizon)) {
+				t.Errorf("projection ends at %v, want %v", last.Timestamp, forecast.From.Add(Horizon))
+			}
+			if math.Abs(last.Value-tt.boundAt) > 1e-9 {
+				t.Errorf("projection ends at %.1f, want %.1f", last.Value, tt.boundAt)
+			}
+		})
+	}
+}
+
+func TestCurrentDropsStaleForecasts(t *testing.T) {
+	forecast := Compute(trace(linear(800, 10, 30), 0, 0), Metrics["co2"].Min, Metrics["co2"].Max)
+
+	if !forecast.Current(forecast.From.Add(maxBucketGap)).Valid() {
+		t.Error("forecast is invalid right after its last value")
+	}
+
+	stale := forecast.Current(forecast.From.Add(maxBucketGap + time.Second))
+	if stale.Valid() {
+		t.Error("forecast is still valid after the device stopped reporting")
+	}
+	if _, ok := stale.Crossing(0); ok {
+		t.Error("stale forecast reports a crossing")
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"sync"
 
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/config"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/config/gsettings"
 	"github.com/monorkin/gnome-desktop-air-monitor/internal/database"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
 )
 
 var (
@@ -20,6 +23,22 @@ var (
 
 // Initialize sets up global instances exactly once
 func Initialize(verbose bool) {
+	initialize(verbose, database.Init)
+}
+
+// InitializeWithoutMigrations sets up global instances like Initialize,
+// but leaves pending database migrations to be managed explicitly
+func InitializeWithoutMigrations(verbose bool) {
+	initialize(verbose, database.InitWithoutMigrations)
+}
+
+// InitializeReadOnly sets up global instances like Initialize, but opens the
+// database read-only for commands that only query it
+func InitializeReadOnly(verbose bool) {
+	initialize(verbose, database.InitReadOnly)
+}
+
+func initialize(verbose bool, initDatabase func() error) {
 	initOnce.Do(func() {
 		// Setup logger first
 		setupLogger(verbose)
@@ -27,25 +46,64 @@ func Initialize(verbose bool) {
 		Logger.Debug("Initializing global instances")
 		
 		// Load or create settings
-		newSettings, settingsLoaded := config.LoadOrInitializeSettingsFromDefaultLocation()
+		newSettings, settingsLoaded, err := config.LoadOrInitializeSettingsFromStore(openSettingsStore())
 		Settings = settingsLoaded
+		if err != nil {
+			Logger.Error("Failed to load settings, using defaults", "error", err)
+		}
 		if newSettings {
 			Logger.Debug("Created new settings file")
 			if err := Settings.Save(); err != nil {
 				Logger.Error("Failed to save new settings", "error", err)
 			}
 		} else {
-			Logger.Debug("Loaded existing settings")
+			Logger.Debug("Loaded existing settings", "location", Settings.Store().Location())
 		}
 		
 		// Initialize database
-		database.Init()
+		// Nothing works without the database, and a database migrated by a
+		// newer version of the app must not be used
+		if err := initDatabase(); err != nil {
+			Logger.Error("Failed to initialize database", "path", database.GetDatabasePath(), "error", err)
+			os.Exit(1)
+		}
 		Logger.Debug("Database initialized")
 		
 		Logger.Info("Global initialization completed", "verbose", verbose)
 	})
 }
 
+// openSettingsStore returns the GSettings store if its schema is installed,
+// otherwise the settings file is used. The settings file is imported into
+// GSettings the first time it is used.
+func openSettingsStore() config.Store {
+	fileStore := config.NewJSONStore(config.DefaultSettingsPath())
+
+	store, err := gsettings.New()
+	if err != nil {
+		Logger.Debug("GSettings unavailable, using settings file", "path", fileStore.Location(), "error", err)
+		return fileStore
+	}
+
+	imported, err := store.ImportFrom(fileStore)
+	if err != nil {
+		Logger.Error("Failed to import settings file into GSettings", "path", fileStore.Location(), "error", err)
+	} else if imported {
+		Logger.Info("Imported settings file into GSettings", "path", fileStore.Location())
+	}
+
+	return store
+}
+
+// StatisticsDB returns the database to compute statistics from, which leaves
+// out measurements flagged as anomalies when the settings ask for it
+func StatisticsDB() *gorm.DB {
+	if Settings.ExcludeAnomalies {
+		return models.WithoutAnomalies(database.DB)
+	}
+	return database.DB
+}
+
 // setupLogger configures the global logger
 func setupLogger(verbose bool) {
 	level := slog.LevelInfo
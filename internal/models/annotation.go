@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Annotation is a note on the timeline of a device, at a point in time or
+// over a span, like when a window was opened, that explains its
+// measurements when looking back at them
+type Annotation struct {
+	gorm.Model
+	DeviceID uint
+	StartsAt time.Time
+	EndsAt   *time.Time // nil for a point in time
+	Text     string
+}
+
+// IsSpan reports whether the annotation covers a span of time rather than a point
+func (annotation Annotation) IsSpan() bool {
+	return annotation.EndsAt != nil && annotation.EndsAt.After(annotation.StartsAt)
+}
+
+// End returns when the annotation ends, its start for a point in time
+func (annotation Annotation) End() time.Time {
+	if annotation.IsSpan() {
+		return *annotation.EndsAt
+	}
+	return annotation.StartsAt
+}
+
+// Label describes when the annotation is, in local time, and what it says
+func (annotation Annotation) Label() string {
+	start := annotation.StartsAt.Local()
+	label := start.Format("Mon Jan 2 15:04")
+	if annotation.IsSpan() {
+		end := annotation.EndsAt.Local()
+		if end.YearDay() == start.YearDay() && end.Year() == start.Year() {
+			label += "–" + end.Format("15:04")
+		} else {
+			label += " – " + end.Format("Mon Jan 2 15:04")
+		}
+	}
+	return label + " " + annotation.Text
+}
+
+// NormalizeAnnotationText trims surrounding whitespace and joins the lines of a note
+func NormalizeAnnotationText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// AnnotationsBetween returns the annotations of devices that overlap the
+// time between two points, ordered by when they start
+func AnnotationsBetween(db *gorm.DB, deviceIDs []uint, from, to time.Time) ([]Annotation, error) {
+	var annotations []Annotation
+	err := db.Where("device_id IN ? AND starts_at <= ? AND COALESCE(ends_at, starts_at) >= ?", deviceIDs, to.UTC(), from.UTC()).
+		Order("starts_at ASC").
+		Find(&annotations).Error
+	return annotations, err
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Anomaly is a set of reasons a measurement looks like a sensor fault or
+// glitch rather than a real reading
+type Anomaly uint
+
+const (
+	AnomalyOutOfRange Anomaly = 1 << iota // A value outside of what is physically possible
+	AnomalyJump                           // A value changed faster than the air can
+	AnomalyFlatLine                       // Every value stayed exactly the same for too long
+	AnomalyStuckCO2                       // CO₂ stayed at the sensor's floor of 400 ppm
+)
+
+// Anomalies lists all anomalies in display order
+var Anomalies = []Anomaly{AnomalyOutOfRange, AnomalyJump, AnomalyFlatLine, AnomalyStuckCO2}
+
+// Has tells whether the set contains an anomaly
+func (anomaly Anomaly) Has(other Anomaly) bool {
+	return anomaly&other != 0
+}
+
+// Name returns the machine readable name of a single anomaly
+func (anomaly Anomaly) Name() string {
+	switch anomaly {
+	case AnomalyOutOfRange:
+		return "out_of_range"
+	case AnomalyJump:
+		return "jump"
+	case AnomalyFlatLine:
+		return "flat_line"
+	case AnomalyStuckCO2:
+		return "stuck_co2"
+	default:
+		return ""
+	}
+}
+
+// DisplayName returns the human readable name of a single anomaly
+func (anomaly Anomaly) DisplayName() string {
+	switch anomaly {
+	case AnomalyOutOfRange:
+		return "Out of range"
+	case AnomalyJump:
+		return "Impossible jump"
+	case AnomalyFlatLine:
+		return "Flat-lined"
+	case AnomalyStuckCO2:
+		return "CO₂ stuck at 400 ppm"
+	default:
+		return ""
+	}
+}
+
+// String lists the display names of the anomalies in the set
+func (anomaly Anomaly) String() string {
+	var names []string
+	for _, single := range Anomalies {
+		if anomaly.Has(single) {
+			names = append(names, single.DisplayName())
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// WithoutAnomalies returns a session of db that leaves out measurements
+// flagged as anomalies, safe to reuse for several queries
+func WithoutAnomalies(db *gorm.DB) *gorm.DB {
+	return db.Where("anomalies = 0").Session(&gorm.Session{})
+}
@@ -6,12 +6,91 @@ import (
 	"gorm.io/gorm"
 )
 
+// DeviceOfflineAfter is how long a device may go without reporting before it is considered offline
+const DeviceOfflineAfter = time.Minute
+
 type Device struct {
 	gorm.Model
-	Name         string `gorm:"uniqueIndex"`
-	IPAddress    string
-	DeviceType   string
-	SerialNumber string `gorm:"uniqueIndex"`
-	LastSeen     time.Time
-	Measurements []Measurement
+	Name               string `gorm:"uniqueIndex"`
+	IPAddress          string
+	DeviceType         string
+	SerialNumber       string   `gorm:"uniqueIndex"`
+	Room               string   `gorm:"index"`
+	RoomVolume         float64  `gorm:"not null;default:0"` // Volume of the room in m³, 0 if unknown
+	SurfaceTemperature *float64 // Temperature in °C of the room's coldest surface, like a wall or window, nil if unknown
+	LastSeen           time.Time
+	Measurements       []Measurement
+	Tags               []DeviceTag
+}
+
+// IsOnline reports whether the device was seen recently
+func (device *Device) IsOnline(now time.Time) bool {
+	return now.Sub(device.LastSeen) < DeviceOfflineAfter
+}
+
+// TagNames returns the names of the device's tags
+func (device *Device) TagNames() []string {
+	names := make([]string, 0, len(device.Tags))
+	for _, tag := range device.Tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// HasTag reports whether the device is tagged with the given tag
+func (device *Device) HasTag(name string) bool {
+	name = NormalizeTag(name)
+	for _, tag := range device.Tags {
+		if tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceTags replaces all tags of the device with the given tag names
+func (device *Device) ReplaceTags(db *gorm.DB, names []string) error {
+	names = NormalizeTags(names)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("device_id = ?", device.ID).Delete(&DeviceTag{}).Error
+		if err != nil {
+			return err
+		}
+
+		tags := make([]DeviceTag, 0, len(names))
+		for _, name := range names {
+			tags = append(tags, DeviceTag{DeviceID: device.ID, Name: name})
+		}
+
+		if len(tags) > 0 {
+			if err := tx.Create(&tags).Error; err != nil {
+				return err
+			}
+		}
+
+		device.Tags = tags
+		return nil
+	})
+}
+
+// InRoom scopes a device query to devices in the given room, ignoring case
+// as rooms keep the case they were entered with
+func InRoom(room string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("LOWER(devices.room) = LOWER(?)", NormalizeRoom(room))
+	}
+}
+
+// WithTag scopes a device query to devices tagged with the given tag
+func WithTag(tag string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			"devices.id IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).
+				Model(&DeviceTag{}).
+				Select("device_id").
+				Where("name = ?", NormalizeTag(tag)),
+		)
+	}
 }
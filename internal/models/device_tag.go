@@ -0,0 +1,47 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type DeviceTag struct {
+	gorm.Model
+	DeviceID uint
+	Name     string
+}
+
+// NormalizeRoom trims surrounding whitespace from a room name
+func NormalizeRoom(room string) string {
+	return strings.TrimSpace(room)
+}
+
+// NormalizeTag lowercases a tag and trims surrounding whitespace
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags normalizes, deduplicates and sorts a list of tags, dropping empty ones
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+	return normalized
+}
+
+// ParseTags splits a comma separated list of tags
+func ParseTags(input string) []string {
+	return NormalizeTags(strings.Split(input, ","))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,12 +9,107 @@ import (
 
 type Measurement struct {
 	gorm.Model
-	DeviceID    uint
-	Timestamp   time.Time `gorm:"index"`
+	DeviceID    uint      `gorm:"uniqueIndex:idx_measurements_device_id_timestamp"`
+	Timestamp   time.Time `gorm:"index;uniqueIndex:idx_measurements_device_id_timestamp"`
 	Temperature float64
 	Humidity    float64
+	DewPoint    *float64 // Reported by the device in °C, nil for measurements stored before it was
 	CO2         float64
 	VOC         float64
 	PM25        float64
 	Score       float64
+	Anomalies   Anomaly `gorm:"not null;default:0"` // Why the measurement looks like a sensor fault, zero if it doesn't
+}
+
+// AverageMeasurements combines measurements from several devices into one
+// by averaging each metric. The timestamp is the most recent one.
+func AverageMeasurements(measurements []Measurement) Measurement {
+	var average Measurement
+	if len(measurements) == 0 {
+		return average
+	}
+
+	for _, measurement := range measurements {
+		average.Temperature += measurement.Temperature
+		average.Humidity += measurement.Humidity
+		average.CO2 += measurement.CO2
+		average.VOC += measurement.VOC
+		average.PM25 += measurement.PM25
+		average.Score += measurement.Score
+
+		if measurement.Timestamp.After(average.Timestamp) {
+			average.Timestamp = measurement.Timestamp
+		}
+	}
+
+	count := float64(len(measurements))
+	average.Temperature /= count
+	average.Humidity /= count
+	average.CO2 /= count
+	average.VOC /= count
+	average.PM25 /= count
+	average.Score /= count
+
+	return average
+}
+
+// MeasurementMetrics maps the metric names accepted by history queries to
+// their columns in the measurements table
+var MeasurementMetrics = map[string]string{
+	"temperature": "temperature",
+	"humidity":    "humidity",
+	"co2":         "co2",
+	"voc":         "voc",
+	"pm25":        "pm25",
+	"score":       "score",
+}
+
+// HistoryPoint is the value of a metric at a point in time
+type HistoryPoint struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// MeasurementHistory returns the values of a metric of a device between two
+// points in time, oldest first. With a positive resolution the values are
+// averaged into buckets of that size, each stamped with its start.
+func MeasurementHistory(db *gorm.DB, deviceID uint, metric string, from, to time.Time, resolution time.Duration) ([]HistoryPoint, error) {
+	column, ok := MeasurementMetrics[metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+
+	query := db.Model(&Measurement{}).
+		Where("device_id = ? AND timestamp BETWEEN ? AND ?", deviceID, from.UTC(), to.UTC())
+
+	seconds := int64(resolution / time.Second)
+	if seconds <= 0 {
+		var points []HistoryPoint
+		err := query.Select("timestamp, " + column + " AS value").
+			Order("timestamp ASC").
+			Scan(&points).Error
+		return points, err
+	}
+
+	var buckets []struct {
+		Bucket int64
+		Value  float64
+	}
+	err := query.Select("CAST(strftime('%s', timestamp) AS INTEGER) / ? * ? AS bucket, AVG("+column+") AS value", seconds, seconds).
+		Group("bucket").
+		Order("bucket ASC").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]HistoryPoint, 0, len(buckets))
+	for _, bucket := range buckets {
+		points = append(points, HistoryPoint{
+			Timestamp: time.Unix(bucket.Bucket, 0).UTC(),
+			Value:     bucket.Value,
+		})
+	}
+
+	return points, nil
 }
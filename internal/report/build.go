@@ -0,0 +1,68 @@
+package report
+
+import (
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// Build creates a report about the last complete period before now, with
+// a section per subject
+func Build(db *gorm.DB, period Period, subjects []Subject, now time.Time) (Document, error) {
+	from, to := period.Bounds(now)
+
+	document := Document{
+		Period:       period,
+		From:         from,
+		To:           to,
+		PreviousFrom: period.previous(from),
+		Generated:    now,
+	}
+
+	for _, subject := range subjects {
+		deviceIDs := make([]uint, 0, len(subject.Devices))
+		for _, device := range subject.Devices {
+			deviceIDs = append(deviceIDs, device.ID)
+		}
+
+		var measurements []models.Measurement
+		err := db.Select("device_id, timestamp, temperature, humidity, co2, voc, pm25, score").
+			Where("device_id IN ? AND timestamp >= ? AND timestamp < ?", deviceIDs, document.PreviousFrom.UTC(), to.UTC()).
+			Order("timestamp ASC").
+			Find(&measurements).Error
+		if err != nil {
+			return Document{}, err
+		}
+
+		if len(deviceIDs) > 1 {
+			measurements = combine(measurements)
+		}
+
+		split := len(measurements)
+		for i, measurement := range measurements {
+			if !measurement.Timestamp.Before(from) {
+				split = i
+				break
+			}
+		}
+
+		section := newSection(subject, document, measurements[split:], measurements[:split])
+
+		annotations, err := models.AnnotationsBetween(db, deviceIDs, from, to)
+		if err != nil {
+			return Document{}, err
+		}
+		for _, annotation := range annotations {
+			for _, device := range subject.Devices {
+				if device.ID == annotation.DeviceID {
+					section.Annotations = append(section.Annotations, Annotation{Annotation: annotation, Device: device.Name})
+				}
+			}
+		}
+
+		document.Sections = append(document.Sections, section)
+	}
+
+	return document, nil
+}
@@ -0,0 +1,134 @@
+package report
+
+import (
+	"strings"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// Unit returns the symbol of the unit a metric is shown in
+func Unit(metric stats.Metric, preferences units.Preferences) string {
+	switch metric {
+	case stats.MetricTemperature:
+		return preferences.Temperature.Symbol()
+	case stats.MetricHumidity:
+		return "%"
+	case stats.MetricCO2:
+		return "ppm"
+	case stats.MetricVOC:
+		return preferences.VOC.Symbol()
+	case stats.MetricPM25:
+		return "μg/m³"
+	default:
+		return ""
+	}
+}
+
+// Convert converts a value from the unit the metric is stored in to the preferred unit
+func Convert(metric stats.Metric, value float64, preferences units.Preferences) float64 {
+	switch metric {
+	case stats.MetricTemperature:
+		return preferences.Temperature.FromCelsius(value)
+	case stats.MetricVOC:
+		return preferences.VOC.FromPPB(value)
+	default:
+		return value
+	}
+}
+
+// convertDelta converts a difference between two values of a metric to the preferred unit
+func convertDelta(metric stats.Metric, delta float64, preferences units.Preferences) float64 {
+	if metric == stats.MetricTemperature {
+		return preferences.Temperature.FromCelsiusDelta(delta)
+	}
+	return Convert(metric, delta, preferences)
+}
+
+// decimals returns how many decimals values of a metric are shown with
+func decimals(metric stats.Metric) int {
+	switch metric {
+	case stats.MetricTemperature, stats.MetricHumidity, stats.MetricPM25:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// FormatValue formats a stored value of a metric in the preferred unit
+func FormatValue(metric stats.Metric, value float64, preferences units.Preferences) string {
+	formatted := preferences.FormatNumber(Convert(metric, value, preferences), decimals(metric))
+	if unit := Unit(metric, preferences); unit != "" {
+		formatted += " " + unit
+	}
+	return formatted
+}
+
+// FormatChange formats the change of the mean of a metric since the
+// previous period, or a dash when there is nothing to compare with
+func FormatChange(section Section, metric stats.Metric, preferences units.Preferences) string {
+	if section.Current.Count == 0 || section.Previous.Count == 0 {
+		return "–"
+	}
+
+	delta := convertDelta(metric, section.Current.Metrics[metric].Mean-section.Previous.Metrics[metric].Mean, preferences)
+	formatted := preferences.FormatNumber(delta, decimals(metric))
+	if strings.Trim(formatted, "-0.,") == "" {
+		return "±0"
+	}
+	if delta > 0 {
+		return "+" + formatted
+	}
+	return strings.Replace(formatted, "-", "−", 1)
+}
+
+// FormatDurationChange formats the change of a duration since the previous period
+func FormatDurationChange(current, previous time.Duration) string {
+	switch {
+	case current > previous:
+		return "+" + stats.FormatDuration(current-previous)
+	case current < previous:
+		return "−" + stats.FormatDuration(previous-current)
+	default:
+		return "±0"
+	}
+}
+
+// FormatTimestamp formats when something was measured in local time
+func FormatTimestamp(timestamp time.Time) string {
+	return timestamp.In(time.Local).Format("Mon 2 Jan 15:04")
+}
+
+// ThresholdLabel describes a threshold, like "CO₂ above 1000 ppm"
+func ThresholdLabel(threshold Threshold, preferences units.Preferences) string {
+	label := threshold.Metric.DisplayName() + " above " +
+		preferences.FormatNumber(Convert(threshold.Metric, threshold.Limit, preferences), 0)
+	if unit := Unit(threshold.Metric, preferences); unit != "" {
+		label += " " + unit
+	}
+	return label
+}
+
+// HourLabel formats an hour of the day as a range, like 14:00–15:00
+func HourLabel(hour int) string {
+	return time.Date(0, 1, 1, hour, 0, 0, 0, time.UTC).Format("15:04") + "–" +
+		time.Date(0, 1, 1, (hour+1)%24, 0, 0, 0, time.UTC).Format("15:04")
+}
+
+// Summary returns a one line summary of a section, used for notifications
+func Summary(section Section, preferences units.Preferences) string {
+	if section.Current.Count == 0 {
+		return section.Name + ": no measurements"
+	}
+
+	parts := []string{
+		"score " + FormatValue(stats.MetricScore, section.Current.Metrics[stats.MetricScore].Mean, preferences),
+	}
+	for _, exceedance := range section.Exceedances {
+		if exceedance.Current > 0 {
+			parts = append(parts, stats.FormatDuration(exceedance.Current)+" "+ThresholdLabel(exceedance.Threshold, preferences))
+		}
+	}
+	return section.Name + ": " + strings.Join(parts, ", ")
+}
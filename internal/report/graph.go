@@ -0,0 +1,101 @@
+package report
+
+import (
+	"math"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+)
+
+// GraphColors are the colors of the metrics in graphs, the same as in the app
+var GraphColors = map[stats.Metric][3]float64{
+	stats.MetricTemperature: {0.96, 0.47, 0.24},
+	stats.MetricHumidity:    {0.20, 0.74, 0.96},
+	stats.MetricCO2:         {0.95, 0.61, 0.23},
+	stats.MetricVOC:         {0.58, 0.75, 0.33},
+	stats.MetricPM25:        {0.88, 0.32, 0.43},
+	stats.MetricScore:       {0.45, 0.67, 0.89},
+}
+
+// GraphSegments splits points into runs without missing buckets, each run
+// is drawn as a separate line so gaps in the data stay visible
+func GraphSegments(points []Point, resolution time.Duration) [][]Point {
+	var segments [][]Point
+	start := 0
+
+	for i := 1; i <= len(points); i++ {
+		if i == len(points) || points[i].Timestamp.Sub(points[i-1].Timestamp) > resolution {
+			segments = append(segments, points[start:i])
+			start = i
+		}
+	}
+	return segments
+}
+
+// GraphRange returns the range of values a graph shows, padded so the
+// line doesn't touch the top and bottom edges
+func GraphRange(points []Point) (low, high float64) {
+	low, high = math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		low = math.Min(low, point.Value)
+		high = math.Max(high, point.Value)
+	}
+
+	if len(points) == 0 {
+		return 0, 1
+	}
+
+	padding := (high - low) * 0.1
+	if padding == 0 {
+		padding = math.Max(math.Abs(high)*0.1, 1)
+	}
+	return low - padding, high + padding
+}
+
+// GraphPosition returns where a point is drawn, as fractions of the width
+// from the left and of the height from the top
+func GraphPosition(point Point, document Document, low, high float64) (x, y float64) {
+	// Points are drawn in the middle of their bucket
+	middle := point.Timestamp.Add(document.Period.Resolution() / 2)
+	x = float64(middle.Sub(document.From)) / float64(document.To.Sub(document.From))
+	y = 1 - (point.Value-low)/(high-low)
+	return x, y
+}
+
+// GraphAnnotationColor is the color annotations are marked with in graphs, the same as in the app
+var GraphAnnotationColor = [3]float64{0.57, 0.25, 0.67}
+
+// GraphSpan returns where an annotation is marked, as fractions of the
+// width from the left clamped to the graph, the same for a point in time
+func GraphSpan(annotation Annotation, document Document) (from, to float64) {
+	length := float64(document.To.Sub(document.From))
+	from = float64(annotation.StartsAt.Sub(document.From)) / length
+	to = float64(annotation.End().Sub(document.From)) / length
+	return math.Max(from, 0), math.Min(to, 1)
+}
+
+// GraphTick is a labelled line across a graph
+type GraphTick struct {
+	Position float64 // Fraction of the width from the left
+	Label    string
+}
+
+// GraphTicks returns the lines of the time axis, one per day in a weekly
+// report and one every six hours in a daily one
+func GraphTicks(document Document) []GraphTick {
+	var ticks []GraphTick
+	length := float64(document.To.Sub(document.From))
+
+	if document.Period == PeriodWeek {
+		for day := document.From; day.Before(document.To); day = day.AddDate(0, 0, 1) {
+			ticks = append(ticks, GraphTick{float64(day.Sub(document.From)) / length, day.Format("Mon")})
+		}
+		return ticks
+	}
+
+	for hour := 0; hour < 24; hour += 6 {
+		tick := document.From.Add(time.Duration(hour) * time.Hour)
+		ticks = append(ticks, GraphTick{float64(tick.Sub(document.From)) / length, tick.Format("15:04")})
+	}
+	return ticks
+}
@@ -0,0 +1,227 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// Size of the graphs in an HTML report, in pixels
+const (
+	htmlGraphWidth  = 480
+	htmlGraphHeight = 140
+)
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} – {{.Period}}</title>
+<style>
+body { font-family: Cantarell, "Noto Sans", sans-serif; color: #241f31; max-width: 1000px; margin: 2em auto; padding: 0 1em; }
+h1 { margin-bottom: 0.2em; }
+.dim { color: #77767b; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #deddda; text-align: left; }
+td.number { text-align: right; font-variant-numeric: tabular-nums; }
+.graphs { display: flex; flex-wrap: wrap; gap: 1em; }
+figure { margin: 0; }
+figcaption { font-weight: bold; }
+.notes { color: #813d9c; }
+.good { color: #26a269; } .moderate { color: #c88800; } .poor { color: #c01c28; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="dim">{{.Period}}, generated {{.Generated}}</p>
+{{range .Sections}}
+<section>
+<h2>{{.Name}}</h2>
+{{if .Devices}}<p class="dim">Devices: {{.Devices}}</p>{{end}}
+{{if not .Count}}<p>No measurements in this period.</p>{{else}}
+<p>{{.Count}} measurements, changes are relative to {{$.Comparison}}.</p>
+<table>
+<tr><th>Metric</th><th>Average</th><th>Change</th><th>Peak</th></tr>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td class="number">{{.Average}}</td><td class="number">{{.Change}}</td><td>{{.Peak}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Air quality</th><th>Time</th><th>Share</th><th>Change</th></tr>
+{{range .Bands}}<tr><td class="{{.Class}}">{{.Name}}</td><td class="number">{{.Time}}</td><td class="number">{{.Share}}</td><td class="number">{{.Change}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Threshold</th><th>Time</th><th>Change</th><th>Why it matters</th></tr>
+{{range .Exceedances}}<tr><td>{{.Name}}</td><td class="number">{{.Time}}</td><td class="number">{{.Change}}</td><td class="dim">{{.Reason}}</td></tr>
+{{end}}</table>
+{{if .WorstHours}}<p>Worst hours of the day: {{.WorstHours}}</p>{{end}}
+{{if .Annotations}}<p>Notes:</p>
+<ul class="notes">
+{{range .Annotations}}<li>{{.}}</li>
+{{end}}</ul>{{end}}
+<div class="graphs">
+{{range .Metrics}}<figure><figcaption>{{.Name}}{{if .Unit}} ({{.Unit}}){{end}}</figcaption>{{.Graph}}</figure>
+{{end}}</div>
+{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+type htmlDocument struct {
+	Title      string
+	Period     string
+	Generated  string
+	Comparison string
+	Sections   []htmlSection
+}
+
+type htmlSection struct {
+	Name        string
+	Devices     string
+	Count       int
+	Metrics     []htmlMetric
+	Bands       []htmlRow
+	Exceedances []htmlRow
+	WorstHours  string
+	Annotations []string
+}
+
+type htmlMetric struct {
+	Name    string
+	Unit    string
+	Average string
+	Change  string
+	Peak    string
+	Graph   template.HTML
+}
+
+type htmlRow struct {
+	Name   string
+	Class  string
+	Time   string
+	Share  string
+	Change string
+	Reason string
+}
+
+// WriteHTML writes a report as a standalone HTML page with SVG graphs
+func WriteHTML(w io.Writer, document Document, preferences units.Preferences) error {
+	view := htmlDocument{
+		Title:      document.Period.Title(),
+		Period:     document.PeriodLabel(),
+		Generated:  FormatTimestamp(document.Generated),
+		Comparison: document.ComparisonLabel(),
+	}
+
+	for _, section := range document.Sections {
+		sectionView := htmlSection{Name: section.Name, Count: section.Current.Count}
+		if section.ShowDevices() {
+			sectionView.Devices = strings.Join(section.Devices, ", ")
+		}
+
+		for _, metric := range stats.Metrics {
+			peak := section.Peaks[metric]
+			sectionView.Metrics = append(sectionView.Metrics, htmlMetric{
+				Name:    metric.DisplayName(),
+				Unit:    Unit(metric, preferences),
+				Average: FormatValue(metric, section.Current.Metrics[metric].Mean, preferences),
+				Change:  FormatChange(section, metric, preferences),
+				Peak:    PeakLabel(metric) + " " + FormatValue(metric, peak.Value, preferences) + " on " + FormatTimestamp(peak.Timestamp),
+				Graph:   svgGraph(document, metric, section.Series[metric], section.Annotations, preferences),
+			})
+		}
+
+		for _, band := range stats.Bands {
+			sectionView.Bands = append(sectionView.Bands, htmlRow{
+				Name:   band.DisplayName(),
+				Class:  string(band),
+				Time:   stats.FormatDuration(section.Current.TimeInBand[band]),
+				Share:  fmt.Sprintf("%.0f%%", section.Current.BandShare(band)*100),
+				Change: FormatDurationChange(section.Current.TimeInBand[band], section.Previous.TimeInBand[band]),
+			})
+		}
+
+		for _, exceedance := range section.Exceedances {
+			sectionView.Exceedances = append(sectionView.Exceedances, htmlRow{
+				Name:   ThresholdLabel(exceedance.Threshold, preferences),
+				Time:   stats.FormatDuration(exceedance.Current),
+				Change: FormatDurationChange(exceedance.Current, exceedance.Previous),
+				Reason: exceedance.Threshold.Reason,
+			})
+		}
+
+		hours := make([]string, 0, len(section.Current.WorstHours))
+		for _, hour := range section.Current.WorstHours {
+			hours = append(hours, fmt.Sprintf("%s (score %.0f)", HourLabel(hour.Hour), hour.Score))
+		}
+		sectionView.WorstHours = strings.Join(hours, ", ")
+
+		for _, annotation := range section.Annotations {
+			sectionView.Annotations = append(sectionView.Annotations, section.AnnotationLabel(annotation))
+		}
+
+		view.Sections = append(view.Sections, sectionView)
+	}
+
+	return htmlTemplate.Execute(w, view)
+}
+
+// svgGraph draws the averaged values of a metric as an SVG line graph, with
+// annotations marked behind it
+func svgGraph(document Document, metric stats.Metric, points []Point, annotations []Annotation, preferences units.Preferences) template.HTML {
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-size="10" fill="#77767b">`,
+		htmlGraphWidth, htmlGraphHeight+16, htmlGraphWidth, htmlGraphHeight+16)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="none" stroke="#deddda"/>`, htmlGraphWidth, htmlGraphHeight)
+
+	for _, tick := range GraphTicks(document) {
+		x := tick.Position * htmlGraphWidth
+		fmt.Fprintf(&svg, `<line x1="%.1f" y1="0" x2="%.1f" y2="%d" stroke="#deddda"/>`, x, x, htmlGraphHeight)
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d">%s</text>`, x+2, htmlGraphHeight+12, template.HTMLEscapeString(tick.Label))
+	}
+
+	marker := fmt.Sprintf("rgb(%.0f,%.0f,%.0f)", GraphAnnotationColor[0]*255, GraphAnnotationColor[1]*255, GraphAnnotationColor[2]*255)
+	for _, annotation := range annotations {
+		from, to := GraphSpan(annotation, document)
+		title := template.HTMLEscapeString(annotation.Label())
+		if annotation.IsSpan() {
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="0" width="%.1f" height="%d" fill="%s" fill-opacity="0.15"><title>%s</title></rect>`,
+				from*htmlGraphWidth, (to-from)*htmlGraphWidth, htmlGraphHeight, marker, title)
+			continue
+		}
+		fmt.Fprintf(&svg, `<line x1="%.1f" y1="0" x2="%.1f" y2="%d" stroke="%s" stroke-dasharray="3 3"><title>%s</title></line>`,
+			from*htmlGraphWidth, from*htmlGraphWidth, htmlGraphHeight, marker, title)
+	}
+
+	low, high := GraphRange(points)
+	color := GraphColors[metric]
+	stroke := fmt.Sprintf("rgb(%.0f,%.0f,%.0f)", color[0]*255, color[1]*255, color[2]*255)
+
+	for _, segment := range GraphSegments(points, document.Period.Resolution()) {
+		if len(segment) == 1 {
+			// A lone bucket has no neighbour to draw a line to
+			x, y := GraphPosition(segment[0], document, low, high)
+			fmt.Fprintf(&svg, `<circle cx="%.1f" cy="%.1f" r="2" fill="%s"/>`, x*htmlGraphWidth, y*htmlGraphHeight, stroke)
+			continue
+		}
+
+		coordinates := make([]string, 0, len(segment))
+		for _, point := range segment {
+			x, y := GraphPosition(point, document, low, high)
+			coordinates = append(coordinates, fmt.Sprintf("%.1f,%.1f", x*htmlGraphWidth, y*htmlGraphHeight))
+		}
+		fmt.Fprintf(&svg, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(coordinates, " "), stroke)
+	}
+
+	if len(points) > 0 {
+		fmt.Fprintf(&svg, `<text x="4" y="12">%s</text>`, template.HTMLEscapeString(FormatValue(metric, high, preferences)))
+		fmt.Fprintf(&svg, `<text x="4" y="%d">%s</text>`, htmlGraphHeight-4, template.HTMLEscapeString(FormatValue(metric, low, preferences)))
+	}
+
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// sparklineBlocks are the characters of a sparkline from lowest to highest
+var sparklineBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// WriteMarkdown writes a report as Markdown, with the graphs as sparklines
+func WriteMarkdown(w io.Writer, document Document, preferences units.Preferences) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# %s\n\n", document.Period.Title())
+	fmt.Fprintf(out, "%s, generated %s\n", document.PeriodLabel(), FormatTimestamp(document.Generated))
+
+	for _, section := range document.Sections {
+		fmt.Fprintf(out, "\n## %s\n\n", escapeMarkdown(section.Name))
+
+		if section.ShowDevices() {
+			fmt.Fprintf(out, "Devices: %s\n\n", escapeMarkdown(strings.Join(section.Devices, ", ")))
+		}
+
+		if section.Current.Count == 0 {
+			fmt.Fprintln(out, "No measurements in this period.")
+			continue
+		}
+
+		fmt.Fprintf(out, "%d measurements, changes are relative to %s.\n\n", section.Current.Count, document.ComparisonLabel())
+
+		fmt.Fprintln(out, "| Metric | Average | Change | Peak | Trend |")
+		fmt.Fprintln(out, "| --- | ---: | ---: | --- | --- |")
+		for _, metric := range stats.Metrics {
+			peak := section.Peaks[metric]
+			fmt.Fprintf(out, "| %s | %s | %s | %s %s on %s | %s |\n",
+				metric.DisplayName(),
+				FormatValue(metric, section.Current.Metrics[metric].Mean, preferences),
+				FormatChange(section, metric, preferences),
+				PeakLabel(metric),
+				FormatValue(metric, peak.Value, preferences),
+				FormatTimestamp(peak.Timestamp),
+				sparkline(section.Series[metric]),
+			)
+		}
+
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "| Air quality | Time | Share | Change |")
+		fmt.Fprintln(out, "| --- | ---: | ---: | ---: |")
+		for _, band := range stats.Bands {
+			fmt.Fprintf(out, "| %s | %s | %.0f%% | %s |\n",
+				band.DisplayName(),
+				stats.FormatDuration(section.Current.TimeInBand[band]),
+				section.Current.BandShare(band)*100,
+				FormatDurationChange(section.Current.TimeInBand[band], section.Previous.TimeInBand[band]),
+			)
+		}
+
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "| Threshold | Time | Change | Why it matters |")
+		fmt.Fprintln(out, "| --- | ---: | ---: | --- |")
+		for _, exceedance := range section.Exceedances {
+			fmt.Fprintf(out, "| %s | %s | %s | %s |\n",
+				ThresholdLabel(exceedance.Threshold, preferences),
+				stats.FormatDuration(exceedance.Current),
+				FormatDurationChange(exceedance.Current, exceedance.Previous),
+				exceedance.Threshold.Reason,
+			)
+		}
+
+		if len(section.Current.WorstHours) > 0 {
+			hours := make([]string, 0, len(section.Current.WorstHours))
+			for _, hour := range section.Current.WorstHours {
+				hours = append(hours, fmt.Sprintf("%s (score %.0f)", HourLabel(hour.Hour), hour.Score))
+			}
+			fmt.Fprintf(out, "\nWorst hours of the day: %s\n", strings.Join(hours, ", "))
+		}
+
+		if len(section.Annotations) > 0 {
+			fmt.Fprintln(out, "\nNotes:")
+			fmt.Fprintln(out)
+			for _, annotation := range section.Annotations {
+				fmt.Fprintf(out, "- %s\n", escapeMarkdown(section.AnnotationLabel(annotation)))
+			}
+		}
+	}
+
+	return out.Flush()
+}
+
+// sparkline draws points as a line of block characters
+func sparkline(points []Point) string {
+	if len(points) == 0 {
+		return ""
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		low = math.Min(low, point.Value)
+		high = math.Max(high, point.Value)
+	}
+
+	var line strings.Builder
+	for _, point := range points {
+		level := 0
+		if high > low {
+			level = int(math.Round((point.Value - low) / (high - low) * float64(len(sparklineBlocks)-1)))
+		}
+		line.WriteRune(sparklineBlocks[level])
+	}
+	return line.String()
+}
+
+// escapeMarkdown escapes the characters of a name that Markdown would interpret
+func escapeMarkdown(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\`*_[]<>#|", r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,283 @@
+// Package pdf renders air quality reports as PDF documents with cairo
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	cairo "github.com/diamondburned/gotk4/pkg/cairo"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/report"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/units"
+)
+
+// A4 in points, and the space left around the content
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 48.0
+
+	contentWidth = pageWidth - 2*margin
+	lineHeight   = 15.0
+	graphWidth   = (contentWidth - 16) / 2
+	graphHeight  = 90.0
+)
+
+// page keeps track of where the next line goes
+type page struct {
+	cr *cairo.Context
+	y  float64
+}
+
+// Write renders a report to a PDF file
+func Write(path string, document report.Document, preferences units.Preferences) error {
+	surface, err := cairo.CreatePDFSurface(path, pageWidth, pageHeight)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	cr := cairo.Create(surface)
+	p := &page{cr: cr, y: margin}
+
+	p.text(margin, document.Period.Title(), 20, true)
+	p.y += 6
+	p.dimText(margin, document.PeriodLabel()+", generated "+report.FormatTimestamp(document.Generated), 10)
+
+	for _, section := range document.Sections {
+		p.writeSection(document, section, preferences)
+	}
+
+	cr.ShowPage()
+	status := cr.Status()
+	cr.Close()
+	surface.Close()
+
+	if status != cairo.StatusSuccess {
+		return fmt.Errorf("failed to render %s: %w", path, status)
+	}
+	return nil
+}
+
+// writeSection renders the tables and graphs of a section
+func (p *page) writeSection(document report.Document, section report.Section, preferences units.Preferences) {
+	p.ensure(4 * lineHeight)
+	p.y += 18
+	p.text(margin, section.Name, 15, true)
+
+	if section.ShowDevices() {
+		p.dimText(margin, "Devices: "+strings.Join(section.Devices, ", "), 10)
+	}
+
+	if section.Current.Count == 0 {
+		p.text(margin, "No measurements in this period.", 10, false)
+		return
+	}
+
+	p.text(margin, fmt.Sprintf("%d measurements, changes are relative to %s.", section.Current.Count, document.ComparisonLabel()), 10, false)
+	p.y += 6
+
+	metricColumns := []float64{0, 90, 180, 250}
+	p.row(metricColumns, []string{"Metric", "Average", "Change", "Peak"}, true)
+	for _, metric := range stats.Metrics {
+		peak := section.Peaks[metric]
+		p.row(metricColumns, []string{
+			metric.DisplayName(),
+			report.FormatValue(metric, section.Current.Metrics[metric].Mean, preferences),
+			report.FormatChange(section, metric, preferences),
+			report.PeakLabel(metric) + " " + report.FormatValue(metric, peak.Value, preferences) + " on " + report.FormatTimestamp(peak.Timestamp),
+		}, false)
+	}
+	p.y += 8
+
+	bandColumns := []float64{0, 90, 180, 250}
+	p.row(bandColumns, []string{"Air quality", "Time", "Share", "Change"}, true)
+	for _, band := range stats.Bands {
+		p.row(bandColumns, []string{
+			band.DisplayName(),
+			stats.FormatDuration(section.Current.TimeInBand[band]),
+			fmt.Sprintf("%.0f%%", section.Current.BandShare(band)*100),
+			report.FormatDurationChange(section.Current.TimeInBand[band], section.Previous.TimeInBand[band]),
+		}, false)
+	}
+	p.y += 8
+
+	thresholdColumns := []float64{0, 150, 220, 290}
+	p.row(thresholdColumns, []string{"Threshold", "Time", "Change", "Why it matters"}, true)
+	for _, exceedance := range section.Exceedances {
+		p.row(thresholdColumns, []string{
+			report.ThresholdLabel(exceedance.Threshold, preferences),
+			stats.FormatDuration(exceedance.Current),
+			report.FormatDurationChange(exceedance.Current, exceedance.Previous),
+			exceedance.Threshold.Reason,
+		}, false)
+	}
+
+	if len(section.Current.WorstHours) > 0 {
+		hours := make([]string, 0, len(section.Current.WorstHours))
+		for _, hour := range section.Current.WorstHours {
+			hours = append(hours, fmt.Sprintf("%s (score %.0f)", report.HourLabel(hour.Hour), hour.Score))
+		}
+		p.y += 8
+		p.text(margin, "Worst hours of the day: "+strings.Join(hours, ", "), 10, false)
+	}
+
+	if len(section.Annotations) > 0 {
+		p.y += 8
+		p.text(margin, "Notes:", 10, false)
+		for _, annotation := range section.Annotations {
+			p.text(margin+8, "• "+section.AnnotationLabel(annotation), 9, false)
+		}
+	}
+
+	// Graphs two per row
+	for i, metric := range stats.Metrics {
+		if i%2 == 0 {
+			p.ensure(graphHeight + 3*lineHeight)
+			p.y += 10
+		}
+
+		x := margin + float64(i%2)*(graphWidth+16)
+		title := metric.DisplayName()
+		if unit := report.Unit(metric, preferences); unit != "" {
+			title += " (" + unit + ")"
+		}
+		p.label(x, p.y+lineHeight, title, 10, true)
+		p.graph(x, p.y+lineHeight+6, document, metric, section.Series[metric], section.Annotations, preferences)
+
+		if i%2 == 1 || i == len(stats.Metrics)-1 {
+			p.y += graphHeight + 2*lineHeight + 6
+		}
+	}
+}
+
+// ensure starts a new page unless there is room for the given height
+func (p *page) ensure(height float64) {
+	if p.y+height > pageHeight-margin {
+		p.cr.ShowPage()
+		p.y = margin
+	}
+}
+
+// text writes a line at the cursor and moves the cursor below it
+func (p *page) text(x float64, text string, size float64, bold bool) {
+	p.ensure(size + 4)
+	p.y += size + 4
+	p.label(x, p.y, text, size, bold)
+}
+
+// dimText writes a line in grey
+func (p *page) dimText(x float64, text string, size float64) {
+	p.ensure(size + 4)
+	p.y += size + 4
+	p.cr.SetSourceRGB(0.47, 0.46, 0.48)
+	p.cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
+	p.cr.SetFontSize(size)
+	p.cr.MoveTo(x, p.y)
+	p.cr.ShowText(text)
+}
+
+// label writes text with its baseline at a position without moving the cursor
+func (p *page) label(x, y float64, text string, size float64, bold bool) {
+	weight := cairo.FontWeightNormal
+	if bold {
+		weight = cairo.FontWeightBold
+	}
+
+	p.cr.SetSourceRGB(0.14, 0.12, 0.19)
+	p.cr.SelectFontFace("Sans", cairo.FontSlantNormal, weight)
+	p.cr.SetFontSize(size)
+	p.cr.MoveTo(x, y)
+	p.cr.ShowText(text)
+}
+
+// row writes a table row with cells starting at the given offsets
+func (p *page) row(columns []float64, cells []string, header bool) {
+	p.ensure(lineHeight)
+	p.y += lineHeight
+	for i, cell := range cells {
+		p.label(margin+columns[i], p.y, cell, 9, header)
+	}
+
+	p.cr.SetSourceRGB(0.87, 0.87, 0.85)
+	p.cr.SetLineWidth(0.5)
+	p.cr.MoveTo(margin, p.y+4)
+	p.cr.LineTo(margin+contentWidth, p.y+4)
+	p.cr.Stroke()
+}
+
+// graph draws the averaged values of a metric as a line graph, with
+// annotations marked behind it
+func (p *page) graph(x, y float64, document report.Document, metric stats.Metric, points []report.Point, annotations []report.Annotation, preferences units.Preferences) {
+	cr := p.cr
+
+	cr.SetSourceRGB(0.87, 0.87, 0.85)
+	cr.SetLineWidth(0.5)
+	cr.Rectangle(x, y, graphWidth, graphHeight)
+	cr.Stroke()
+
+	for _, tick := range report.GraphTicks(document) {
+		tickX := x + tick.Position*graphWidth
+		cr.SetSourceRGB(0.87, 0.87, 0.85)
+		cr.MoveTo(tickX, y)
+		cr.LineTo(tickX, y+graphHeight)
+		cr.Stroke()
+
+		cr.SetSourceRGB(0.47, 0.46, 0.48)
+		cr.SelectFontFace("Sans", cairo.FontSlantNormal, cairo.FontWeightNormal)
+		cr.SetFontSize(7)
+		cr.MoveTo(tickX+2, y+graphHeight+9)
+		cr.ShowText(tick.Label)
+	}
+
+	marker := report.GraphAnnotationColor
+	for _, annotation := range annotations {
+		from, to := report.GraphSpan(annotation, document)
+		if annotation.IsSpan() {
+			cr.SetSourceRGBA(marker[0], marker[1], marker[2], 0.15)
+			cr.Rectangle(x+from*graphWidth, y, (to-from)*graphWidth, graphHeight)
+			cr.Fill()
+			continue
+		}
+		cr.SetSourceRGB(marker[0], marker[1], marker[2])
+		cr.SetDash([]float64{2, 2}, 0)
+		cr.MoveTo(x+from*graphWidth, y)
+		cr.LineTo(x+from*graphWidth, y+graphHeight)
+		cr.Stroke()
+		cr.SetDash(nil, 0)
+	}
+
+	if len(points) == 0 {
+		return
+	}
+
+	low, high := report.GraphRange(points)
+	color := report.GraphColors[metric]
+	cr.SetSourceRGB(color[0], color[1], color[2])
+	cr.SetLineWidth(1.5)
+
+	for _, segment := range report.GraphSegments(points, document.Period.Resolution()) {
+		for i, point := range segment {
+			pointX, pointY := report.GraphPosition(point, document, low, high)
+			pointX, pointY = x+pointX*graphWidth, y+pointY*graphHeight
+
+			if len(segment) == 1 {
+				// A lone bucket has no neighbour to draw a line to
+				cr.Arc(pointX, pointY, 1.5, 0, 2*math.Pi)
+				cr.Fill()
+			} else if i == 0 {
+				cr.MoveTo(pointX, pointY)
+			} else {
+				cr.LineTo(pointX, pointY)
+			}
+		}
+		cr.Stroke()
+	}
+
+	cr.SetSourceRGB(0.47, 0.46, 0.48)
+	cr.SetFontSize(7)
+	cr.MoveTo(x+3, y+9)
+	cr.ShowText(report.FormatValue(metric, high, preferences))
+	cr.MoveTo(x+3, y+graphHeight-3)
+	cr.ShowText(report.FormatValue(metric, low, preferences))
+}
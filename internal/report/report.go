@@ -0,0 +1,311 @@
+// Package report builds daily and weekly air quality reports and renders
+// them as Markdown and HTML. PDF rendering lives in the pdf subpackage as
+// it needs cairo.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+)
+
+// Period is the length of time a report covers
+type Period string
+
+const (
+	PeriodDay  Period = "day"
+	PeriodWeek Period = "week"
+)
+
+// ParsePeriod parses the name of a period
+func ParsePeriod(name string) (Period, error) {
+	switch Period(strings.ToLower(strings.TrimSpace(name))) {
+	case PeriodDay:
+		return PeriodDay, nil
+	case PeriodWeek:
+		return PeriodWeek, nil
+	default:
+		return "", fmt.Errorf("unknown period %q, expected day or week", name)
+	}
+}
+
+// Title returns the title of reports covering the period
+func (period Period) Title() string {
+	if period == PeriodWeek {
+		return "Weekly Air Quality Report"
+	}
+	return "Daily Air Quality Report"
+}
+
+// Bounds returns the last complete period before now in local time,
+// yesterday for a day and Monday to Sunday of last week for a week
+func (period Period) Bounds(now time.Time) (from, to time.Time) {
+	now = now.In(time.Local)
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	if period == PeriodWeek {
+		daysSinceMonday := (int(to.Weekday()) + 6) % 7
+		to = to.AddDate(0, 0, -daysSinceMonday)
+		return to.AddDate(0, 0, -7), to
+	}
+
+	return to.AddDate(0, 0, -1), to
+}
+
+// previous returns the period before the one starting at from
+func (period Period) previous(from time.Time) time.Time {
+	if period == PeriodWeek {
+		return from.AddDate(0, 0, -7)
+	}
+	return from.AddDate(0, 0, -1)
+}
+
+// Resolution returns the size of the buckets measurements are averaged
+// into for the graphs of a report
+func (period Period) Resolution() time.Duration {
+	if period == PeriodWeek {
+		return 3 * time.Hour
+	}
+	return time.Hour
+}
+
+// Threshold is a limit above which a metric is worth pointing out
+type Threshold struct {
+	Metric stats.Metric
+	Limit  float64 // In the unit the metric is stored in
+	Reason string
+}
+
+// Thresholds are the limits the time above is reported for
+var Thresholds = []Threshold{
+	{stats.MetricCO2, 1000, "Stuffy air, ventilation is recommended"},
+	{stats.MetricVOC, 1000, "High chemical pollution"},
+	{stats.MetricPM25, 15, "WHO 24-hour guideline"},
+	{stats.MetricHumidity, 60, "Risk of mould and dust mites"},
+}
+
+// Point is an averaged value of a metric in a graph
+type Point struct {
+	Timestamp time.Time // Start of the bucket
+	Value     float64
+}
+
+// Exceedance is how long a metric was above a threshold
+type Exceedance struct {
+	Threshold Threshold
+	Current   time.Duration
+	Previous  time.Duration
+}
+
+// Annotation is a note on the timeline of a device in the period of a report
+type Annotation struct {
+	models.Annotation
+	Device string // Name of the device
+}
+
+// Section is the part of a report about one device or room
+type Section struct {
+	Name        string
+	Devices     []string // Names of the devices whose measurements are included
+	Current     stats.Report
+	Previous    stats.Report // The period before, to compare against
+	Peaks       map[stats.Metric]stats.Peak
+	Exceedances []Exceedance
+	Series      map[stats.Metric][]Point
+	Annotations []Annotation
+}
+
+// Document is a report about one or more devices or rooms
+type Document struct {
+	Period       Period
+	From         time.Time
+	To           time.Time
+	PreviousFrom time.Time
+	Generated    time.Time
+	Sections     []Section
+}
+
+// Subject is a device, or the devices of a room, that gets its own section
+type Subject struct {
+	Name    string
+	Devices []models.Device
+}
+
+// DeviceSubject returns the subject of a single device
+func DeviceSubject(device models.Device) Subject {
+	return Subject{Name: device.Name, Devices: []models.Device{device}}
+}
+
+// RoomSubjects groups devices by room, sorted by room name. Devices
+// without a room get a subject each, after the rooms.
+func RoomSubjects(devices []models.Device) []Subject {
+	var rooms []string
+	byRoom := make(map[string][]models.Device)
+	var unassigned []Subject
+
+	for _, device := range devices {
+		if device.Room == "" {
+			unassigned = append(unassigned, DeviceSubject(device))
+			continue
+		}
+		if _, exists := byRoom[device.Room]; !exists {
+			rooms = append(rooms, device.Room)
+		}
+		byRoom[device.Room] = append(byRoom[device.Room], device)
+	}
+
+	sort.Strings(rooms)
+
+	subjects := make([]Subject, 0, len(rooms)+len(unassigned))
+	for _, room := range rooms {
+		subjects = append(subjects, Subject{Name: room, Devices: byRoom[room]})
+	}
+	return append(subjects, unassigned...)
+}
+
+// ShowDevices tells whether the devices of a section are worth listing,
+// which they aren't for a section about a single device
+func (section Section) ShowDevices() bool {
+	return len(section.Devices) > 1 || (len(section.Devices) == 1 && section.Devices[0] != section.Name)
+}
+
+// AnnotationLabel describes when an annotation is and what it says, with
+// the device it's on when the section has several
+func (section Section) AnnotationLabel(annotation Annotation) string {
+	if section.ShowDevices() {
+		return annotation.Label() + " (" + annotation.Device + ")"
+	}
+	return annotation.Label()
+}
+
+// PeriodLabel describes the dates the report covers
+func (document Document) PeriodLabel() string {
+	last := document.To.Add(-time.Nanosecond)
+	if document.Period == PeriodWeek {
+		return document.From.Format("Monday 2 January") + " to " + last.Format("Sunday 2 January 2006")
+	}
+	return document.From.Format("Monday 2 January 2006")
+}
+
+// ComparisonLabel describes what the changes in the report are relative to
+func (document Document) ComparisonLabel() string {
+	if document.Period == PeriodWeek {
+		return "the week before"
+	}
+	return "the day before"
+}
+
+// Count returns the number of measurements in all sections
+func (document Document) Count() int {
+	count := 0
+	for _, section := range document.Sections {
+		count += section.Current.Count
+	}
+	return count
+}
+
+// PeakLabel returns what the peak of a metric is, the lowest score or the
+// highest value of anything else
+func PeakLabel(metric stats.Metric) string {
+	if metric == stats.MetricScore {
+		return "Lowest"
+	}
+	return "Highest"
+}
+
+// newSection computes a section from the measurements of the period and
+// of the one before it, both ordered by timestamp
+func newSection(subject Subject, document Document, current, previous []models.Measurement) Section {
+	section := Section{
+		Name:     subject.Name,
+		Current:  stats.Compute(current, document.From, document.To, time.Local),
+		Previous: stats.Compute(previous, document.PreviousFrom, document.From, time.Local),
+		Peaks:    make(map[stats.Metric]stats.Peak, len(stats.Metrics)),
+		Series:   make(map[stats.Metric][]Point, len(stats.Metrics)),
+	}
+
+	for _, device := range subject.Devices {
+		section.Devices = append(section.Devices, device.Name)
+	}
+
+	if len(current) > 0 {
+		for _, metric := range stats.Metrics {
+			if metric == stats.MetricScore {
+				section.Peaks[metric] = stats.Lowest(current, metric)
+			} else {
+				section.Peaks[metric] = stats.Highest(current, metric)
+			}
+			section.Series[metric] = series(current, metric, document.From, document.Period.Resolution())
+		}
+	}
+
+	for _, threshold := range Thresholds {
+		section.Exceedances = append(section.Exceedances, Exceedance{
+			Threshold: threshold,
+			Current:   stats.TimeAbove(current, threshold.Metric, threshold.Limit),
+			Previous:  stats.TimeAbove(previous, threshold.Metric, threshold.Limit),
+		})
+	}
+
+	return section
+}
+
+// series averages a metric into buckets of the resolution counted from
+// the start of the period. Buckets without measurements are left out.
+func series(measurements []models.Measurement, metric stats.Metric, from time.Time, resolution time.Duration) []Point {
+	var points []Point
+	var sum float64
+	count := 0
+	bucket := -1
+
+	flush := func() {
+		if count > 0 {
+			points = append(points, Point{
+				Timestamp: from.Add(time.Duration(bucket) * resolution),
+				Value:     sum / float64(count),
+			})
+		}
+	}
+
+	for _, measurement := range measurements {
+		index := int(measurement.Timestamp.Sub(from) / resolution)
+		if index != bucket {
+			flush()
+			bucket, sum, count = index, 0, 0
+		}
+		sum += metric.Value(measurement)
+		count++
+	}
+	flush()
+
+	return points
+}
+
+// combine merges the measurements of several devices into one series by
+// averaging the measurements taken within the same minute
+func combine(measurements []models.Measurement) []models.Measurement {
+	byMinute := make(map[time.Time][]models.Measurement)
+	var minutes []time.Time
+
+	for _, measurement := range measurements {
+		minute := measurement.Timestamp.Truncate(time.Minute)
+		if _, exists := byMinute[minute]; !exists {
+			minutes = append(minutes, minute)
+		}
+		byMinute[minute] = append(byMinute[minute], measurement)
+	}
+
+	sort.Slice(minutes, func(i, j int) bool { return minutes[i].Before(minutes[j]) })
+
+	combined := make([]models.Measurement, 0, len(minutes))
+	for _, minute := range minutes {
+		average := models.AverageMeasurements(byMinute[minute])
+		average.Timestamp = minute
+		combined = append(combined, average)
+	}
+	return combined
+}
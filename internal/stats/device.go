@@ -0,0 +1,23 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// ForDevice summarizes the stored measurements of a device between two
+// points in time, with hours of the day in local time
+func ForDevice(db *gorm.DB, deviceID uint, from, to time.Time) (Report, error) {
+	var measurements []models.Measurement
+	err := db.Select("timestamp, temperature, humidity, co2, voc, pm25, score").
+		Where("device_id = ? AND timestamp BETWEEN ? AND ?", deviceID, from.UTC(), to.UTC()).
+		Order("timestamp ASC").
+		Find(&measurements).Error
+	if err != nil {
+		return Report{}, err
+	}
+
+	return Compute(measurements, from, to, time.Local), nil
+}
@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// HeatmapCell is the average of a metric over an hour of a day
+type HeatmapCell struct {
+	Value float64
+	Count int // Number of hourly averages in the cell, zero when there is no data
+}
+
+// Heatmap is the average of a metric per day and hour of the day, oldest day first
+type Heatmap struct {
+	Metric Metric
+	Days   []time.Time // Midnight of each day
+	Cells  [][24]HeatmapCell
+	Min    float64 // Lowest average of all cells with data
+	Max    float64 // Highest average of all cells with data
+}
+
+// HasData tells whether any cell of the heatmap has data
+func (heatmap Heatmap) HasData() bool {
+	for _, day := range heatmap.Cells {
+		for _, cell := range day {
+			if cell.Count > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewHeatmap places hourly averages into a grid of the given number of days
+// ending with the day of to, in the given location
+func NewHeatmap(metric Metric, points []models.HistoryPoint, days int, to time.Time, location *time.Location) Heatmap {
+	to = to.In(location)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, location)
+
+	heatmap := Heatmap{
+		Metric: metric,
+		Days:   make([]time.Time, days),
+		Cells:  make([][24]HeatmapCell, days),
+	}
+	for i := range heatmap.Days {
+		heatmap.Days[i] = last.AddDate(0, 0, i-days+1)
+	}
+
+	for _, point := range points {
+		timestamp := point.Timestamp.In(location)
+		day := time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), 0, 0, 0, 0, location)
+		index := days - 1 - int(last.Sub(day).Hours()/24+0.5)
+		if index < 0 || index >= days {
+			continue
+		}
+
+		// Days with a daylight saving change can have an hour twice
+		cell := &heatmap.Cells[index][timestamp.Hour()]
+		cell.Value = (cell.Value*float64(cell.Count) + point.Value) / float64(cell.Count+1)
+		cell.Count++
+	}
+
+	first := true
+	for _, day := range heatmap.Cells {
+		for _, cell := range day {
+			if cell.Count == 0 {
+				continue
+			}
+			if first || cell.Value < heatmap.Min {
+				heatmap.Min = cell.Value
+			}
+			if first || cell.Value > heatmap.Max {
+				heatmap.Max = cell.Value
+			}
+			first = false
+		}
+	}
+
+	return heatmap
+}
+
+// HeatmapForDevice builds a heatmap of a metric of a device over the last
+// days up to to, from hourly averages of the stored measurements
+func HeatmapForDevice(db *gorm.DB, deviceID uint, metric Metric, days int, to time.Time) (Heatmap, error) {
+	local := to.In(time.Local)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1-days)
+
+	points, err := models.MeasurementHistory(db, deviceID, string(metric), from, to, time.Hour)
+	if err != nil {
+		return Heatmap{}, err
+	}
+
+	return NewHeatmap(metric, points, days, to, time.Local), nil
+}
@@ -0,0 +1,364 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+)
+
+// Score thresholds of the quality bands, the same as the colors of the score circle
+const (
+	ModerateScoreBelow = 75
+	PoorScoreBelow     = 30
+)
+
+// MaxGap is the longest time between two measurements that is counted
+// towards the time spent in a band, longer gaps mean the device was offline
+const MaxGap = 5 * time.Minute
+
+// WorstHoursCount is how many hours of the day are listed as the worst
+const WorstHoursCount = 3
+
+// Band is a range of scores with the same air quality
+type Band string
+
+const (
+	BandGood     Band = "good"
+	BandModerate Band = "moderate"
+	BandPoor     Band = "poor"
+)
+
+// Bands lists all bands from best to worst
+var Bands = []Band{BandGood, BandModerate, BandPoor}
+
+// BandForScore returns the band a score falls into
+func BandForScore(score float64) Band {
+	switch {
+	case score < PoorScoreBelow:
+		return BandPoor
+	case score < ModerateScoreBelow:
+		return BandModerate
+	default:
+		return BandGood
+	}
+}
+
+// DisplayName returns the human readable name of the band
+func (band Band) DisplayName() string {
+	switch band {
+	case BandGood:
+		return "Good"
+	case BandModerate:
+		return "Moderate"
+	case BandPoor:
+		return "Poor"
+	default:
+		return string(band)
+	}
+}
+
+// Metric identifies a measured value
+type Metric string
+
+const (
+	MetricScore       Metric = "score"
+	MetricTemperature Metric = "temperature"
+	MetricHumidity    Metric = "humidity"
+	MetricCO2         Metric = "co2"
+	MetricVOC         Metric = "voc"
+	MetricPM25        Metric = "pm25"
+)
+
+// Metrics lists all metrics in display order
+var Metrics = []Metric{MetricScore, MetricTemperature, MetricHumidity, MetricCO2, MetricVOC, MetricPM25}
+
+// Value returns the metric of a measurement in the unit it is stored in
+func (metric Metric) Value(measurement models.Measurement) float64 {
+	switch metric {
+	case MetricTemperature:
+		return measurement.Temperature
+	case MetricHumidity:
+		return measurement.Humidity
+	case MetricCO2:
+		return measurement.CO2
+	case MetricVOC:
+		return measurement.VOC
+	case MetricPM25:
+		return measurement.PM25
+	default:
+		return measurement.Score
+	}
+}
+
+// DisplayName returns the human readable name of the metric
+func (metric Metric) DisplayName() string {
+	switch metric {
+	case MetricTemperature:
+		return "Temperature"
+	case MetricHumidity:
+		return "Humidity"
+	case MetricCO2:
+		return "CO₂"
+	case MetricVOC:
+		return "VOC"
+	case MetricPM25:
+		return "PM2.5"
+	default:
+		return "Score"
+	}
+}
+
+// Summary describes the distribution of a metric
+type Summary struct {
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	P95    float64
+}
+
+// Map applies a unit conversion to all values of the summary. The
+// conversion must preserve order, like all unit conversions of the app do.
+func (summary Summary) Map(convert func(float64) float64) Summary {
+	return Summary{
+		Min:    convert(summary.Min),
+		Max:    convert(summary.Max),
+		Mean:   convert(summary.Mean),
+		Median: convert(summary.Median),
+		P95:    convert(summary.P95),
+	}
+}
+
+// HourScore is the average score of an hour of the day
+type HourScore struct {
+	Hour  int // 0 to 23 in local time
+	Score float64
+	Count int
+}
+
+// Report summarizes the measurements of a device over a period of time
+type Report struct {
+	From       time.Time
+	To         time.Time
+	Count      int
+	Metrics    map[Metric]Summary
+	TimeInBand map[Band]time.Duration
+	WorstHours []HourScore // Hours of the day with the lowest average score, worst first
+}
+
+// Compute summarizes measurements ordered by timestamp. Hours of the day
+// are in the given location.
+func Compute(measurements []models.Measurement, from, to time.Time, location *time.Location) Report {
+	report := Report{
+		From:       from,
+		To:         to,
+		Count:      len(measurements),
+		Metrics:    make(map[Metric]Summary, len(Metrics)),
+		TimeInBand: make(map[Band]time.Duration, len(Bands)),
+	}
+
+	if len(measurements) == 0 {
+		return report
+	}
+
+	values := make([]float64, len(measurements))
+	for _, metric := range Metrics {
+		for i, measurement := range measurements {
+			values[i] = metric.Value(measurement)
+		}
+		report.Metrics[metric] = Summarize(values)
+	}
+
+	forEachInterval(measurements, func(measurement models.Measurement, duration time.Duration) {
+		report.TimeInBand[BandForScore(measurement.Score)] += duration
+	})
+
+	report.WorstHours = worstHours(measurements, location)
+
+	return report
+}
+
+// forEachInterval calls fn with every measurement, except the last, and the
+// time until the next one. Gaps longer than MaxGap are skipped.
+func forEachInterval(measurements []models.Measurement, fn func(models.Measurement, time.Duration)) {
+	for i := 0; i+1 < len(measurements); i++ {
+		gap := measurements[i+1].Timestamp.Sub(measurements[i].Timestamp)
+		if gap > 0 && gap <= MaxGap {
+			fn(measurements[i], gap)
+		}
+	}
+}
+
+// TimeAbove returns how long a metric was above a limit, counted the same
+// way as the time spent in a band
+func TimeAbove(measurements []models.Measurement, metric Metric, limit float64) time.Duration {
+	var total time.Duration
+	forEachInterval(measurements, func(measurement models.Measurement, duration time.Duration) {
+		if metric.Value(measurement) > limit {
+			total += duration
+		}
+	})
+	return total
+}
+
+// Peak is the most extreme value of a metric and when it was measured
+type Peak struct {
+	Value     float64
+	Timestamp time.Time
+}
+
+// Highest returns the highest value of a metric, the earliest one if it was
+// measured several times
+func Highest(measurements []models.Measurement, metric Metric) Peak {
+	return extreme(measurements, metric, func(value, peak float64) bool { return value > peak })
+}
+
+// Lowest returns the lowest value of a metric, the earliest one if it was
+// measured several times
+func Lowest(measurements []models.Measurement, metric Metric) Peak {
+	return extreme(measurements, metric, func(value, peak float64) bool { return value < peak })
+}
+
+// extreme returns the first measured value that no other value beats
+func extreme(measurements []models.Measurement, metric Metric, beats func(value, peak float64) bool) Peak {
+	var peak Peak
+	for i, measurement := range measurements {
+		value := metric.Value(measurement)
+		if i == 0 || beats(value, peak.Value) {
+			peak = Peak{Value: value, Timestamp: measurement.Timestamp}
+		}
+	}
+	return peak
+}
+
+// Summarize computes the summary of a list of values. Percentiles are
+// interpolated between the closest values.
+func Summarize(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+
+	return Summary{
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   sum / float64(len(sorted)),
+		Median: percentile(sorted, 0.5),
+		P95:    percentile(sorted, 0.95),
+	}
+}
+
+// percentile returns the value below which the given fraction of the
+// sorted values fall
+func percentile(sorted []float64, fraction float64) float64 {
+	position := fraction * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	weight := position - float64(lower)
+
+	return sorted[lower]*(1-weight) + sorted[upper]*weight
+}
+
+// worstHours returns the hours of the day with the lowest average score
+func worstHours(measurements []models.Measurement, location *time.Location) []HourScore {
+	var hours [24]HourScore
+	for hour := range hours {
+		hours[hour].Hour = hour
+	}
+
+	for _, measurement := range measurements {
+		hour := measurement.Timestamp.In(location).Hour()
+		hours[hour].Score += measurement.Score
+		hours[hour].Count++
+	}
+
+	var result []HourScore
+	for _, hour := range hours {
+		if hour.Count > 0 {
+			hour.Score /= float64(hour.Count)
+			result = append(result, hour)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score < result[j].Score
+	})
+
+	if len(result) > WorstHoursCount {
+		result = result[:WorstHoursCount]
+	}
+	return result
+}
+
+// ParseRange parses a period of time like 90m, 24h, 7d or 2w
+func ParseRange(text string) (time.Duration, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	units := map[string]time.Duration{
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	if len(text) >= 2 {
+		if unit, ok := units[text[len(text)-1:]]; ok {
+			count, err := strconv.Atoi(text[:len(text)-1])
+			if err == nil && count > 0 {
+				return time.Duration(count) * unit, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("invalid range %q, expected a positive number followed by m, h, d or w, like 7d", text)
+}
+
+// FormatDuration formats a duration in whole minutes, like 2d 3h 15m
+func FormatDuration(duration time.Duration) string {
+	minutes := int(duration.Round(time.Minute) / time.Minute)
+	days, hours := minutes/(24*60), minutes/60%24
+	minutes %= 60
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	return strings.Join(parts, " ")
+}
+
+// TotalTime returns the time covered by the bands
+func (report *Report) TotalTime() time.Duration {
+	var total time.Duration
+	for _, duration := range report.TimeInBand {
+		total += duration
+	}
+	return total
+}
+
+// BandShare returns the fraction of the covered time spent in a band
+func (report *Report) BandShare(band Band) float64 {
+	total := report.TotalTime()
+	if total == 0 {
+		return 0
+	}
+	return float64(report.TimeInBand[band]) / float64(total)
+}
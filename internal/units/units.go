@@ -0,0 +1,199 @@
+package units
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TemperatureUnit is the unit temperatures are displayed in
+type TemperatureUnit string
+
+const (
+	Celsius    TemperatureUnit = "celsius"
+	Fahrenheit TemperatureUnit = "fahrenheit"
+	Kelvin     TemperatureUnit = "kelvin"
+)
+
+// TemperatureUnits lists all supported temperature units in display order
+var TemperatureUnits = []TemperatureUnit{Celsius, Fahrenheit, Kelvin}
+
+// ParseTemperatureUnit parses a temperature unit from its name or symbol
+func ParseTemperatureUnit(value string) (TemperatureUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "c", "°c", "celsius":
+		return Celsius, nil
+	case "f", "°f", "fahrenheit":
+		return Fahrenheit, nil
+	case "k", "kelvin":
+		return Kelvin, nil
+	default:
+		return "", fmt.Errorf("unknown temperature unit %q, expected celsius, fahrenheit or kelvin", value)
+	}
+}
+
+// Symbol returns the symbol of the unit
+func (unit TemperatureUnit) Symbol() string {
+	switch unit {
+	case Fahrenheit:
+		return "°F"
+	case Kelvin:
+		return "K"
+	default:
+		return "°C"
+	}
+}
+
+// DisplayName returns the human readable name of the unit
+func (unit TemperatureUnit) DisplayName() string {
+	switch unit {
+	case Fahrenheit:
+		return "Fahrenheit"
+	case Kelvin:
+		return "Kelvin"
+	default:
+		return "Celsius"
+	}
+}
+
+// FromCelsius converts a temperature in degrees Celsius to the unit
+func (unit TemperatureUnit) FromCelsius(celsius float64) float64 {
+	switch unit {
+	case Fahrenheit:
+		return celsius*9/5 + 32
+	case Kelvin:
+		return celsius + 273.15
+	default:
+		return celsius
+	}
+}
+
+// ToCelsius converts a temperature in the unit to degrees Celsius
+func (unit TemperatureUnit) ToCelsius(value float64) float64 {
+	switch unit {
+	case Fahrenheit:
+		return (value - 32) * 5 / 9
+	case Kelvin:
+		return value - 273.15
+	default:
+		return value
+	}
+}
+
+// FromCelsiusDelta converts a temperature difference in Celsius to the unit
+func (unit TemperatureUnit) FromCelsiusDelta(celsius float64) float64 {
+	if unit == Fahrenheit {
+		return celsius * 9 / 5
+	}
+	return celsius
+}
+
+// VOCUnit is the unit VOC concentrations are displayed in
+type VOCUnit string
+
+const (
+	PartsPerBillion         VOCUnit = "ppb"
+	MicrogramsPerCubicMeter VOCUnit = "ugm3"
+)
+
+// VOCUnits lists all supported VOC units in display order
+var VOCUnits = []VOCUnit{PartsPerBillion, MicrogramsPerCubicMeter}
+
+// VOCPPBToMicrogramsPerCubicMeter converts TVOC ppb to µg/m³ for the
+// standard TVOC reference mixture (mean molar mass 110 g/mol) at 25 °C
+const VOCPPBToMicrogramsPerCubicMeter = 4.5
+
+// ParseVOCUnit parses a VOC unit from its name or symbol
+func ParseVOCUnit(value string) (VOCUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "ppb":
+		return PartsPerBillion, nil
+	case "ugm3", "µg/m³", "μg/m³", "ug/m3":
+		return MicrogramsPerCubicMeter, nil
+	default:
+		return "", fmt.Errorf("unknown VOC unit %q, expected ppb or ugm3", value)
+	}
+}
+
+// Symbol returns the symbol of the unit
+func (unit VOCUnit) Symbol() string {
+	if unit == MicrogramsPerCubicMeter {
+		return "μg/m³"
+	}
+	return "ppb"
+}
+
+// FromPPB converts a VOC concentration in ppb to the unit
+func (unit VOCUnit) FromPPB(ppb float64) float64 {
+	if unit == MicrogramsPerCubicMeter {
+		return ppb * VOCPPBToMicrogramsPerCubicMeter
+	}
+	return ppb
+}
+
+// Preferences describes how measurements are presented to the user
+type Preferences struct {
+	Temperature      TemperatureUnit
+	VOC              VOCUnit
+	DecimalSeparator string
+}
+
+// NewPreferences creates preferences for the given units, falling back to
+// metric units when empty, with the decimal separator of the user's locale
+func NewPreferences(temperature TemperatureUnit, voc VOCUnit) Preferences {
+	if temperature == "" {
+		temperature = Celsius
+	}
+	if voc == "" {
+		voc = PartsPerBillion
+	}
+
+	return Preferences{
+		Temperature:      temperature,
+		VOC:              voc,
+		DecimalSeparator: LocaleDecimalSeparator(),
+	}
+}
+
+// FormatNumber formats a number with a fixed number of decimals using the
+// preferred decimal separator
+func (preferences Preferences) FormatNumber(value float64, decimals int) string {
+	formatted := strconv.FormatFloat(value, 'f', decimals, 64)
+	if preferences.DecimalSeparator != "" && preferences.DecimalSeparator != "." {
+		formatted = strings.Replace(formatted, ".", preferences.DecimalSeparator, 1)
+	}
+	return formatted
+}
+
+// Languages whose locales use a comma as the decimal separator
+var commaDecimalLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true,
+	"es": true, "et": true, "eu": true, "fi": true, "fr": true, "gl": true,
+	"hr": true, "hu": true, "id": true, "is": true, "it": true, "lt": true,
+	"lv": true, "nb": true, "nl": true, "nn": true, "no": true, "pl": true,
+	"pt": true, "ro": true, "ru": true, "sk": true, "sl": true, "sr": true,
+	"sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// LocaleDecimalSeparator returns the decimal separator of the user's
+// numeric locale, as configured by LC_ALL, LC_NUMERIC or LANG
+func LocaleDecimalSeparator() string {
+	locale := ""
+	for _, variable := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(variable); value != "" {
+			locale = value
+			break
+		}
+	}
+
+	language := strings.ToLower(locale)
+	if index := strings.IndexAny(language, "_.@"); index >= 0 {
+		language = language[:index]
+	}
+
+	if commaDecimalLanguages[language] {
+		return ","
+	}
+	return "."
+}
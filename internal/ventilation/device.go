@@ -0,0 +1,19 @@
+package ventilation
+
+import (
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// ForDevice estimates the ventilation and occupancy of the room of a device
+// from its stored CO₂ measurements between two points in time
+func ForDevice(db *gorm.DB, device models.Device, from, to time.Time) (Estimate, error) {
+	points, err := models.MeasurementHistory(db, device.ID, "co2", from, to, Resolution)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	return Compute(points, device.RoomVolume, from, to), nil
+}
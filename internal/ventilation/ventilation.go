@@ -0,0 +1,304 @@
+// Package ventilation estimates how well a room is ventilated, and when and
+// by how many people it was occupied, from the CO₂ measured in it.
+//
+// People exhale CO₂ and ventilation replaces indoor air with outdoor air, so
+// once a room empties CO₂ decays exponentially towards the outdoor level at a
+// rate set by the air changes per hour. While the room is occupied CO₂ rises
+// with the number of people, less what the ventilation removes.
+package ventilation
+
+import (
+	"math"
+	"time"
+
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/airquality"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/models"
+	"github.com/monorkin/gnome-desktop-air-monitor/internal/stats"
+)
+
+const (
+	// Resolution is the size of the buckets CO₂ is averaged into before
+	// estimating, which smooths out the noise of the sensor
+	Resolution = 5 * time.Minute
+
+	// CO2PerPerson is how much CO₂ an adult doing office work exhales, in m³ per hour
+	CO2PerPerson = 0.018
+
+	// MinDecayDuration is how long CO₂ has to keep falling for the fall to be
+	// used to estimate the air changes
+	MinDecayDuration = 30 * time.Minute
+
+	// MinDecayExcess is how far above outdoor air, in ppm, CO₂ has to start
+	// falling from, smaller falls drown in the noise of the sensor
+	MinDecayExcess = 200
+
+	// MinRiseRate is how fast, in ppm per hour, people have to add CO₂ to
+	// the room for it to count as occupied
+	MinRiseRate = 100
+
+	// MinOccupancy is how long a room has to be occupied for the occupancy to count
+	MinOccupancy = 15 * time.Minute
+
+	// decayEndExcess is how close to outdoor air, in ppm, a decay ends, as
+	// the logarithm of the excess gets dominated by noise near zero
+	decayEndExcess = 50
+
+	// decayTolerance is how much CO₂ may rise within a decay, in ppm, to
+	// allow for noise
+	decayTolerance = 10
+
+	// plateauShare is how much of the excess CO₂ at the start of a fall has
+	// to remain for a bucket to count as part of a plateau before the fall
+	plateauShare = 0.9
+
+	// maxBucketGap is the longest time between buckets that still counts as continuous
+	maxBucketGap = 2 * Resolution
+)
+
+// Airflow per person, in l/s, for EN 16798-1 categories I to III
+var airflowCategoryLimits = []struct {
+	perPerson float64
+	name      string
+}{
+	{10, "Category I"},
+	{7, "Category II"},
+	{4, "Category III"},
+}
+
+// Decay is a period in which CO₂ fell towards the outdoor level
+type Decay struct {
+	From       time.Time
+	To         time.Time
+	StartCO2   float64 // ppm
+	EndCO2     float64 // ppm
+	AirChanges float64 // Air changes per hour estimated from the fall
+}
+
+// Occupancy is a period in which people added CO₂ to the room
+type Occupancy struct {
+	From    time.Time
+	To      time.Time
+	PeakCO2 float64 // ppm
+	People  float64 // Estimated headcount, 0 if the volume of the room is unknown
+}
+
+// Estimate describes the ventilation and occupancy of a room over a period
+type Estimate struct {
+	From        time.Time
+	To          time.Time
+	Volume      float64 // Volume of the room in m³, 0 if unknown
+	Decays      []Decay
+	Occupancies []Occupancy
+}
+
+// AirChanges returns the median air changes per hour of all decays, and
+// false if CO₂ never fell for long enough to tell
+func (estimate Estimate) AirChanges() (float64, bool) {
+	if len(estimate.Decays) == 0 {
+		return 0, false
+	}
+
+	changes := make([]float64, len(estimate.Decays))
+	for i, decay := range estimate.Decays {
+		changes[i] = decay.AirChanges
+	}
+	return stats.Summarize(changes).Median, true
+}
+
+// Airflow returns the outdoor air brought into the room in m³ per hour, and
+// false if the air changes or the volume of the room are unknown
+func (estimate Estimate) Airflow() (float64, bool) {
+	airChanges, ok := estimate.AirChanges()
+	if !ok || estimate.Volume <= 0 {
+		return 0, false
+	}
+	return airChanges * estimate.Volume, true
+}
+
+// PeakPeople returns the highest headcount of all occupancies
+func (estimate Estimate) PeakPeople() float64 {
+	peak := 0.0
+	for _, occupancy := range estimate.Occupancies {
+		peak = max(peak, occupancy.People)
+	}
+	return peak
+}
+
+// AirflowPerPerson returns the outdoor air per person at the highest
+// headcount in l/s, and false if it can't be estimated
+func (estimate Estimate) AirflowPerPerson() (float64, bool) {
+	airflow, ok := estimate.Airflow()
+	people := math.Round(estimate.PeakPeople())
+	if !ok || people < 1 {
+		return 0, false
+	}
+	return airflow * 1000 / 3600 / people, true
+}
+
+// OccupiedTime returns how long the room was occupied in total
+func (estimate Estimate) OccupiedTime() time.Duration {
+	var total time.Duration
+	for _, occupancy := range estimate.Occupancies {
+		total += occupancy.To.Sub(occupancy.From)
+	}
+	return total
+}
+
+// AirflowCategory returns the EN 16798-1 category for an airflow per person in l/s
+func AirflowCategory(perPerson float64) airquality.Level {
+	maxRank := len(airflowCategoryLimits)
+
+	for rank, limit := range airflowCategoryLimits {
+		if perPerson >= limit.perPerson {
+			return airquality.Level{Rank: rank, Max: maxRank, Name: limit.name}
+		}
+	}
+
+	return airquality.Level{Rank: maxRank, Max: maxRank, Name: "Category IV"}
+}
+
+// Compute estimates the ventilation and occupancy of a room with the given
+// volume in m³, 0 if unknown, from its CO₂ averaged into buckets of
+// Resolution, ordered by timestamp
+func Compute(points []models.HistoryPoint, volume float64, from, to time.Time) Estimate {
+	estimate := Estimate{
+		From:   from,
+		To:     to,
+		Volume: volume,
+		Decays: findDecays(points),
+	}
+
+	airChanges, _ := estimate.AirChanges()
+	estimate.Occupancies = findOccupancies(points, airChanges, volume)
+
+	return estimate
+}
+
+// findDecays finds the runs of buckets in which CO₂ kept falling, and fits
+// the air changes to each of them. This assumes nobody is in the room while
+// CO₂ falls, people who stay make the ventilation look worse than it is.
+func findDecays(points []models.HistoryPoint) []Decay {
+	var decays []Decay
+
+	for start := 0; start < len(points); {
+		end := start
+		for end+1 < len(points) &&
+			points[end+1].Timestamp.Sub(points[end].Timestamp) <= maxBucketGap &&
+			points[end+1].Value <= points[end].Value+decayTolerance &&
+			points[end+1].Value-airquality.OutdoorCO2 >= decayEndExcess {
+			end++
+		}
+
+		run := trimPlateau(points[start : end+1])
+		first, last := run[0], run[len(run)-1]
+		if first.Value-airquality.OutdoorCO2 >= MinDecayExcess &&
+			last.Timestamp.Sub(first.Timestamp) >= MinDecayDuration &&
+			last.Value < first.Value {
+			if airChanges := fitAirChanges(run); airChanges > 0 {
+				decays = append(decays, Decay{
+					From:       first.Timestamp,
+					To:         last.Timestamp,
+					StartCO2:   first.Value,
+					EndCO2:     last.Value,
+					AirChanges: airChanges,
+				})
+			}
+		}
+
+		start = end + 1
+	}
+
+	return decays
+}
+
+// trimPlateau drops the start of a run of falling buckets in which CO₂
+// barely fell, like a steady level while people were still in the room,
+// so the run starts where the room emptied
+func trimPlateau(run []models.HistoryPoint) []models.HistoryPoint {
+	excess := run[0].Value - airquality.OutdoorCO2
+	for i := len(run) - 1; i > 0; i-- {
+		if run[i].Value-airquality.OutdoorCO2 >= plateauShare*excess {
+			return run[i:]
+		}
+	}
+	return run
+}
+
+// fitAirChanges fits an exponential decay towards outdoor air to the
+// buckets with least squares on the logarithm of the excess CO₂, and
+// returns its rate in air changes per hour
+func fitAirChanges(points []models.HistoryPoint) float64 {
+	var sumX, sumY, sumXY, sumXX float64
+	for _, point := range points {
+		x := point.Timestamp.Sub(points[0].Timestamp).Hours()
+		y := math.Log(point.Value - airquality.OutdoorCO2)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return -(n*sumXY - sumX*sumY) / denominator
+}
+
+// findOccupancies finds the runs of buckets in which people added CO₂ to
+// the room. The CO₂ added is how fast it rose plus what the ventilation
+// removed, so a room with a steady but high level counts as occupied too
+// once the air changes are known.
+func findOccupancies(points []models.HistoryPoint, airChanges, volume float64) []Occupancy {
+	var occupancies []Occupancy
+
+	start := -1
+	var sources []float64
+	peak := 0.0
+
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+
+		occupancy := Occupancy{
+			From:    points[start-1].Timestamp,
+			To:      points[end].Timestamp,
+			PeakCO2: peak,
+		}
+		if occupancy.To.Sub(occupancy.From) >= MinOccupancy {
+			if volume > 0 {
+				occupancy.People = stats.Summarize(sources).Mean * volume / 1e6 / CO2PerPerson
+			}
+			occupancies = append(occupancies, occupancy)
+		}
+
+		start, sources, peak = -1, nil, 0
+	}
+
+	for i := 1; i < len(points); i++ {
+		elapsed := points[i].Timestamp.Sub(points[i-1].Timestamp)
+		if elapsed > maxBucketGap {
+			flush(i - 1)
+			continue
+		}
+
+		rise := (points[i].Value - points[i-1].Value) / elapsed.Hours()
+		excess := (points[i].Value+points[i-1].Value)/2 - airquality.OutdoorCO2
+		source := rise + airChanges*max(excess, 0)
+		if source < MinRiseRate {
+			flush(i - 1)
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+		sources = append(sources, source)
+		peak = max(peak, points[i].Value)
+	}
+	flush(len(points) - 1)
+
+	return occupancies
+}
@@ -11,6 +11,31 @@ const AirMonitorInterface = `<node>
     <method name="GetSelectedDevice">
       <arg type="a{sv}" direction="out" name="device"/>
     </method>
+    <method name="ListDevices">
+      <arg type="aa{sv}" direction="out" name="devices"/>
+    </method>
+    <method name="GetDevice">
+      <arg type="s" direction="in" name="serial"/>
+      <arg type="a{sv}" direction="out" name="device"/>
+    </method>
+    <method name="GetHistory">
+      <arg type="s" direction="in" name="serial"/>
+      <arg type="s" direction="in" name="metric"/>
+      <arg type="x" direction="in" name="from"/>
+      <arg type="x" direction="in" name="to"/>
+      <arg type="u" direction="in" name="resolution"/>
+      <arg type="a(xd)" direction="out" name="points"/>
+    </method>
+    <method name="SetSelectedDevice">
+      <arg type="s" direction="in" name="serial"/>
+    </method>
+    <method name="GetSettings">
+      <arg type="a{sv}" direction="out" name="settings"/>
+    </method>
+    <method name="SetSetting">
+      <arg type="s" direction="in" name="key"/>
+      <arg type="v" direction="in" name="value"/>
+    </method>
     <method name="OpenApp">
     </method>
     <method name="OpenSettings">
@@ -23,6 +48,13 @@ const AirMonitorInterface = `<node>
     <signal name="DeviceUpdated">
       <arg type="a{sv}" name="device"/>
     </signal>
+    <signal name="MeasurementStored">
+      <arg type="s" name="serial"/>
+      <arg type="a{sv}" name="device"/>
+    </signal>
+    <signal name="SettingsChanged">
+      <arg type="a{sv}" name="settings"/>
+    </signal>
     <signal name="VisibilityChanged">
       <arg type="b" name="visible"/>
     </signal>
@@ -77,6 +109,15 @@ const AirMonitorIndicator = GObject.registerClass(
       // Separator
       this.menu.addMenuItem(new PopupMenu.PopupSeparatorMenuItem());
 
+      // Device selection, populated when the menu opens
+      this._deviceSubMenu = new PopupMenu.PopupSubMenuMenuItem("Show Device");
+      this.menu.addMenuItem(this._deviceSubMenu);
+      this.menu.connect("open-state-changed", (menu, open) => {
+        if (open) {
+          this._refreshDeviceChoices();
+        }
+      });
+
       // Action buttons
       const actionsSection = new PopupMenu.PopupMenuSection();
 
@@ -222,6 +263,58 @@ const AirMonitorIndicator = GObject.registerClass(
       }
     }
 
+    _refreshDeviceChoices() {
+      if (!this._proxy) {
+        return;
+      }
+
+      try {
+        this._proxy.ListDevicesRemote((result, error) => {
+          if (error) {
+            console.error("Failed to list devices:", error);
+            return;
+          }
+
+          const [devices] = result;
+          const selectedSerial = this._currentDevice?.serial?.unpack();
+          this._deviceSubMenu.menu.removeAll();
+
+          devices.forEach((device) => {
+            const serial = device.serial?.unpack();
+            const name = device.name?.unpack() || serial;
+            const item = new PopupMenu.PopupMenuItem(name);
+            if (serial === selectedSerial) {
+              item.setOrnament(PopupMenu.Ornament.CHECK);
+            }
+            item.connect("activate", () => this._selectDevice(serial));
+            this._deviceSubMenu.menu.addMenuItem(item);
+          });
+        });
+      } catch (e) {
+        console.error("Error calling ListDevices:", e);
+      }
+    }
+
+    _selectDevice(serial) {
+      if (!this._proxy) {
+        return;
+      }
+
+      try {
+        this._proxy.SetSettingRemote(
+          "status_bar_device_serial_number",
+          new GLib.Variant("s", serial),
+          (result, error) => {
+            if (error) {
+              console.error("Failed to select device:", error);
+            }
+          },
+        );
+      } catch (e) {
+        console.error("Error calling SetSetting:", e);
+      }
+    }
+
     _updateDeviceDisplay(deviceData) {
       this._currentDevice = deviceData;
 
@@ -252,37 +345,77 @@ const AirMonitorIndicator = GObject.registerClass(
           "margin-left: 4px; font-weight: bold; color: #27ae60;";
       }
 
+      // Unit hints sent by the app, older versions always use metric units
+      const temperatureUnit = deviceData.temperature_unit?.unpack() || "°C";
+      const vocUnit = deviceData.voc_unit?.unpack() || "ppb";
+      const decimalSeparator = deviceData.decimal_separator?.unpack() || ".";
+      const format = (value, decimals) =>
+        value === undefined
+          ? "--"
+          : value.toFixed(decimals).replace(".", decimalSeparator);
+
       // Prepare measurements for menu
       const measurements = [
         { label: "Air Quality Score", value: score.toFixed(0), unit: "" },
         {
           label: "Temperature",
-          value: deviceData.temperature?.unpack()?.toFixed(1) || "--",
-          unit: "°C",
+          value: format(deviceData.temperature?.unpack(), 1),
+          unit: temperatureUnit === "K" ? " K" : temperatureUnit,
         },
         {
           label: "Humidity",
-          value: deviceData.humidity?.unpack()?.toFixed(1) || "--",
+          value: format(deviceData.humidity?.unpack(), 1),
           unit: "%",
         },
         {
           label: "CO₂",
-          value: deviceData.co2?.unpack()?.toFixed(0) || "--",
+          value: format(deviceData.co2?.unpack(), 0),
           unit: " ppm",
         },
         {
           label: "VOC",
-          value: deviceData.voc?.unpack()?.toFixed(0) || "--",
-          unit: " ppb",
+          value: format(deviceData.voc?.unpack(), 0),
+          unit: ` ${vocUnit}`,
         },
         {
           label: "PM2.5",
-          value: deviceData.pm25?.unpack()?.toFixed(1) || "--",
+          value: format(deviceData.pm25?.unpack(), 1),
           unit: " μg/m³",
         },
       ];
 
-      const deviceName = deviceData.name?.unpack() || "Unknown Device";
+      // -1 when there were no recent measurements to compute it from
+      if (deviceData.aqi && deviceData.aqi.unpack() >= 0) {
+        measurements.push({
+          label: "US AQI",
+          value: `${deviceData.aqi.unpack()} (${deviceData.aqi_category?.unpack() || "--"})`,
+          unit: "",
+        });
+      }
+
+      if (deviceData.co2_category?.unpack()) {
+        measurements.push({
+          label: "Ventilation",
+          value: deviceData.co2_category.unpack(),
+          unit: "",
+        });
+      }
+
+      // Sent when CO₂ is forecast to reach the configured threshold soon
+      const ventilationAdvice = deviceData.ventilation_advice?.unpack();
+      if (ventilationAdvice) {
+        measurements.push({
+          label: "Forecast",
+          value: ventilationAdvice,
+          unit: "",
+        });
+      }
+
+      let deviceName = deviceData.name?.unpack() || "Unknown Device";
+      const room = deviceData.room?.unpack();
+      if (room) {
+        deviceName = `${deviceName} · ${room}`;
+      }
       this._updateDeviceMenu(deviceName, measurements);
     }
 
@@ -17,6 +17,8 @@ BINARY_NAME="gnome-desktop-air-monitor"
 INSTALL_DIR="/usr/local/bin"
 DESKTOP_DIR="/usr/share/applications"
 ICON_DIR="/usr/share/icons/hicolor"
+SCHEMA_DIR="/usr/share/glib-2.0/schemas"
+SCHEMA_FILE="io.stanko.gnome-desktop-air-monitor.gschema.xml"
 
 # Functions
 log_info() {
@@ -87,6 +89,20 @@ remove_desktop_files() {
   log_success "Icons removed"
 }
 
+# Remove GSettings schema
+remove_schema() {
+  if [ -f "$SCHEMA_DIR/$SCHEMA_FILE" ]; then
+    log_info "Removing GSettings schema..."
+    sudo rm -f "$SCHEMA_DIR/$SCHEMA_FILE"
+    if command -v glib-compile-schemas &>/dev/null; then
+      sudo glib-compile-schemas "$SCHEMA_DIR" 2>/dev/null || true
+    fi
+    log_success "GSettings schema removed"
+  else
+    log_info "GSettings schema not found"
+  fi
+}
+
 # Remove GNOME Shell extension
 remove_extension() {
   local extension_dir_pattern="$HOME/.local/share/gnome-shell/extensions/*air-monitor*"
@@ -190,6 +206,7 @@ main() {
   # Remove components
   remove_binary
   remove_desktop_files
+  remove_schema
   remove_extension
   remove_user_data
 